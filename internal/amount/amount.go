@@ -0,0 +1,54 @@
+// Package amount holds the type every bitcoin value in the service is kept
+// in, shared by the indexer, the data layer and the API models.
+package amount
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// SatoshiPerBitcoin is the number of satoshis in one bitcoin.
+const SatoshiPerBitcoin = 100_000_000
+
+// Max is the 21 million bitcoin supply cap, no valid value exceeds it.
+const Max Amount = 21_000_000 * SatoshiPerBitcoin
+
+var ErrInvalid = errors.New("invalid amount")
+
+// Amount is a quantity of bitcoin in satoshis. It is stored and served as an
+// integer; only the node's JSON, which denominates values in BTC, is decoded
+// from a decimal with Parse.
+type Amount int64
+
+// Parse converts a decimal number of bitcoins, as bitcoind prints it, into
+// satoshis exactly. Values with more precision than a satoshi or beyond the
+// supply cap are rejected rather than rounded.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	btc, ok := new(big.Rat).SetString(s)
+	if !ok || strings.ContainsFunc(s, func(r rune) bool { return !strings.ContainsRune("+-0123456789.eE", r) }) {
+		return 0, fmt.Errorf("%w: %q is not a decimal number", ErrInvalid, s)
+	}
+
+	sat := btc.Mul(btc, new(big.Rat).SetInt64(SatoshiPerBitcoin))
+	if !sat.IsInt() {
+		return 0, fmt.Errorf("%w: %q is finer than a satoshi", ErrInvalid, s)
+	}
+	num := sat.Num()
+	if num.CmpAbs(big.NewInt(int64(Max))) > 0 {
+		return 0, fmt.Errorf("%w: %q exceeds the supply cap", ErrInvalid, s)
+	}
+	return Amount(num.Int64()), nil
+}
+
+// String formats the amount in BTC with all eight decimals.
+func (a Amount) String() string {
+	sign := ""
+	abs := int64(a)
+	if abs < 0 {
+		sign, abs = "-", -abs
+	}
+	return fmt.Sprintf("%s%d.%08d", sign, abs/SatoshiPerBitcoin, abs%SatoshiPerBitcoin)
+}
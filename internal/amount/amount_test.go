@@ -0,0 +1,90 @@
+package amount
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+		err  bool
+	}{
+		{in: "0", want: 0},
+		{in: "1", want: SatoshiPerBitcoin},
+		{in: "0.1", want: 10_000_000},
+		{in: "0.2", want: 20_000_000},
+		{in: "0.3", want: 30_000_000},
+		{in: "0.00000001", want: 1},
+		{in: "1e-8", want: 1},
+		{in: "1E-8", want: 1},
+		{in: "5e-7", want: 50},
+		{in: "2.1e7", want: Max},
+		{in: "20999999.97690000", want: 2_099_999_997_690_000},
+		{in: "21000000", want: Max},
+		{in: "1.12345670", want: 112_345_670},
+		{in: "+0.5", want: 50_000_000},
+		{in: "-0.5", want: -50_000_000},
+		{in: "-0.00000001", want: -1},
+		{in: " 0.001 ", want: 100_000},
+
+		{in: "0.000000001", err: true},
+		{in: "1.123456789", err: true},
+		{in: "1e-9", err: true},
+		{in: "21000000.00000001", err: true},
+		{in: "-21000000.00000001", err: true},
+		{in: "1e9", err: true},
+		{in: "", err: true},
+		{in: "abc", err: true},
+		{in: "0x10", err: true},
+		{in: "1/2", err: true},
+		{in: "NaN", err: true},
+		{in: "Inf", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if tt.err {
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("Parse(%q) = %d, %v, want ErrInvalid", tt.in, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Parse(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseSumIsExact(t *testing.T) {
+	a, _ := Parse("0.1")
+	b, _ := Parse("0.2")
+	c, _ := Parse("0.3")
+	if a+b != c {
+		t.Errorf("0.1 + 0.2 = %d satoshis, want %d", a+b, c)
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in   Amount
+		want string
+	}{
+		{0, "0.00000000"},
+		{1, "0.00000001"},
+		{-1, "-0.00000001"},
+		{30_000_000, "0.30000000"},
+		{2_099_999_997_690_000, "20999999.97690000"},
+		{Max, "21000000.00000000"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Amount(%d).String() = %q, want %q", int64(tt.in), got, tt.want)
+		}
+		if back, err := Parse(tt.want); err != nil || back != tt.in {
+			t.Errorf("Parse(%q) = %d, %v, want %d", tt.want, back, err, tt.in)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS undo_log (
+    id            bigserial PRIMARY KEY,
+    block_height  bigint NOT NULL,
+    action        text NOT NULL,
+    tx_id         text NOT NULL,
+    vout          bigint NOT NULL DEFAULT 0,
+    created_at    timestamp DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_undo_log_block_height ON undo_log(block_height);
+
+-- +migrate Down
+DROP TABLE IF EXISTS undo_log;
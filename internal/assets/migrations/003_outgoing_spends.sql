@@ -0,0 +1,11 @@
+-- +migrate Up
+ALTER TABLE utxos ADD COLUMN IF NOT EXISTS spent_tx_id text;
+ALTER TABLE utxos ADD COLUMN IF NOT EXISTS spent_height bigint;
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS direction text NOT NULL DEFAULT 'incoming';
+
+-- +migrate Down
+ALTER TABLE transactions DROP COLUMN IF EXISTS direction;
+
+ALTER TABLE utxos DROP COLUMN IF EXISTS spent_height;
+ALTER TABLE utxos DROP COLUMN IF EXISTS spent_tx_id;
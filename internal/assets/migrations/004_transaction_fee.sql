@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fee bigint;
+
+-- +migrate Down
+ALTER TABLE transactions DROP COLUMN IF EXISTS fee;
@@ -0,0 +1,15 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS mempool_transactions (
+    id          bigserial PRIMARY KEY,
+    tx_id       text NOT NULL,
+    address_id  bigint NOT NULL REFERENCES addresses(id) ON DELETE CASCADE,
+    amount      bigint NOT NULL,
+    direction   text NOT NULL,
+    first_seen  timestamp DEFAULT now(),
+    UNIQUE(tx_id, address_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_mempool_transactions_address_id ON mempool_transactions(address_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS mempool_transactions;
@@ -0,0 +1,17 @@
+-- +migrate Up
+ALTER TABLE transactions ALTER COLUMN merkle_proof TYPE jsonb USING '[]'::jsonb;
+ALTER TABLE transactions ALTER COLUMN merkle_proof SET DEFAULT '[]'::jsonb;
+
+ALTER TABLE block_headers ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 0;
+ALTER TABLE block_headers ADD COLUMN IF NOT EXISTS bits text NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_transactions_tx_id ON transactions(tx_id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_transactions_tx_id;
+
+ALTER TABLE block_headers DROP COLUMN IF EXISTS bits;
+ALTER TABLE block_headers DROP COLUMN IF EXISTS version;
+
+ALTER TABLE transactions ALTER COLUMN merkle_proof DROP DEFAULT;
+ALTER TABLE transactions ALTER COLUMN merkle_proof TYPE text[] USING '{}'::text[];
@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE block_headers ADD COLUMN IF NOT EXISTS chainwork text NOT NULL DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE block_headers DROP COLUMN IF EXISTS chainwork;
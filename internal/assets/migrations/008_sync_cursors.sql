@@ -0,0 +1,13 @@
+-- +migrate Up
+CREATE TABLE sync_cursors (
+    name text PRIMARY KEY,
+    height bigint NOT NULL
+);
+
+-- Until now block_headers only held fully indexed blocks, so its tip is
+-- where block indexing left off.
+INSERT INTO sync_cursors (name, height)
+SELECT 'blocks', max(height) FROM block_headers HAVING max(height) IS NOT NULL;
+
+-- +migrate Down
+DROP TABLE IF EXISTS sync_cursors;
@@ -0,0 +1,21 @@
+-- +migrate Up
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'active';
+
+CREATE TABLE rescan_jobs (
+    id bigserial PRIMARY KEY,
+    address_id bigint NOT NULL REFERENCES addresses(id) ON DELETE CASCADE,
+    from_height bigint NOT NULL,
+    to_height bigint,
+    current_height bigint NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    error text,
+    created_at timestamp NOT NULL DEFAULT now(),
+    updated_at timestamp NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_rescan_jobs_address_id ON rescan_jobs(address_id);
+CREATE INDEX idx_rescan_jobs_status ON rescan_jobs(status);
+
+-- +migrate Down
+DROP TABLE IF EXISTS rescan_jobs;
+ALTER TABLE addresses DROP COLUMN IF EXISTS status;
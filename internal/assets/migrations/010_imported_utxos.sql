@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE utxos ADD COLUMN IF NOT EXISTS imported boolean NOT NULL DEFAULT false;
+
+-- +migrate Down
+ALTER TABLE utxos DROP COLUMN IF EXISTS imported;
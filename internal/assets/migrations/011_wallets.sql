@@ -0,0 +1,35 @@
+-- +migrate Up
+CREATE TABLE wallets (
+    id bigserial PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    descriptor text NOT NULL,
+    gap_limit int NOT NULL,
+    created_at timestamp NOT NULL DEFAULT now(),
+    UNIQUE(user_id, descriptor)
+);
+
+ALTER TABLE addresses
+    ADD COLUMN wallet_id bigint REFERENCES wallets(id) ON DELETE CASCADE,
+    ADD COLUMN chain int,
+    ADD COLUMN derivation_index int,
+    ADD COLUMN used boolean NOT NULL DEFAULT false;
+
+CREATE INDEX idx_addresses_wallet_id ON addresses(wallet_id);
+
+ALTER TABLE rescan_jobs
+    ALTER COLUMN address_id DROP NOT NULL,
+    ADD COLUMN wallet_id bigint REFERENCES wallets(id) ON DELETE CASCADE;
+
+-- +migrate Down
+ALTER TABLE rescan_jobs DROP COLUMN IF EXISTS wallet_id;
+DELETE FROM rescan_jobs WHERE address_id IS NULL;
+ALTER TABLE rescan_jobs ALTER COLUMN address_id SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_addresses_wallet_id;
+ALTER TABLE addresses
+    DROP COLUMN IF EXISTS wallet_id,
+    DROP COLUMN IF EXISTS chain,
+    DROP COLUMN IF EXISTS derivation_index,
+    DROP COLUMN IF EXISTS used;
+
+DROP TABLE IF EXISTS wallets;
@@ -0,0 +1,8 @@
+-- +migrate Up
+ALTER TABLE transaction_outputs ADD COLUMN IF NOT EXISTS script_type text NOT NULL DEFAULT '';
+-- outputs without an address used to be stored with a placeholder
+UPDATE transaction_outputs SET address = '' WHERE address = 'unknown';
+
+-- +migrate Down
+UPDATE transaction_outputs SET address = 'unknown' WHERE address = '';
+ALTER TABLE transaction_outputs DROP COLUMN IF EXISTS script_type;
@@ -0,0 +1,14 @@
+-- +migrate Up
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS script_type text NOT NULL DEFAULT '';
+
+-- bech32 addresses are stored lowercased, unless that would duplicate one
+UPDATE addresses a SET address = lower(a.address)
+WHERE a.address <> lower(a.address)
+  AND lower(a.address) ~ '^(bc|tb|bcrt)1'
+  AND NOT EXISTS (
+      SELECT 1 FROM addresses b
+      WHERE b.user_id = a.user_id AND b.address = lower(a.address)
+  );
+
+-- +migrate Down
+ALTER TABLE addresses DROP COLUMN IF EXISTS script_type;
@@ -1,20 +1,50 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/url"
+	"os"
 	"time"
 
+	btc "github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
 	"gitlab.com/distributed_lab/figure"
 	"gitlab.com/distributed_lab/kit/comfig"
 	"gitlab.com/distributed_lab/kit/kv"
+	"gitlab.com/distributed_lab/logan/v3"
 	"gitlab.com/distributed_lab/logan/v3/errors"
 )
 
 type Bitcoin interface {
+	BlockSource() string
+	EsploraURL() string
 	NodeURL() string
 	NodeUser() string
 	NodePass() string
+	NodeRPC() btc.RPCConfig
+	Nodes() []RPCNode
+	NodeHealthInterval() time.Duration
 	IndexerPollInterval() time.Duration
+	MempoolPollInterval() time.Duration
 	StartHeight() int64
+	ZMQURL() string
+	CatchUpWorkers() int
+	HeaderBatchSize() int
+	MaxReorgDepth() int
+	FilterScan() bool
+	Network() string
+	ChainParams() *btc.ChainParams
+	Checkpoints() []btc.Checkpoint
+}
+
+// RPCNode is a bitcoind RPC endpoint with its credentials.
+type RPCNode struct {
+	URL        string
+	User       string
+	Pass       string
+	CookieFile string
+	// TLS is nil unless the node has TLS files configured.
+	TLS *tls.Config
 }
 
 type bitcoin struct {
@@ -22,12 +52,45 @@ type bitcoin struct {
 	once   comfig.Once
 }
 
+// nodeConfig is the connection to one node: the main one at the top of the
+// bitcoin section and the failover ones as entries of its nodes list.
+type nodeConfig struct {
+	URL  string `fig:"url"`
+	User string `fig:"user"`
+	Pass string `fig:"pass"`
+	// CookieFile is bitcoind's .cookie, used instead of user and pass.
+	CookieFile  string `fig:"cookie_file"`
+	TLSCAFile   string `fig:"tls_ca_file"`
+	TLSCertFile string `fig:"tls_cert_file"`
+	TLSKeyFile  string `fig:"tls_key_file"`
+}
+
 type bitcoinConfig struct {
-	URL          string        `figure:"url"`
-	User         string        `figure:"user"`
-	Pass         string        `figure:"pass"`
-	PollInterval time.Duration `figure:"poll_interval"`
-	StartHeight  int64         `figure:"start_height"`
+	Source     string `fig:"source"`
+	EsploraURL string `fig:"esplora_url"`
+	// Wallet is appended to node URLs as /wallet/<name>.
+	Wallet          string        `fig:"wallet"`
+	RPCTimeout      time.Duration `fig:"rpc_timeout"`
+	RPCRetries      int           `fig:"rpc_retries"`
+	RPCRetryBackoff time.Duration `fig:"rpc_retry_backoff"`
+	RPCMaxBatchSize int           `fig:"rpc_max_batch_size"`
+	// Nodes are the main node followed by the ones to fail over to, read
+	// from the nodes list by parseNodes.
+	Nodes               []RPCNode     `fig:"-"`
+	NodeHealthInterval  time.Duration `fig:"node_health_interval"`
+	PollInterval        time.Duration `fig:"poll_interval"`
+	MempoolPollInterval time.Duration `fig:"mempool_poll_interval"`
+	StartHeight         int64         `fig:"start_height"`
+	ZMQURL              string        `fig:"zmq_url"`
+	CatchUpWorkers      int           `fig:"catchup_workers"`
+	HeaderBatchSize     int           `fig:"header_batch_size"`
+	FilterScan          bool          `fig:"filter_scan"`
+	// MaxReorgDepth is how deep a reorg may go before the indexer halts.
+	MaxReorgDepth int `fig:"max_reorg_depth"`
+	// Network is mainnet, testnet, signet or regtest.
+	Network string `fig:"network"`
+	// Checkpoints are extra "height:hash" pairs on top of the built-in ones.
+	Checkpoints []string `fig:"checkpoints"`
 }
 
 func NewBitcoin(getter kv.Getter) Bitcoin {
@@ -45,26 +108,236 @@ func (b *bitcoin) BitcoinConfig() *bitcoinConfig {
 			panic(errors.Wrap(err, "failed to get bitcoin config"))
 		}
 
+		switch config.Source {
+		case "":
+			config.Source = btc.SourceRPC
+		case btc.SourceRPC, btc.SourceEsplora:
+		default:
+			panic(errors.From(errors.New("unknown block source"), logan.F{"source": config.Source}))
+		}
+
+		if config.Network == "" {
+			config.Network = btc.MainNetParams.Name
+		}
+		if _, err := btc.ParamsForNetwork(config.Network); err != nil {
+			panic(errors.Wrap(err, "failed to get network"))
+		}
+
+		if config.MaxReorgDepth < 0 {
+			panic(errors.From(errors.New("max_reorg_depth must not be negative"), logan.F{"max_reorg_depth": config.MaxReorgDepth}))
+		}
+
+		if config.RPCRetries < 0 {
+			panic(errors.From(errors.New("rpc_retries must not be negative"), logan.F{"rpc_retries": config.RPCRetries}))
+		}
+
+		var main nodeConfig
+		if err := figure.Out(&main).From(raw).Please(); err != nil {
+			panic(errors.Wrap(err, "failed to get bitcoin node config"))
+		}
+		if config.Nodes, err = parseNodes(main, raw["nodes"]); err != nil {
+			panic(errors.Wrap(err, "failed to parse nodes"))
+		}
+
+		if _, err := parseCheckpoints(config.Checkpoints); err != nil {
+			panic(errors.Wrap(err, "failed to parse checkpoints"))
+		}
+
 		return &config
 	}).(*bitcoinConfig)
 }
 
+func (b *bitcoin) BlockSource() string {
+	return b.BitcoinConfig().Source
+}
+
+func (b *bitcoin) EsploraURL() string {
+	return b.BitcoinConfig().EsploraURL
+}
+
 func (b *bitcoin) NodeURL() string {
-	return b.BitcoinConfig().URL
+	return b.BitcoinConfig().Nodes[0].URL
 }
 
 func (b *bitcoin) NodeUser() string {
-	return b.BitcoinConfig().User
+	return b.BitcoinConfig().Nodes[0].User
 }
 
 func (b *bitcoin) NodePass() string {
-	return b.BitcoinConfig().Pass
+	return b.BitcoinConfig().Nodes[0].Pass
+}
+
+// NodeRPC returns the client settings shared by all nodes.
+func (b *bitcoin) NodeRPC() btc.RPCConfig {
+	config := b.BitcoinConfig()
+	return btc.RPCConfig{
+		Timeout:      config.RPCTimeout,
+		Retries:      config.RPCRetries,
+		RetryBackoff: config.RPCRetryBackoff,
+		MaxBatchSize: config.RPCMaxBatchSize,
+		Wallet:       config.Wallet,
+	}
+}
+
+// Nodes returns the main node followed by the failover ones.
+func (b *bitcoin) Nodes() []RPCNode {
+	return b.BitcoinConfig().Nodes
+}
+
+func (b *bitcoin) NodeHealthInterval() time.Duration {
+	return b.BitcoinConfig().NodeHealthInterval
 }
 
 func (b *bitcoin) IndexerPollInterval() time.Duration {
 	return b.BitcoinConfig().PollInterval
 }
 
+func (b *bitcoin) MempoolPollInterval() time.Duration {
+	return b.BitcoinConfig().MempoolPollInterval
+}
+
 func (b *bitcoin) StartHeight() int64 {
 	return b.BitcoinConfig().StartHeight
 }
+
+func (b *bitcoin) ZMQURL() string {
+	return b.BitcoinConfig().ZMQURL
+}
+
+func (b *bitcoin) CatchUpWorkers() int {
+	return b.BitcoinConfig().CatchUpWorkers
+}
+
+func (b *bitcoin) HeaderBatchSize() int {
+	return b.BitcoinConfig().HeaderBatchSize
+}
+
+func (b *bitcoin) MaxReorgDepth() int {
+	return b.BitcoinConfig().MaxReorgDepth
+}
+
+func (b *bitcoin) FilterScan() bool {
+	return b.BitcoinConfig().FilterScan
+}
+
+func (b *bitcoin) Network() string {
+	return b.BitcoinConfig().Network
+}
+
+func (b *bitcoin) ChainParams() *btc.ChainParams {
+	params, _ := btc.ParamsForNetwork(b.Network())
+	return params
+}
+
+func (b *bitcoin) Checkpoints() []btc.Checkpoint {
+	checkpoints, _ := parseCheckpoints(b.BitcoinConfig().Checkpoints)
+	return checkpoints
+}
+
+func parseCheckpoints(raw []string) ([]btc.Checkpoint, error) {
+	checkpoints := make([]btc.Checkpoint, 0, len(raw))
+	for _, s := range raw {
+		cp, err := btc.ParseCheckpoint(s)
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, nil
+}
+
+// parseNodes returns main followed by the entries of the nodes list. Each
+// entry is configured like main; one without credentials of its own uses
+// the user and pass of main.
+func parseNodes(main nodeConfig, raw interface{}) ([]RPCNode, error) {
+	configs := []nodeConfig{main}
+	if raw != nil {
+		entries, ok := raw.([]interface{})
+		if !ok {
+			return nil, errors.From(errors.New("nodes must be a list"), logan.F{"nodes": raw})
+		}
+		for n, entry := range entries {
+			values, ok := toStringMap(entry)
+			if !ok {
+				return nil, errors.From(errors.New("node must be a map"), logan.F{"node": n})
+			}
+
+			var node nodeConfig
+			if err := figure.Out(&node).From(values).Please(); err != nil {
+				return nil, errors.Wrap(err, "failed to get node config", logan.F{"node": n})
+			}
+			if u, err := url.Parse(node.URL); err != nil || u.Host == "" {
+				return nil, errors.From(errors.New("invalid node url"), logan.F{"node": n})
+			}
+			if node.User == "" && node.Pass == "" && node.CookieFile == "" {
+				node.User, node.Pass = main.User, main.Pass
+			}
+			configs = append(configs, node)
+		}
+	}
+
+	nodes := make([]RPCNode, len(configs))
+	for n, c := range configs {
+		tlsConfig, err := loadTLS(c.TLSCAFile, c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load node tls config", logan.F{"node": n})
+		}
+		nodes[n] = RPCNode{
+			URL:        c.URL,
+			User:       c.User,
+			Pass:       c.Pass,
+			CookieFile: c.CookieFile,
+			TLS:        tlsConfig,
+		}
+	}
+	return nodes, nil
+}
+
+// toStringMap accepts the maps YAML decoders produce.
+func toStringMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		values := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			key, ok := k.(string)
+			if !ok {
+				return nil, false
+			}
+			values[key] = v
+		}
+		return values, true
+	default:
+		return nil, false
+	}
+}
+
+// loadTLS builds the TLS config for node connections, nil when none of the
+// files is set.
+func loadTLS(caFile, certFile, keyFile string) (*tls.Config, error) {
+	if caFile == "" && certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read ca file")
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.From(errors.New("no certificates in ca file"), logan.F{"file": caFile})
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load client certificate")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
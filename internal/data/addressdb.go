@@ -3,13 +3,46 @@ package data
 type Addressdb interface {
 	Insert(Address) error
 	Select(userID int64) ([]Address, error)
+	SelectAll() ([]Address, error)
 	GetByAddress(address string) (*Address, error)
+	GetByID(id int64) (*Address, error)
 	Get() (*Address, error)
 	GetByAddressUserID(address string, userID int64) (*Address, error)
+	SetStatus(id int64, status string) error
+	SelectByWallet(walletID int64) ([]Address, error)
+	// SetWalletStatus sets the status of every address of a wallet.
+	SetWalletStatus(walletID int64, status string) error
+	// LinkToWallet makes a tracked address the one derived at index of a
+	// wallet chain.
+	LinkToWallet(id, walletID, chain, index int64) error
+	MarkUsed(id int64) error
 }
 
+const (
+	AddressStatusActive = "active"
+	// AddressStatusSyncing means the history of the address before it was
+	// added is still being rescanned.
+	AddressStatusSyncing = "syncing"
+	// AddressStatusFailed means the rescan of the address history failed
+	// and is not retried.
+	AddressStatusFailed = "failed"
+	// AddressStatusImportFailed means seeding the UTXOs of the address
+	// failed, its balance is incomplete until the rescan finishes.
+	AddressStatusImportFailed = "import_failed"
+)
+
 type Address struct {
 	ID      int64  `db:"id"`
 	UserID  int64  `db:"user_id"`
 	Address string `db:"address"`
+	Status  string `db:"status"`
+	// ScriptType is the type of the output script the address pays to.
+	ScriptType string `db:"script_type"`
+	// WalletID, Chain and DerivationIndex are set on addresses derived
+	// from a wallet.
+	WalletID        *int64 `db:"wallet_id"`
+	Chain           *int64 `db:"chain"`
+	DerivationIndex *int64 `db:"derivation_index"`
+	// Used is set once the address received its first output.
+	Used bool `db:"used"`
 }
@@ -7,6 +7,11 @@ type BlockHeaderdb interface {
 	GetByHeight(height int64) (*BlockHeader, error)
 	GetByHash(hash string) (*BlockHeader, error)
 	GetLast() (*BlockHeader, error)
+	// GetFirst returns the lowest stored header, the one the header chain
+	// starts from.
+	GetFirst() (*BlockHeader, error)
+	// SelectRange returns the stored headers with from <= height <= to.
+	SelectRange(from, to int64) ([]BlockHeader, error)
 	DeleteAboveHeight(height int64) error
 }
 
@@ -19,4 +24,7 @@ type BlockHeader struct {
 	Timestamp      time.Time `db:"timestamp"`
 	Difficulty     int64     `db:"difficulty"`
 	Nonce          int64     `db:"nonce"`
+	Version        int32     `db:"version"`
+	Bits           string    `db:"bits"`
+	Chainwork      string    `db:"chainwork"`
 }
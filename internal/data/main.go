@@ -7,5 +7,10 @@ type MasterQ interface {
 	BlockHeader() BlockHeaderdb
 	Transaction() Transactiondb
 	UTXO() UTXOdb
+	UndoLog() UndoLogdb
+	Mempool() Mempooldb
+	SyncCursor() SyncCursordb
+	RescanJob() RescanJobdb
+	Wallet() Walletdb
 	NewTransaction(fn func() error) error
 }
@@ -0,0 +1,30 @@
+package data
+
+import (
+	"time"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/amount"
+)
+
+type Mempooldb interface {
+	Insert(MempoolTransaction) error
+	SelectByAddressID(addressID int64) ([]MempoolTransaction, error)
+	SelectTxIDs() ([]string, error)
+	DeleteByTxID(txID string) error
+}
+
+const (
+	TxStatusPending   = "pending"
+	TxStatusConfirmed = "confirmed"
+)
+
+// MempoolTransaction is an unconfirmed transaction touching a tracked address.
+// It is removed once the transaction is mined or dropped from the mempool.
+type MempoolTransaction struct {
+	ID        int64         `db:"id"`
+	TxID      string        `db:"tx_id"`
+	AddressID int64         `db:"address_id"`
+	Amount    amount.Amount `db:"amount"`
+	Direction string        `db:"direction"`
+	FirstSeen time.Time     `db:"first_seen"`
+}
@@ -19,9 +19,14 @@ type addressA struct {
 }
 
 func (a *addressA) Insert(address data.Address) error {
+	status := address.Status
+	if status == "" {
+		status = data.AddressStatusActive
+	}
+
 	query := sq.Insert("addresses").
-		Columns("user_id", "address").
-		Values(address.UserID, address.Address)
+		Columns("user_id", "address", "status", "script_type", "wallet_id", "chain", "derivation_index").
+		Values(address.UserID, address.Address, status, address.ScriptType, address.WalletID, address.Chain, address.DerivationIndex)
 
 	err := a.db.Exec(query)
 	return err
@@ -41,6 +46,19 @@ func (a *addressA) Select(userID int64) ([]data.Address, error) {
 	return addresses, nil
 }
 
+func (a *addressA) SelectAll() ([]data.Address, error) {
+	query := sq.Select("*").
+		From("addresses")
+
+	var addresses []data.Address
+	err := a.db.Select(&addresses, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return addresses, nil
+}
+
 func (a *addressA) GetByAddressUserID(address string, userID int64) (*data.Address, error) {
 	var result data.Address
 	query := sq.Select("*").From("addresses").Where(sq.Eq{"address": address, "user_id": userID})
@@ -66,6 +84,20 @@ func (a *addressA) GetByAddress(address string) (*data.Address, error) {
 	return &addr, nil
 }
 
+func (a *addressA) GetByID(id int64) (*data.Address, error) {
+	query := sq.Select("*").
+		From("addresses").
+		Where(sq.Eq{"id": id})
+
+	var addr data.Address
+	err := a.db.Get(&addr, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &addr, nil
+}
+
 func (a *addressA) Get() (*data.Address, error) {
 	var addr data.Address
 	err := a.db.Get(&addr, a.sql.Select("*").From("addresses"))
@@ -74,3 +106,56 @@ func (a *addressA) Get() (*data.Address, error) {
 	}
 	return &addr, nil
 }
+
+func (a *addressA) SetStatus(id int64, status string) error {
+	query := sq.Update("addresses").
+		Set("status", status).
+		Where(sq.Eq{"id": id})
+
+	err := a.db.Exec(query)
+	return err
+}
+
+func (a *addressA) SelectByWallet(walletID int64) ([]data.Address, error) {
+	query := sq.Select("*").
+		From("addresses").
+		Where(sq.Eq{"wallet_id": walletID}).
+		OrderBy("chain ASC", "derivation_index ASC")
+
+	var addresses []data.Address
+	err := a.db.Select(&addresses, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return addresses, nil
+}
+
+func (a *addressA) SetWalletStatus(walletID int64, status string) error {
+	query := sq.Update("addresses").
+		Set("status", status).
+		Where(sq.Eq{"wallet_id": walletID})
+
+	err := a.db.Exec(query)
+	return err
+}
+
+func (a *addressA) LinkToWallet(id, walletID, chain, index int64) error {
+	query := sq.Update("addresses").
+		Set("wallet_id", walletID).
+		Set("chain", chain).
+		Set("derivation_index", index).
+		Where(sq.Eq{"id": id})
+
+	err := a.db.Exec(query)
+	return err
+}
+
+func (a *addressA) MarkUsed(id int64) error {
+	query := sq.Update("addresses").
+		Set("used", true).
+		Where(sq.Eq{"id": id})
+
+	err := a.db.Exec(query)
+	return err
+}
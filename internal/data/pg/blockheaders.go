@@ -20,8 +20,8 @@ type blockHeaderB struct {
 
 func (b *blockHeaderB) Insert(header data.BlockHeader) error {
 	query := sq.Insert("block_headers").
-		Columns("block_hash", "previous_hash", "transaction_num", "height", "merkle_root", "timestamp", "difficulty", "nonce").
-		Values(header.BlockHash, header.PreviousHash, header.TransactionNum, header.Height, header.MerkleRoot, header.Timestamp, header.Difficulty, header.Nonce).
+		Columns("block_hash", "previous_hash", "transaction_num", "height", "merkle_root", "timestamp", "difficulty", "nonce", "version", "bits", "chainwork").
+		Values(header.BlockHash, header.PreviousHash, header.TransactionNum, header.Height, header.MerkleRoot, header.Timestamp, header.Difficulty, header.Nonce, header.Version, header.Bits, header.Chainwork).
 		PlaceholderFormat(sq.Dollar)
 
 	err := b.db.Exec(query)
@@ -52,6 +52,9 @@ func (b *blockHeaderB) GetLast() (*data.BlockHeader, error) {
 		"timestamp",
 		"difficulty",
 		"nonce",
+		"version",
+		"bits",
+		"chainwork",
 	).
 		From("block_headers").
 		OrderBy("height DESC").
@@ -72,6 +75,38 @@ func (b *blockHeaderB) GetLast() (*data.BlockHeader, error) {
 	return &header, nil
 }
 
+func (b *blockHeaderB) GetFirst() (*data.BlockHeader, error) {
+	query := sq.Select("*").
+		From("block_headers").
+		OrderBy("height ASC").
+		Limit(1).
+		PlaceholderFormat(sq.Dollar)
+
+	var header data.BlockHeader
+	err := b.db.Get(&header, query)
+	if err != nil {
+		if err.Error() == "sql: no rows in result set" {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &header, nil
+}
+
+func (b *blockHeaderB) SelectRange(from, to int64) ([]data.BlockHeader, error) {
+	query := sq.Select("*").
+		From("block_headers").
+		Where(sq.GtOrEq{"height": from}).
+		Where(sq.LtOrEq{"height": to}).
+		OrderBy("height ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	var headers []data.BlockHeader
+	err := b.db.Select(&headers, query)
+	return headers, err
+}
+
 func (b *blockHeaderB) DeleteAboveHeight(height int64) error {
 	query := sq.Delete("block_headers").
 		Where(sq.Gt{"height": height})
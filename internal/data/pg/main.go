@@ -39,6 +39,26 @@ func (m *masterQ) UTXO() data.UTXOdb {
 	return newUTXOdb(m.db)
 }
 
+func (m *masterQ) UndoLog() data.UndoLogdb {
+	return newUndoLogdb(m.db)
+}
+
+func (m *masterQ) Mempool() data.Mempooldb {
+	return newMempooldb(m.db)
+}
+
+func (m *masterQ) SyncCursor() data.SyncCursordb {
+	return newSyncCursordb(m.db)
+}
+
+func (m *masterQ) RescanJob() data.RescanJobdb {
+	return newRescanJobdb(m.db)
+}
+
+func (m *masterQ) Wallet() data.Walletdb {
+	return newWalletdb(m.db)
+}
+
 func (m *masterQ) NewTransaction(fn func() error) error {
 	return m.db.Transaction(func() error {
 		return fn()
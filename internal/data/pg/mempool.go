@@ -0,0 +1,65 @@
+package pg
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"gitlab.com/distributed_lab/kit/pgdb"
+)
+
+func newMempooldb(db *pgdb.DB) data.Mempooldb {
+	return &mempoolM{
+		db:  db,
+		sql: sq.StatementBuilder,
+	}
+}
+
+type mempoolM struct {
+	db  *pgdb.DB
+	sql sq.StatementBuilderType
+}
+
+func (m *mempoolM) Insert(tx data.MempoolTransaction) error {
+	query := sq.Insert("mempool_transactions").
+		Columns("tx_id", "address_id", "amount", "direction").
+		Values(tx.TxID, tx.AddressID, tx.Amount, tx.Direction).
+		Suffix("ON CONFLICT (tx_id, address_id) DO NOTHING")
+
+	err := m.db.Exec(query)
+	return err
+}
+
+func (m *mempoolM) SelectByAddressID(addressID int64) ([]data.MempoolTransaction, error) {
+	query := sq.Select("*").
+		From("mempool_transactions").
+		Where(sq.Eq{"address_id": addressID}).
+		OrderBy("first_seen DESC")
+
+	var txs []data.MempoolTransaction
+	err := m.db.Select(&txs, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return txs, nil
+}
+
+func (m *mempoolM) SelectTxIDs() ([]string, error) {
+	query := sq.Select("DISTINCT tx_id").
+		From("mempool_transactions")
+
+	var txIDs []string
+	err := m.db.Select(&txIDs, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return txIDs, nil
+}
+
+func (m *mempoolM) DeleteByTxID(txID string) error {
+	query := sq.Delete("mempool_transactions").
+		Where(sq.Eq{"tx_id": txID})
+
+	err := m.db.Exec(query)
+	return err
+}
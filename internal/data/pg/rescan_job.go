@@ -0,0 +1,126 @@
+package pg
+
+import (
+	"database/sql"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"gitlab.com/distributed_lab/kit/pgdb"
+)
+
+func newRescanJobdb(db *pgdb.DB) data.RescanJobdb {
+	return &rescanJobR{
+		db:  db,
+		sql: sq.StatementBuilder,
+	}
+}
+
+type rescanJobR struct {
+	db  *pgdb.DB
+	sql sq.StatementBuilderType
+}
+
+func (r *rescanJobR) Insert(job data.RescanJob) error {
+	status := job.Status
+	if status == "" {
+		status = data.RescanStatusPending
+	}
+
+	query := sq.Insert("rescan_jobs").
+		Columns("address_id", "wallet_id", "from_height", "current_height", "status").
+		Values(job.AddressID, job.WalletID, job.FromHeight, job.CurrentHeight, status)
+
+	err := r.db.Exec(query)
+	return err
+}
+
+func (r *rescanJobR) GetNext() (*data.RescanJob, error) {
+	query := sq.Select("*").
+		From("rescan_jobs").
+		Where(sq.Eq{"status": []string{data.RescanStatusPending, data.RescanStatusRunning}}).
+		OrderBy("id ASC").
+		Limit(1)
+
+	var job data.RescanJob
+	err := r.db.Get(&job, query)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (r *rescanJobR) SelectByStatus(status string) ([]data.RescanJob, error) {
+	query := sq.Select("*").
+		From("rescan_jobs").
+		Where(sq.Eq{"status": status}).
+		OrderBy("id ASC")
+
+	var jobs []data.RescanJob
+	err := r.db.Select(&jobs, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (r *rescanJobR) SelectByAddressID(addressID int64) ([]data.RescanJob, error) {
+	query := sq.Select("*").
+		From("rescan_jobs").
+		Where(sq.Eq{"address_id": addressID}).
+		OrderBy("id DESC")
+
+	var jobs []data.RescanJob
+	err := r.db.Select(&jobs, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (r *rescanJobR) SelectByWalletID(walletID int64) ([]data.RescanJob, error) {
+	query := sq.Select("*").
+		From("rescan_jobs").
+		Where(sq.Eq{"wallet_id": walletID}).
+		OrderBy("id DESC")
+
+	var jobs []data.RescanJob
+	err := r.db.Select(&jobs, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (r *rescanJobR) Update(job data.RescanJob) error {
+	query := sq.Update("rescan_jobs").
+		SetMap(map[string]interface{}{
+			"to_height":      job.ToHeight,
+			"current_height": job.CurrentHeight,
+			"status":         job.Status,
+			"error":          job.Error,
+			"updated_at":     time.Now(),
+		}).
+		Where(sq.Eq{"id": job.ID})
+
+	err := r.db.Exec(query)
+	return err
+}
+
+func (r *rescanJobR) Rewind(height int64) error {
+	query := sq.Update("rescan_jobs").
+		Set("current_height", height).
+		Set("updated_at", time.Now()).
+		Where(sq.Gt{"current_height": height}).
+		Where(sq.Eq{"status": []string{data.RescanStatusRunning, data.RescanStatusFinishing}})
+
+	err := r.db.Exec(query)
+	return err
+}
@@ -0,0 +1,48 @@
+package pg
+
+import (
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"gitlab.com/distributed_lab/kit/pgdb"
+)
+
+func newSyncCursordb(db *pgdb.DB) data.SyncCursordb {
+	return &syncCursorC{
+		db:  db,
+		sql: sq.StatementBuilder,
+	}
+}
+
+type syncCursorC struct {
+	db  *pgdb.DB
+	sql sq.StatementBuilderType
+}
+
+func (c *syncCursorC) Get(name string) (*data.SyncCursor, error) {
+	query := sq.Select("*").
+		From("sync_cursors").
+		Where(sq.Eq{"name": name})
+
+	var cursor data.SyncCursor
+	err := c.db.Get(&cursor, query)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &cursor, nil
+}
+
+func (c *syncCursorC) Set(name string, height int64) error {
+	query := sq.Insert("sync_cursors").
+		Columns("name", "height").
+		Values(name, height).
+		Suffix("ON CONFLICT (name) DO UPDATE SET height = EXCLUDED.height")
+
+	err := c.db.Exec(query)
+	return err
+}
@@ -1,9 +1,10 @@
 package pg
 
 import (
+	"database/sql"
+
 	sq "github.com/Masterminds/squirrel"
 	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
-	"github.com/lib/pq"
 	"gitlab.com/distributed_lab/kit/pgdb"
 )
 
@@ -20,9 +21,14 @@ type transactionT struct {
 }
 
 func (t *transactionT) Insert(tx data.Transaction) error {
+	merkleProof := string(tx.MerkleProof)
+	if merkleProof == "" {
+		merkleProof = "[]"
+	}
+
 	query := sq.Insert("transactions").
-		Columns("tx_id", "address_id", "amount", "block_height", "block_hash", "merkle_proof").
-		Values(tx.TxID, tx.AddressID, tx.Amount, tx.BlockHeight, tx.BlockHash, pq.Array(tx.MerkleProof))
+		Columns("tx_id", "address_id", "amount", "direction", "fee", "block_height", "block_hash", "merkle_proof").
+		Values(tx.TxID, tx.AddressID, tx.Amount, tx.Direction, tx.Fee, tx.BlockHeight, tx.BlockHash, merkleProof)
 
 	if err := t.db.Exec(query); err != nil {
 		return err
@@ -30,8 +36,8 @@ func (t *transactionT) Insert(tx data.Transaction) error {
 
 	for _, input := range tx.Inputs {
 		input_query := sq.Insert("transaction_inputs").
-			Columns("tx_id", "address", "amount", "vout_idx").
-			Values(tx.TxID, input.Address, input.Amount, input.VoutIdx)
+			Columns("tx_id", "prev_tx_id", "address", "amount", "vout_idx").
+			Values(tx.TxID, input.PrevTxID, input.Address, input.Amount, input.VoutIdx)
 
 		if err := t.db.Exec(input_query); err != nil {
 			return err
@@ -40,8 +46,8 @@ func (t *transactionT) Insert(tx data.Transaction) error {
 
 	for _, output := range tx.Outputs {
 		output_query := sq.Insert("transaction_outputs").
-			Columns("tx_id", "address", "amount", "vout_idx").
-			Values(tx.TxID, output.Address, output.Amount, output.VoutIdx)
+			Columns("tx_id", "address", "script_type", "amount", "vout_idx").
+			Values(tx.TxID, output.Address, output.ScriptType, output.Amount, output.VoutIdx)
 
 		if err := t.db.Exec(output_query); err != nil {
 			return err
@@ -80,6 +86,75 @@ func (t *transactionT) SelectByAddressID(addressID int64) ([]data.Transaction, e
 	return transactions, nil
 }
 
+func (t *transactionT) GetByTxIDAddressID(txID string, addressID int64) (*data.Transaction, error) {
+	query := sq.Select("*").
+		From("transactions").
+		Where(sq.Eq{"tx_id": txID, "address_id": addressID})
+
+	var tx data.Transaction
+	err := t.db.Get(&tx, query)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tx.Inputs, err = t.getInputsForTransaction(tx.TxID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx.Outputs, err = t.getOutputsForTransaction(tx.TxID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tx, nil
+}
+
+func (t *transactionT) GetOutput(txID string, voutIdx int64) (*data.TransactionOutput, error) {
+	query := sq.Select("*").
+		From("transaction_outputs").
+		Where(sq.Eq{"tx_id": txID, "vout_idx": voutIdx}).
+		Limit(1)
+
+	var output data.TransactionOutput
+	err := t.db.Get(&output, query)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &output, nil
+}
+
+func (t *transactionT) Delete(txID string, blockHeight int64) error {
+	query := sq.Delete("transactions").
+		Where(sq.Eq{"tx_id": txID, "block_height": blockHeight})
+
+	err := t.db.Exec(query)
+	return err
+}
+
+func (t *transactionT) DeleteInput(txID string, voutIdx int64) error {
+	query := sq.Delete("transaction_inputs").
+		Where(sq.Eq{"tx_id": txID, "vout_idx": voutIdx})
+
+	err := t.db.Exec(query)
+	return err
+}
+
+func (t *transactionT) DeleteOutput(txID string, voutIdx int64) error {
+	query := sq.Delete("transaction_outputs").
+		Where(sq.Eq{"tx_id": txID, "vout_idx": voutIdx})
+
+	err := t.db.Exec(query)
+	return err
+}
+
 func (t *transactionT) DeleteAboveHeight(height int64) error {
 	query := sq.Delete("transactions").
 		Where(sq.Gt{"block_height": height})
@@ -0,0 +1,61 @@
+package pg
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"gitlab.com/distributed_lab/kit/pgdb"
+)
+
+func newUndoLogdb(db *pgdb.DB) data.UndoLogdb {
+	return &undoLogU{
+		db:  db,
+		sql: sq.StatementBuilder,
+	}
+}
+
+type undoLogU struct {
+	db  *pgdb.DB
+	sql sq.StatementBuilderType
+}
+
+func (u *undoLogU) Insert(action data.UndoAction) error {
+	query := sq.Insert("undo_log").
+		Columns("block_height", "action", "tx_id", "vout").
+		Values(action.BlockHeight, action.Action, action.TxID, action.Vout)
+
+	err := u.db.Exec(query)
+	return err
+}
+
+// SelectByHeight returns the actions of a block newest first, so they can be
+// reversed in the order opposite to the one they were applied in.
+func (u *undoLogU) SelectByHeight(height int64) ([]data.UndoAction, error) {
+	query := sq.Select("*").
+		From("undo_log").
+		Where(sq.Eq{"block_height": height}).
+		OrderBy("id DESC")
+
+	var actions []data.UndoAction
+	err := u.db.Select(&actions, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return actions, nil
+}
+
+func (u *undoLogU) DeleteByHeight(height int64) error {
+	query := sq.Delete("undo_log").
+		Where(sq.Eq{"block_height": height})
+
+	err := u.db.Exec(query)
+	return err
+}
+
+func (u *undoLogU) DeleteBelowHeight(height int64) error {
+	query := sq.Delete("undo_log").
+		Where(sq.Lt{"block_height": height})
+
+	err := u.db.Exec(query)
+	return err
+}
@@ -1,6 +1,8 @@
 package pg
 
 import (
+	"database/sql"
+
 	sq "github.com/Masterminds/squirrel"
 	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
 	"gitlab.com/distributed_lab/kit/pgdb"
@@ -20,8 +22,8 @@ type utxoU struct {
 
 func (u *utxoU) Insert(utxo data.UTXO) error {
 	query := sq.Insert("utxos").
-		Columns("address_id", "tx_id", "vout", "amount", "block_height", "is_spent").
-		Values(utxo.AddressID, utxo.TxID, utxo.Vout, utxo.Amount, utxo.BlockHeight, utxo.IsSpent)
+		Columns("address_id", "tx_id", "vout", "amount", "block_height", "is_spent", "imported").
+		Values(utxo.AddressID, utxo.TxID, utxo.Vout, utxo.Amount, utxo.BlockHeight, utxo.IsSpent, utxo.Imported)
 
 	err := u.db.Exec(query)
 	return err
@@ -41,27 +43,79 @@ func (u *utxoU) SelectByAddressID(addressID int64) ([]data.UTXO, error) {
 	return utxos, nil
 }
 
-func (u *utxoU) MarkAsSpent(txID string, vout int64) error {
+func (u *utxoU) SelectUnspentByAddressID(addressID int64) ([]data.UTXO, error) {
+	query := sq.Select("*").
+		From("utxos").
+		Where(sq.Eq{"address_id": addressID, "is_spent": false})
+
+	var utxos []data.UTXO
+	err := u.db.Select(&utxos, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return utxos, nil
+}
+
+func (u *utxoU) GetByOutpoint(txID string, vout int64) (*data.UTXO, error) {
+	query := sq.Select("*").
+		From("utxos").
+		Where(sq.Eq{"tx_id": txID, "vout": vout})
+
+	var utxo data.UTXO
+	err := u.db.Get(&utxo, query)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &utxo, nil
+}
+
+func (u *utxoU) MarkAsSpent(txID string, vout int64, spentTxID string, spentHeight int64) error {
 	query := sq.Update("utxos").
 		Set("is_spent", true).
+		Set("spent_tx_id", spentTxID).
+		Set("spent_height", spentHeight).
 		Where(sq.Eq{"tx_id": txID, "vout": vout})
 
 	err := u.db.Exec(query)
 	return err
 }
 
-func (u *utxoU) DeleteAboveHeight(height int64) error {
-	query := sq.Delete("utxos").
-		Where(sq.Gt{"block_height": height})
+func (u *utxoU) Unspend(txID string, vout int64) error {
+	query := sq.Update("utxos").
+		Set("is_spent", false).
+		Set("spent_tx_id", nil).
+		Set("spent_height", nil).
+		Where(sq.Eq{"tx_id": txID, "vout": vout})
 
 	err := u.db.Exec(query)
 	return err
 }
 
-func (u *utxoU) UnspendByHeight(height int64) error {
+func (u *utxoU) ClearImported(txID string, vout int64) error {
 	query := sq.Update("utxos").
-		Set("is_spent", false).
-		Where(sq.Eq{"block_height": height})
+		Set("imported", false).
+		Where(sq.Eq{"tx_id": txID, "vout": vout})
+
+	err := u.db.Exec(query)
+	return err
+}
+
+func (u *utxoU) DeleteByOutpoint(txID string, vout int64) error {
+	query := sq.Delete("utxos").
+		Where(sq.Eq{"tx_id": txID, "vout": vout})
+
+	err := u.db.Exec(query)
+	return err
+}
+
+func (u *utxoU) DeleteAboveHeight(height int64) error {
+	query := sq.Delete("utxos").
+		Where(sq.Gt{"block_height": height})
 
 	err := u.db.Exec(query)
 	return err
@@ -0,0 +1,76 @@
+package pg
+
+import (
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"gitlab.com/distributed_lab/kit/pgdb"
+)
+
+func newWalletdb(db *pgdb.DB) data.Walletdb {
+	return &walletW{
+		db:  db,
+		sql: sq.StatementBuilder,
+	}
+}
+
+type walletW struct {
+	db  *pgdb.DB
+	sql sq.StatementBuilderType
+}
+
+func (w *walletW) Insert(wallet data.Wallet) error {
+	query := sq.Insert("wallets").
+		Columns("user_id", "descriptor", "gap_limit").
+		Values(wallet.UserID, wallet.Descriptor, wallet.GapLimit)
+
+	err := w.db.Exec(query)
+	return err
+}
+
+func (w *walletW) Select(userID int64) ([]data.Wallet, error) {
+	query := sq.Select("*").
+		From("wallets").
+		Where(sq.Eq{"user_id": userID}).
+		OrderBy("id ASC")
+
+	var wallets []data.Wallet
+	err := w.db.Select(&wallets, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return wallets, nil
+}
+
+func (w *walletW) GetByID(id int64) (*data.Wallet, error) {
+	query := sq.Select("*").
+		From("wallets").
+		Where(sq.Eq{"id": id})
+
+	var wallet data.Wallet
+	err := w.db.Get(&wallet, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wallet, nil
+}
+
+func (w *walletW) GetByDescriptorUserID(descriptor string, userID int64) (*data.Wallet, error) {
+	query := sq.Select("*").
+		From("wallets").
+		Where(sq.Eq{"descriptor": descriptor, "user_id": userID})
+
+	var wallet data.Wallet
+	err := w.db.Get(&wallet, query)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &wallet, nil
+}
@@ -0,0 +1,42 @@
+package data
+
+import "time"
+
+type RescanJobdb interface {
+	Insert(RescanJob) error
+	// GetNext returns the oldest job the background worker still has to
+	// scan, or nil.
+	GetNext() (*RescanJob, error)
+	SelectByStatus(status string) ([]RescanJob, error)
+	SelectByAddressID(addressID int64) ([]RescanJob, error)
+	SelectByWalletID(walletID int64) ([]RescanJob, error)
+	Update(RescanJob) error
+	// Rewind moves unfinished jobs that scanned past height back to it.
+	Rewind(height int64) error
+}
+
+const (
+	RescanStatusPending = "pending"
+	RescanStatusRunning = "running"
+	// RescanStatusFinishing means the job is a few blocks behind the block
+	// cursor and the indexer scans the rest itself, in step with live sync.
+	RescanStatusFinishing = "finishing"
+	RescanStatusDone      = "done"
+	RescanStatusFailed    = "failed"
+)
+
+// RescanJob scans the blocks from FromHeight up to the block cursor for the
+// history of an address, or of all addresses of a wallet, added after their
+// first activity. CurrentHeight is the last block scanned.
+type RescanJob struct {
+	ID            int64     `db:"id"`
+	AddressID     *int64    `db:"address_id"`
+	WalletID      *int64    `db:"wallet_id"`
+	FromHeight    int64     `db:"from_height"`
+	ToHeight      *int64    `db:"to_height"`
+	CurrentHeight int64     `db:"current_height"`
+	Status        string    `db:"status"`
+	Error         *string   `db:"error"`
+	CreatedAt     time.Time `db:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at"`
+}
@@ -0,0 +1,16 @@
+package data
+
+type SyncCursordb interface {
+	// Get returns nil if the cursor has not been set yet.
+	Get(name string) (*SyncCursor, error)
+	Set(name string, height int64) error
+}
+
+// CursorBlocks is the last block whose transactions have been indexed. Headers
+// are synced ahead of it and block_headers holds the whole validated chain.
+const CursorBlocks = "blocks"
+
+type SyncCursor struct {
+	Name   string `db:"name"`
+	Height int64  `db:"height"`
+}
@@ -3,14 +3,29 @@ package data
 import (
 	"encoding/json"
 	"time"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/amount"
 )
 
 type Transactiondb interface {
 	Insert(tx Transaction) error
 	SelectByAddressID(addressID int64) ([]Transaction, error)
+	GetByTxIDAddressID(txID string, addressID int64) (*Transaction, error)
+	GetOutput(txID string, voutIdx int64) (*TransactionOutput, error)
+	Delete(txID string, blockHeight int64) error
+	DeleteInput(txID string, voutIdx int64) error
+	DeleteOutput(txID string, voutIdx int64) error
 	DeleteAboveHeight(height int64) error
 }
 
+const (
+	TxDirectionIncoming = "incoming"
+	TxDirectionOutgoing = "outgoing"
+)
+
+// MerkleNode is a sibling on the path from a transaction to the merkle root,
+// hex in the same reversed byte order as txids. IsLeft is set when the sibling
+// is hashed in on the left.
 type MerkleNode struct {
 	Hash   string `json:"hash"`
 	IsLeft bool   `json:"is_left"`
@@ -20,7 +35,9 @@ type Transaction struct {
 	ID          int64               `db:"id"`
 	TxID        string              `db:"tx_id"`
 	AddressID   *int64              `db:"address_id"`
-	Amount      int64               `db:"amount"`
+	Amount      amount.Amount       `db:"amount"`
+	Direction   string              `db:"direction"`
+	Fee         *amount.Amount      `db:"fee"`
 	BlockHeight int64               `db:"block_height"`
 	BlockHash   string              `db:"block_hash"`
 	MerkleProof json.RawMessage     `db:"merkle_proof"`
@@ -30,18 +47,21 @@ type Transaction struct {
 }
 
 type TransactionInput struct {
-	ID       int64   `db:"id"`
-	TxID     string  `db:"tx_id"`
-	PrevTxID *string `db:"prev_tx_id"`
-	VoutIdx  uint32  `db:"vout_idx"`
-	Address  string  `db:"address"`
-	Amount   int64   `db:"amount"`
+	ID       int64         `db:"id"`
+	TxID     string        `db:"tx_id"`
+	PrevTxID *string       `db:"prev_tx_id"`
+	VoutIdx  uint32        `db:"vout_idx"`
+	Address  string        `db:"address"`
+	Amount   amount.Amount `db:"amount"`
 }
 
+// TransactionOutput is an output of an indexed transaction. Address is empty
+// for scripts without one, ScriptType tells what they are.
 type TransactionOutput struct {
-	ID      int64  `db:"id"`
-	TxID    string `db:"tx_id"`
-	Address string `db:"address"`
-	Amount  int64  `db:"amount"`
-	VoutIdx uint32 `db:"vout_idx"`
+	ID         int64         `db:"id"`
+	TxID       string        `db:"tx_id"`
+	Address    string        `db:"address"`
+	ScriptType string        `db:"script_type"`
+	Amount     amount.Amount `db:"amount"`
+	VoutIdx    uint32        `db:"vout_idx"`
 }
@@ -0,0 +1,29 @@
+package data
+
+import "time"
+
+type UndoLogdb interface {
+	Insert(UndoAction) error
+	SelectByHeight(height int64) ([]UndoAction, error)
+	DeleteByHeight(height int64) error
+	// DeleteBelowHeight drops the actions of blocks below height, which can
+	// no longer be rolled back.
+	DeleteBelowHeight(height int64) error
+}
+
+const (
+	UndoCreateUTXO        = "create_utxo"
+	UndoSpendUTXO         = "spend_utxo"
+	UndoInsertTransaction = "insert_transaction"
+	UndoInsertTxInput     = "insert_tx_input"
+	UndoInsertTxOutput    = "insert_tx_output"
+)
+
+type UndoAction struct {
+	ID          int64     `db:"id"`
+	BlockHeight int64     `db:"block_height"`
+	Action      string    `db:"action"`
+	TxID        string    `db:"tx_id"`
+	Vout        int64     `db:"vout"`
+	CreatedAt   time.Time `db:"created_at"`
+}
@@ -1,21 +1,33 @@
 package data
 
+import "github.com/Myrtilli/transaction-indexing-svc/internal/amount"
+
 type UTXOdb interface {
 	Insert(utxo UTXO) error
 	SelectByAddressID(addressID int64) ([]UTXO, error)
-	MarkAsSpent(txID string, vout int64) error
+	SelectUnspentByAddressID(addressID int64) ([]UTXO, error)
+	GetByOutpoint(txID string, vout int64) (*UTXO, error)
+	MarkAsSpent(txID string, vout int64, spentTxID string, spentHeight int64) error
+	Unspend(txID string, vout int64) error
+	// ClearImported marks an imported UTXO as seen in its block.
+	ClearImported(txID string, vout int64) error
+	DeleteByOutpoint(txID string, vout int64) error
 	DeleteAboveHeight(height int64) error
-	UnspendByHeight(height int64) error
 	FilterByHeight(height int64) UTXOdb
 	Get() (*UTXO, error)
 }
 
 type UTXO struct {
-	ID          int64  `db:"id"`
-	AddressID   int64  `db:"address_id"`
-	TxID        string `db:"tx_id"`
-	Vout        int64  `db:"vout"`
-	Amount      int64  `db:"amount"`
-	BlockHeight int64  `db:"block_height"`
-	IsSpent     bool   `db:"is_spent"`
+	ID          int64         `db:"id"`
+	AddressID   int64         `db:"address_id"`
+	TxID        string        `db:"tx_id"`
+	Vout        int64         `db:"vout"`
+	Amount      amount.Amount `db:"amount"`
+	BlockHeight int64         `db:"block_height"`
+	IsSpent     bool          `db:"is_spent"`
+	SpentTxID   *string       `db:"spent_tx_id"`
+	SpentHeight *int64        `db:"spent_height"`
+	// Imported is set for UTXOs seeded from the node's UTXO set, until the
+	// block that created them is indexed.
+	Imported bool `db:"imported"`
 }
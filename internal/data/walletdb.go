@@ -0,0 +1,25 @@
+package data
+
+import "time"
+
+type Walletdb interface {
+	Insert(Wallet) error
+	Select(userID int64) ([]Wallet, error)
+	GetByID(id int64) (*Wallet, error)
+	// GetByDescriptorUserID returns nil if the user has no such wallet.
+	GetByDescriptorUserID(descriptor string, userID int64) (*Wallet, error)
+}
+
+// DefaultGapLimit is how many unused addresses are kept derived past the last
+// used one on each chain, as BIP44 recommends.
+const DefaultGapLimit = 20
+
+// Wallet derives addresses from an output descriptor over an extended public
+// key. The derived addresses are stored in addresses with WalletID set.
+type Wallet struct {
+	ID         int64     `db:"id"`
+	UserID     int64     `db:"user_id"`
+	Descriptor string    `db:"descriptor"`
+	GapLimit   int64     `db:"gap_limit"`
+	CreatedAt  time.Time `db:"created_at"`
+}
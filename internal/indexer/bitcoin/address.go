@@ -0,0 +1,336 @@
+package bitcoin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Script opcodes used to build standard output scripts.
+const (
+	opDup         = 0x76
+	opHash160     = 0xa9
+	opEqual       = 0x87
+	opEqualVerify = 0x88
+	opCheckSig    = 0xac
+	op0           = 0x00
+	op1           = 0x51
+)
+
+const (
+	base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	bech32Charset  = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+// Base58Check version bytes of P2PKH and P2SH addresses.
+const (
+	versionMainPubKeyHash = 0x00
+	versionMainScriptHash = 0x05
+	versionTestPubKeyHash = 0x6f
+	versionTestScriptHash = 0xc4
+)
+
+var ErrInvalidAddress = errors.New("invalid address")
+
+// Address is an address decoded for a network.
+type Address struct {
+	// Canonical is the form the address is stored in, bech32 addresses
+	// are lowercased.
+	Canonical  string
+	Script     []byte
+	ScriptType string
+}
+
+// DecodeAddress decodes a Base58Check or bech32/bech32m address and checks
+// that it belongs to the network. Errors wrap ErrInvalidAddress and say what
+// is wrong with the address.
+func (p *ChainParams) DecodeAddress(address string) (*Address, error) {
+	if address == "" {
+		return nil, fmt.Errorf("%w: empty", ErrInvalidAddress)
+	}
+
+	lower := strings.ToLower(address)
+	if sep := strings.LastIndexByte(lower, '1'); sep > 0 && isSegwitHRP(lower[:sep]) {
+		hrp := lower[:sep]
+		if hrp != p.Bech32HRP {
+			return nil, fmt.Errorf("%w: bech32 prefix %q is not used on %s, expected %q", ErrInvalidAddress, hrp, p.Name, p.Bech32HRP)
+		}
+
+		version, program, err := decodeSegwit(address, hrp)
+		if err != nil {
+			return nil, err
+		}
+		script := witnessScript(version, program)
+		return &Address{
+			Canonical:  lower,
+			Script:     script,
+			ScriptType: ClassifyScript(script),
+		}, nil
+	}
+
+	payload, err := decodeBase58Check(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) != 21 {
+		return nil, fmt.Errorf("%w: unexpected payload length %d", ErrInvalidAddress, len(payload))
+	}
+
+	var script []byte
+	switch version, hash := payload[0], payload[1:]; {
+	case version == p.PubKeyHashAddrID:
+		script = append(append([]byte{opDup, opHash160, 20}, hash...), opEqualVerify, opCheckSig)
+	case version == p.ScriptHashAddrID:
+		script = append(append([]byte{opHash160, 20}, hash...), opEqual)
+	case isBase58Version(version):
+		return nil, fmt.Errorf("%w: version byte %#x is not used on %s", ErrInvalidAddress, version, p.Name)
+	default:
+		return nil, fmt.Errorf("%w: unknown version byte %#x", ErrInvalidAddress, version)
+	}
+
+	return &Address{
+		Canonical:  address,
+		Script:     script,
+		ScriptType: ClassifyScript(script),
+	}, nil
+}
+
+// AddressToScript returns the scriptPubKey an address pays to. Base58Check
+// P2PKH/P2SH and bech32/bech32m segwit addresses of any network are accepted.
+func AddressToScript(address string) ([]byte, error) {
+	if hrp, _, ok := strings.Cut(strings.ToLower(address), "1"); ok && isSegwitHRP(hrp) {
+		version, program, err := decodeSegwit(address, hrp)
+		if err != nil {
+			return nil, err
+		}
+		return witnessScript(version, program), nil
+	}
+
+	payload, err := decodeBase58Check(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) != 21 {
+		return nil, fmt.Errorf("%w: unexpected payload length %d", ErrInvalidAddress, len(payload))
+	}
+
+	hash := payload[1:]
+	switch payload[0] {
+	case versionMainPubKeyHash, versionTestPubKeyHash:
+		return append(append([]byte{opDup, opHash160, 20}, hash...), opEqualVerify, opCheckSig), nil
+	case versionMainScriptHash, versionTestScriptHash:
+		return append(append([]byte{opHash160, 20}, hash...), opEqual), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown version byte %#x", ErrInvalidAddress, payload[0])
+	}
+}
+
+func isBase58Version(version byte) bool {
+	switch version {
+	case versionMainPubKeyHash, versionMainScriptHash, versionTestPubKeyHash, versionTestScriptHash:
+		return true
+	}
+	return false
+}
+
+func isSegwitHRP(hrp string) bool {
+	switch hrp {
+	case "bc", "tb", "bcrt":
+		return true
+	}
+	return false
+}
+
+func witnessScript(version byte, program []byte) []byte {
+	script := make([]byte, 0, 2+len(program))
+	if version == 0 {
+		script = append(script, op0)
+	} else {
+		script = append(script, op1+version-1)
+	}
+	script = append(script, byte(len(program)))
+	return append(script, program...)
+}
+
+func encodeBase58Check(payload []byte) string {
+	hash := DoubleSHA256(payload)
+	data := append(append([]byte{}, payload...), hash[:4]...)
+
+	n := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for k := 0; k < len(data) && data[k] == 0; k++ {
+		out = append(out, base58Alphabet[0])
+	}
+
+	for a, b := 0, len(out)-1; a < b; a, b = a+1, b-1 {
+		out[a], out[b] = out[b], out[a]
+	}
+	return string(out)
+}
+
+func decodeBase58Check(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range []byte(s) {
+		digit := strings.IndexByte(base58Alphabet, c)
+		if digit < 0 {
+			return nil, fmt.Errorf("%w: bad base58 character %q", ErrInvalidAddress, c)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(digit)))
+	}
+
+	decoded := n.Bytes()
+	for k := 0; k < len(s) && s[k] == base58Alphabet[0]; k++ {
+		decoded = append([]byte{0}, decoded...)
+	}
+	if len(decoded) < 4 {
+		return nil, fmt.Errorf("%w: too short", ErrInvalidAddress)
+	}
+
+	payload, checksum := decoded[:len(decoded)-4], decoded[len(decoded)-4:]
+	hash := DoubleSHA256(payload)
+	if !bytes.Equal(hash[:4], checksum) {
+		return nil, fmt.Errorf("%w: bad checksum", ErrInvalidAddress)
+	}
+	return payload, nil
+}
+
+// decodeSegwit decodes a BIP173/BIP350 address, checking that version 0 uses
+// bech32 and later versions bech32m.
+func decodeSegwit(address, hrp string) (byte, []byte, error) {
+	if strings.ToLower(address) != address && strings.ToUpper(address) != address {
+		return 0, nil, fmt.Errorf("%w: mixed case", ErrInvalidAddress)
+	}
+	address = strings.ToLower(address)
+
+	data := address[len(hrp)+1:]
+	if len(data) < 7 || len(address) > 90 {
+		return 0, nil, fmt.Errorf("%w: bad length", ErrInvalidAddress)
+	}
+
+	values := make([]byte, len(data))
+	for k, c := range []byte(data) {
+		v := strings.IndexByte(bech32Charset, c)
+		if v < 0 {
+			return 0, nil, fmt.Errorf("%w: bad bech32 character %q", ErrInvalidAddress, c)
+		}
+		values[k] = byte(v)
+	}
+
+	checksum := bech32Polymod(append(bech32ExpandHRP(hrp), values...))
+	values = values[:len(values)-6]
+	if len(values) == 0 {
+		return 0, nil, fmt.Errorf("%w: missing witness version", ErrInvalidAddress)
+	}
+
+	version := values[0]
+	switch {
+	case version == 0 && checksum != bech32Const,
+		version > 0 && checksum != bech32mConst:
+		return 0, nil, fmt.Errorf("%w: bad checksum", ErrInvalidAddress)
+	case version > 16:
+		return 0, nil, fmt.Errorf("%w: bad witness version", ErrInvalidAddress)
+	}
+
+	program, err := convertBits(values[1:], 5, 8, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(program) < 2 || len(program) > 40 ||
+		(version == 0 && len(program) != 20 && len(program) != 32) {
+		return 0, nil, fmt.Errorf("%w: bad witness program length %d", ErrInvalidAddress, len(program))
+	}
+
+	return version, program, nil
+}
+
+// encodeSegwit encodes a witness program as a BIP173/BIP350 address.
+func encodeSegwit(hrp string, version byte, program []byte) (string, error) {
+	values, err := convertBits(program, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	values = append([]byte{version}, values...)
+
+	checksumConst := uint32(bech32Const)
+	if version > 0 {
+		checksumConst = bech32mConst
+	}
+	polymod := bech32Polymod(append(append(bech32ExpandHRP(hrp), values...), 0, 0, 0, 0, 0, 0)) ^ checksumConst
+	for k := 0; k < 6; k++ {
+		values = append(values, byte(polymod>>(5*(5-k))&31))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, v := range values {
+		sb.WriteByte(bech32Charset[v])
+	}
+	return sb.String(), nil
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for k := 0; k < 5; k++ {
+			if (top>>k)&1 == 1 {
+				chk ^= generator[k]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32ExpandHRP(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for _, c := range []byte(hrp) {
+		expanded = append(expanded, c>>5)
+	}
+	expanded = append(expanded, 0)
+	for _, c := range []byte(hrp) {
+		expanded = append(expanded, c&31)
+	}
+	return expanded
+}
+
+func convertBits(data []byte, from, to uint, pad bool) ([]byte, error) {
+	var (
+		acc  uint32
+		bits uint
+		out  []byte
+	)
+	maxv := uint32(1)<<to - 1
+	for _, v := range data {
+		acc = acc<<from | uint32(v)
+		bits += from
+		for bits >= to {
+			bits -= to
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(to-bits)&maxv))
+		}
+	} else if bits >= from || acc<<(to-bits)&maxv != 0 {
+		return nil, fmt.Errorf("%w: bad padding", ErrInvalidAddress)
+	}
+	return out, nil
+}
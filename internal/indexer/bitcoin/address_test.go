@@ -0,0 +1,178 @@
+package bitcoin
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// segwitVectors are the valid BIP350 test vectors whose prefix is used by a
+// network, with the scriptPubKey they pay to.
+var segwitVectors = []struct {
+	address string
+	script  string
+}{
+	{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", "0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+	{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"},
+	{"bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y", "5128751e76e8199196d454941c45d1b3a323f1433bd6751e76e8199196d454941c45d1b3a323f1433bd6"},
+	{"BC1SW50QGDZ25J", "6002751e"},
+	{"bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs", "5210751e76e8199196d454941c45d1b3a323"},
+	{"tb1qqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesrxh6hy", "0020000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+	{"tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c", "5120000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+	{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+}
+
+func TestDecodeSegwitAddress(t *testing.T) {
+	for _, tt := range segwitVectors {
+		params := &MainNetParams
+		if strings.HasPrefix(strings.ToLower(tt.address), "tb") {
+			params = &TestNetParams
+		}
+
+		addr, err := params.DecodeAddress(tt.address)
+		if err != nil {
+			t.Errorf("%s: %v", tt.address, err)
+			continue
+		}
+		if hex.EncodeToString(addr.Script) != tt.script {
+			t.Errorf("%s: script = %x, want %s", tt.address, addr.Script, tt.script)
+		}
+		if addr.Canonical != strings.ToLower(tt.address) {
+			t.Errorf("%s: canonical form = %s", tt.address, addr.Canonical)
+		}
+
+		script, err := AddressToScript(tt.address)
+		if err != nil || hex.EncodeToString(script) != tt.script {
+			t.Errorf("%s: AddressToScript = %x, %v, want %s", tt.address, script, err, tt.script)
+		}
+
+		version, program := addr.Script[0], addr.Script[2:]
+		if version != 0 {
+			version -= op1 - 1
+		}
+		encoded, err := encodeSegwit(params.Bech32HRP, version, program)
+		if err != nil || encoded != addr.Canonical {
+			t.Errorf("%s: encoded back as %s, %v", tt.address, encoded, err)
+		}
+	}
+}
+
+func TestDecodeSegwitAddressRejectsInvalid(t *testing.T) {
+	// the invalid BIP173/BIP350 test vectors with a prefix of a network
+	tests := []struct {
+		address string
+		reason  string
+	}{
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd", "v1 with a bech32 checksum"},
+		{"tb1z0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqglt7rf", "v2 with a bech32 checksum"},
+		{"BC1S0XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ54WELL", "v16 with a bech32 checksum"},
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh", "v0 with a bech32m checksum"},
+		{"tb1q0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq24jc47", "v0 with a bech32m checksum"},
+		{"bc1p38j9r5y49hruaue7wxjce0updqjuyyx0kh56v8s25huc6995vvpql3jow4", "bad character in the checksum"},
+		{"BC130XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ7ZWS8R", "witness version 17"},
+		{"bc1pw5dgrnzv", "1 byte program"},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v8n0nx0muaewav253zgeav", "41 byte program"},
+		{"BC1QR508D6QEJXTDG4Y5R3ZARVARYV98GJ9P", "16 byte v0 program"},
+		{"tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq47Zagq", "mixed case"},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v07qwwzcrf", "more than 4 bits of padding"},
+		{"tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vpggkg4j", "non-zero padding"},
+		{"bc1gmk9yu", "empty data"},
+		{"bc1zw508d6qejxtdg4y5r3zarvaryvaxxpct", "wrong checksum"},
+	}
+
+	for _, tt := range tests {
+		params := &MainNetParams
+		if strings.HasPrefix(strings.ToLower(tt.address), "tb") {
+			params = &TestNetParams
+		}
+		if _, err := params.DecodeAddress(tt.address); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("%s (%s): err = %v, want ErrInvalidAddress", tt.address, tt.reason, err)
+		}
+		if _, err := AddressToScript(tt.address); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("%s (%s): AddressToScript err = %v, want ErrInvalidAddress", tt.address, tt.reason, err)
+		}
+	}
+}
+
+func TestDecodeBase58Address(t *testing.T) {
+	tests := []struct {
+		address string
+		params  *ChainParams
+		script  string
+		typ     string
+	}{
+		{"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", &MainNetParams, "76a91477bff20c60e522dfaa3350c39b030a5d004e839a88ac", ScriptTypePubKeyHash},
+		{"3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", &MainNetParams, "a914b472a266d0bd89c13706a4132ccfb16f7c3b9fcb87", ScriptTypeScriptHash},
+		{"mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn", &TestNetParams, "76a914243f1394f44554f4ce3fd68649c19adc483ce92488ac", ScriptTypePubKeyHash},
+		{"2MzQwSSnBHWHqSAqtTVQ6v47XtaisrJa1Vc", &RegTestParams, "a9144e9f39ca4688ff102128ea4ccda34105324305b087", ScriptTypeScriptHash},
+	}
+
+	for _, tt := range tests {
+		addr, err := tt.params.DecodeAddress(tt.address)
+		if err != nil {
+			t.Errorf("%s: %v", tt.address, err)
+			continue
+		}
+		if hex.EncodeToString(addr.Script) != tt.script || addr.ScriptType != tt.typ || addr.Canonical != tt.address {
+			t.Errorf("%s: decoded as %+v", tt.address, addr)
+		}
+		if script, err := AddressToScript(tt.address); err != nil || hex.EncodeToString(script) != tt.script {
+			t.Errorf("%s: AddressToScript = %x, %v", tt.address, script, err)
+		}
+	}
+
+	invalid := []struct {
+		address string
+		reason  string
+	}{
+		{"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN3", "bad checksum"},
+		{"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN", "truncated"},
+		{"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN0", "bad base58 character"},
+		// a 20 byte payload with version byte 0x01
+		{encodeBase58Check(append([]byte{0x01}, make([]byte, 20)...)), "unknown version"},
+		{encodeBase58Check(make([]byte, 22)), "payload too long"},
+	}
+	for _, tt := range invalid {
+		if _, err := MainNetParams.DecodeAddress(tt.address); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("%s (%s): err = %v, want ErrInvalidAddress", tt.address, tt.reason, err)
+		}
+	}
+}
+
+func TestDecodeAddressRejectsOtherNetworks(t *testing.T) {
+	const (
+		mainP2PKH  = "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"
+		mainP2SH   = "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy"
+		mainSegwit = "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"
+		testP2PKH  = "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn"
+		testP2SH   = "2MzQwSSnBHWHqSAqtTVQ6v47XtaisrJa1Vc"
+		testSegwit = "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7"
+		regSegwit  = "bcrt1qw508d6qejxtdg4y5r3zarvary0c5xw7kygt080"
+	)
+
+	tests := []struct {
+		params  *ChainParams
+		valid   []string
+		invalid []string
+	}{
+		{&MainNetParams, []string{mainP2PKH, mainP2SH, mainSegwit}, []string{testP2PKH, testP2SH, testSegwit, regSegwit}},
+		{&TestNetParams, []string{testP2PKH, testP2SH, testSegwit}, []string{mainP2PKH, mainP2SH, mainSegwit, regSegwit}},
+		{&SigNetParams, []string{testP2PKH, testP2SH, testSegwit}, []string{mainP2PKH, mainP2SH, mainSegwit, regSegwit}},
+		// regtest shares the base58 versions of testnet
+		{&RegTestParams, []string{testP2PKH, testP2SH, regSegwit}, []string{mainP2PKH, mainP2SH, mainSegwit, testSegwit}},
+	}
+
+	for _, tt := range tests {
+		for _, address := range tt.valid {
+			if _, err := tt.params.DecodeAddress(address); err != nil {
+				t.Errorf("%s on %s: %v", address, tt.params.Name, err)
+			}
+		}
+		for _, address := range tt.invalid {
+			if _, err := tt.params.DecodeAddress(address); !errors.Is(err, ErrInvalidAddress) {
+				t.Errorf("%s on %s: err = %v, want ErrInvalidAddress", address, tt.params.Name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package bitcoin
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/amount"
+)
+
+// btcAmount is the form amounts take in the node's JSON. It is decoded as a
+// json.Number so no value goes through a float64.
+type btcAmount amount.Amount
+
+func (a *btcAmount) UnmarshalJSON(b []byte) error {
+	var num json.Number
+	if err := json.Unmarshal(b, &num); err != nil {
+		return fmt.Errorf("%w: %v", amount.ErrInvalid, err)
+	}
+	value, err := amount.Parse(num.String())
+	if err != nil {
+		return err
+	}
+	*a = btcAmount(value)
+	return nil
+}
@@ -0,0 +1,55 @@
+package bitcoin
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/amount"
+)
+
+func TestBTCAmountUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want amount.Amount
+		err  bool
+	}{
+		{in: `0.1`, want: 10_000_000},
+		{in: `0.30000000`, want: 30_000_000},
+		{in: `1e-8`, want: 1},
+		{in: `0.00000001`, want: 1},
+		{in: `20999999.9769`, want: 2_099_999_997_690_000},
+		{in: `-0.5`, want: -50_000_000},
+		{in: `"0.5"`, want: 50_000_000},
+
+		{in: `0.123456789`, err: true},
+		{in: `21000000.00000001`, err: true},
+		{in: `"abc"`, err: true},
+		{in: `true`, err: true},
+		{in: `null`, err: true},
+	}
+
+	for _, tt := range tests {
+		var got btcAmount
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if tt.err {
+			if !errors.Is(err, amount.ErrInvalid) {
+				t.Errorf("unmarshal %s = %d, %v, want ErrInvalid", tt.in, got, err)
+			}
+			continue
+		}
+		if err != nil || amount.Amount(got) != tt.want {
+			t.Errorf("unmarshal %s = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestTxOutputValueIsExact(t *testing.T) {
+	var out TxOutput
+	if err := json.Unmarshal([]byte(`{"value": 0.29999999, "n": 1}`), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Value != 29_999_999 {
+		t.Errorf("value = %d, want 29999999", out.Value)
+	}
+}
@@ -0,0 +1,205 @@
+package bitcoin
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// BatchSource is implemented by backends that answer many queries in one
+// round trip. Results are in request order. errs[n] is set for an item the
+// backend answered with an error, such as ErrBlockNotFound; err means the
+// batch as a whole failed.
+type BatchSource interface {
+	MaxBatchSize() int
+	GetBlockHashes(ctx context.Context, heights []int64) (hashes []string, errs []error, err error)
+	GetBlockHeaders(ctx context.Context, hashes []string) (headers []*BlockHeader, errs []error, err error)
+	GetTxOutProofs(ctx context.Context, txids []string, blockhash string) (proofs [][]byte, errs []error, err error)
+}
+
+var _ BatchSource = (*RPCClient)(nil)
+
+// BatchSize is the number of items worth asking src for at once, 1 for a
+// source without batches.
+func BatchSize(src BlockSource) int {
+	if batch, ok := src.(BatchSource); ok {
+		return batch.MaxBatchSize()
+	}
+	return 1
+}
+
+// GetBlockHashes returns the hashes of the blocks at heights, batched when
+// src supports it. Heights src has no block at get an ErrBlockNotFound in
+// errs, any other failure is returned as err.
+func GetBlockHashes(ctx context.Context, src BlockSource, heights []int64) ([]string, []error, error) {
+	if batch, ok := src.(BatchSource); ok {
+		return batch.GetBlockHashes(ctx, heights)
+	}
+	return eachItem(heights, ErrBlockNotFound, func(height int64) (string, error) {
+		return src.GetBlockHash(ctx, height)
+	})
+}
+
+// GetBlockHeaders returns the headers of the blocks with hashes, batched
+// when src supports it.
+func GetBlockHeaders(ctx context.Context, src BlockSource, hashes []string) ([]*BlockHeader, []error, error) {
+	if batch, ok := src.(BatchSource); ok {
+		return batch.GetBlockHeaders(ctx, hashes)
+	}
+	return eachItem(hashes, ErrBlockNotFound, func(hash string) (*BlockHeader, error) {
+		return src.GetBlockHeader(ctx, hash)
+	})
+}
+
+// GetTxOutProofs returns a proof per transaction of txids that they are in
+// the block, batched when src supports it.
+func GetTxOutProofs(ctx context.Context, src BlockSource, txids []string, blockhash string) ([][]byte, []error, error) {
+	if batch, ok := src.(BatchSource); ok {
+		return batch.GetTxOutProofs(ctx, txids, blockhash)
+	}
+	return eachItem(txids, ErrTxNotFound, func(txid string) ([]byte, error) {
+		return src.GetTxOutProof(ctx, txid, blockhash)
+	})
+}
+
+// eachItem queries keys one by one. Errors matching notFound are answers
+// about a single item, anything else stops the loop.
+func eachItem[K, T any](keys []K, notFound error, get func(K) (T, error)) ([]T, []error, error) {
+	res := make([]T, len(keys))
+	errs := make([]error, len(keys))
+	for n, key := range keys {
+		var err error
+		res[n], err = get(key)
+		if errors.Is(err, notFound) {
+			errs[n] = err
+		} else if err != nil {
+			return nil, nil, err
+		}
+	}
+	return res, errs, nil
+}
+
+func (c *RPCClient) MaxBatchSize() int {
+	return c.Config.MaxBatchSize
+}
+
+func (c *RPCClient) GetBlockHashes(ctx context.Context, heights []int64) ([]string, []error, error) {
+	params := make([][]any, len(heights))
+	for n, height := range heights {
+		params[n] = []any{height}
+	}
+	return callBatch[string](ctx, c, "getblockhash", params)
+}
+
+func (c *RPCClient) GetBlockHeaders(ctx context.Context, hashes []string) ([]*BlockHeader, []error, error) {
+	params := make([][]any, len(hashes))
+	for n, hash := range hashes {
+		params[n] = []any{hash}
+	}
+	return callBatch[*BlockHeader](ctx, c, "getblockheader", params)
+}
+
+func (c *RPCClient) GetTxOutProofs(ctx context.Context, txids []string, blockhash string) ([][]byte, []error, error) {
+	params := make([][]any, len(txids))
+	for n, txid := range txids {
+		params[n] = []any{[]string{txid}, blockhash}
+	}
+	proofHexes, errs, err := callBatch[string](ctx, c, "gettxoutproof", params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proofs := make([][]byte, len(txids))
+	for n, proofHex := range proofHexes {
+		if errs[n] != nil {
+			continue
+		}
+		if proofs[n], err = hex.DecodeString(proofHex); err != nil {
+			errs[n] = err
+		}
+	}
+	return proofs, errs, nil
+}
+
+// callBatch runs method once per entry of params, in JSON-RPC batches of at
+// most MaxBatchSize calls, and decodes each result into a T.
+func callBatch[T any](ctx context.Context, c *RPCClient, method string, params [][]any) ([]T, []error, error) {
+	results := make([]T, len(params))
+	errs := make([]error, len(params))
+
+	for start := 0; start < len(params); start += c.Config.MaxBatchSize {
+		end := min(start+c.Config.MaxBatchSize, len(params))
+
+		raw, err := c.callBatch(ctx, method, params[start:end])
+		if err != nil {
+			return nil, nil, err
+		}
+		for n, item := range raw {
+			if item.Error != nil {
+				errs[start+n] = fmt.Errorf("%s: %w", method, item.Error)
+				continue
+			}
+			if err := json.Unmarshal(item.Result, &results[start+n]); err != nil {
+				errs[start+n] = fmt.Errorf("failed to decode %s result: %w", method, err)
+			}
+		}
+	}
+	return results, errs, nil
+}
+
+// callBatch sends one batch and returns the responses in request order.
+// Items are matched by id, as the node may answer them in any order.
+func (c *RPCClient) callBatch(ctx context.Context, method string, params [][]any) ([]rpcResponse, error) {
+	reqs := make([]rpcRequest, len(params))
+	for n, p := range params {
+		reqs[n] = rpcRequest{JSONRPC: "1.0", ID: n, Method: method, Params: p}
+	}
+	body, err := json.Marshal(reqs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s batch: %w", method, err)
+	}
+
+	res := make([]rpcResponse, len(params))
+	err = c.retry(ctx, method+" batch", func() error {
+		status, respBody, err := c.post(ctx, body)
+		if err != nil {
+			return err
+		}
+
+		var items []rpcResponse
+		if err := json.Unmarshal(respBody, &items); err != nil {
+			// a request the node refused as a whole, such as during warmup,
+			// is answered with a single response
+			_, err := decodeResponse(status, respBody)
+			if err == nil {
+				err = fmt.Errorf("malformed batch response: %q", truncate(respBody, 200))
+			}
+			return err
+		}
+		if status != http.StatusOK {
+			return &statusError{Status: status, Body: strings.TrimSpace(truncate(respBody, 200))}
+		}
+
+		seen := make([]bool, len(params))
+		for _, item := range items {
+			id, ok := item.ID.(float64)
+			n := int(id)
+			if !ok || float64(n) != id || n < 0 || n >= len(params) || seen[n] {
+				return fmt.Errorf("unexpected batch response id %v", item.ID)
+			}
+			seen[n] = true
+			res[n] = item
+		}
+		for n := range seen {
+			if !seen[n] {
+				return fmt.Errorf("no batch response for id %d", n)
+			}
+		}
+		return nil
+	})
+	return res, err
+}
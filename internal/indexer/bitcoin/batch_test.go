@@ -0,0 +1,119 @@
+package bitcoin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchNode answers getblockhash batches for a chain of tip+1 blocks, in
+// reverse order, and refuses the first batch as a whole while warming up.
+type batchNode struct {
+	*httptest.Server
+	tip int64
+
+	mu      sync.Mutex
+	batches []int
+}
+
+func newBatchNode(t *testing.T, tip int64) *batchNode {
+	t.Helper()
+	node := &batchNode{tip: tip}
+	node.Server = httptest.NewServer(http.HandlerFunc(node.serve))
+	t.Cleanup(node.Close)
+	return node
+}
+
+func (b *batchNode) serve(w http.ResponseWriter, r *http.Request) {
+	var reqs []struct {
+		ID     int     `json:"id"`
+		Method string  `json:"method"`
+		Params []int64 `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	b.mu.Lock()
+	b.batches = append(b.batches, len(reqs))
+	first := len(b.batches) == 1
+	b.mu.Unlock()
+
+	if first {
+		writeRPCAnswer(w, rpcAnswer{
+			status: http.StatusInternalServerError,
+			err:    &RPCError{Code: RPCErrInWarmup, Message: "Loading block index..."},
+		}, nil)
+		return
+	}
+
+	resps := make([]map[string]any, 0, len(reqs))
+	for _, req := range slices.Backward(reqs) {
+		resp := map[string]any{"id": req.ID, "result": nil, "error": nil}
+		if height := req.Params[0]; height > b.tip {
+			resp["error"] = &RPCError{Code: RPCErrInvalidParameter, Message: "Block height out of range"}
+		} else {
+			resp["result"] = testBlockHash(height)
+		}
+		resps = append(resps, resp)
+	}
+	json.NewEncoder(w).Encode(resps)
+}
+
+func testBlockHash(height int64) string {
+	return fmt.Sprintf("%064x", height)
+}
+
+func TestGetBlockHashesBatches(t *testing.T) {
+	node := newBatchNode(t, 3)
+	c := NewRPCClient(node.URL, "user", "pass", RPCConfig{
+		Retries:      1,
+		RetryBackoff: time.Millisecond,
+		MaxBatchSize: 2,
+	})
+
+	heights := []int64{0, 1, 2, 3, 4}
+	hashes, errs, err := GetBlockHashes(context.Background(), c, heights)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the refused batch is sent again, then the rest in batches of two
+	if want := []int{2, 2, 2, 1}; !slices.Equal(node.batches, want) {
+		t.Errorf("batch sizes = %v, want %v", node.batches, want)
+	}
+	for n, height := range heights {
+		if height > node.tip {
+			if !errors.Is(errs[n], ErrBlockNotFound) {
+				t.Errorf("height %d: err = %v, want ErrBlockNotFound", height, errs[n])
+			}
+			continue
+		}
+		if errs[n] != nil || hashes[n] != testBlockHash(height) {
+			t.Errorf("height %d: hash = %s, %v, want %s", height, hashes[n], errs[n], testBlockHash(height))
+		}
+	}
+}
+
+func TestGetBlockHashesRejectsUnknownIDs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]any{
+			{"id": 0, "result": testBlockHash(0)},
+			{"id": 7, "result": testBlockHash(1)},
+		})
+	}))
+	defer server.Close()
+	c := NewRPCClient(server.URL, "user", "pass", RPCConfig{})
+
+	if _, _, err := c.GetBlockHashes(context.Background(), []int64{0, 1}); err == nil {
+		t.Error("batch answered with an id that was not asked for was accepted")
+	}
+}
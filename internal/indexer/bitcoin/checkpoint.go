@@ -0,0 +1,69 @@
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrCheckpointMismatch means a block conflicts with a checkpoint.
+var ErrCheckpointMismatch = errors.New("block does not match checkpoint")
+
+// Checkpoint pins the hash of the block at a height.
+type Checkpoint struct {
+	Height int64
+	Hash   string
+}
+
+// ParseCheckpoint parses a checkpoint written as "height:hash".
+func ParseCheckpoint(s string) (Checkpoint, error) {
+	height, hash, ok := strings.Cut(s, ":")
+	if !ok {
+		return Checkpoint{}, fmt.Errorf("checkpoint %q is not in height:hash form", s)
+	}
+
+	h, err := strconv.ParseInt(strings.TrimSpace(height), 10, 64)
+	if err != nil || h < 0 {
+		return Checkpoint{}, fmt.Errorf("invalid checkpoint height %q", height)
+	}
+
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	if _, err := HashFromHex(hash); err != nil {
+		return Checkpoint{}, fmt.Errorf("invalid checkpoint hash %q: %w", hash, err)
+	}
+
+	return Checkpoint{Height: h, Hash: hash}, nil
+}
+
+// MergeCheckpoints combines checkpoint lists into one ordered by height.
+// Two checkpoints at the same height must agree.
+func MergeCheckpoints(lists ...[]Checkpoint) ([]Checkpoint, error) {
+	byHeight := make(map[int64]string)
+	for _, list := range lists {
+		for _, cp := range list {
+			if hash, ok := byHeight[cp.Height]; ok && hash != cp.Hash {
+				return nil, fmt.Errorf("conflicting checkpoints at height %d", cp.Height)
+			}
+			byHeight[cp.Height] = cp.Hash
+		}
+	}
+
+	merged := make([]Checkpoint, 0, len(byHeight))
+	for height, hash := range byHeight {
+		merged = append(merged, Checkpoint{Height: height, Hash: hash})
+	}
+	sort.Slice(merged, func(a, b int) bool { return merged[a].Height < merged[b].Height })
+	return merged, nil
+}
+
+// LastCheckpoint returns the highest checkpoint at or below height, or nil.
+// checkpoints must be ordered by height.
+func LastCheckpoint(checkpoints []Checkpoint, height int64) *Checkpoint {
+	n := sort.Search(len(checkpoints), func(k int) bool { return checkpoints[k].Height > height })
+	if n == 0 {
+		return nil
+	}
+	return &checkpoints[n-1]
+}
@@ -0,0 +1,59 @@
+package bitcoin
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// cookieAuth holds the credentials bitcoind writes to its .cookie file. The
+// node makes up new ones on every start, so they are re-read when it rejects
+// the current ones.
+type cookieAuth struct {
+	path string
+
+	mu   sync.Mutex
+	user string
+	pass string
+}
+
+// credentials returns the cached credentials, reading the file the first
+// time.
+func (a *cookieAuth) credentials() (string, string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.user == "" {
+		if err := a.load(); err != nil {
+			return "", "", err
+		}
+	}
+	return a.user, a.pass, nil
+}
+
+// reload re-reads the file and reports whether the credentials changed since
+// user was sent.
+func (a *cookieAuth) reload(user, pass string) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.load(); err != nil {
+		return false, err
+	}
+	return a.user != user || a.pass != pass, nil
+}
+
+func (a *cookieAuth) load() error {
+	raw, err := os.ReadFile(a.path)
+	if err != nil {
+		return fmt.Errorf("failed to read rpc cookie: %w", err)
+	}
+
+	user, pass, ok := strings.Cut(strings.TrimSpace(string(raw)), ":")
+	if !ok || user == "" {
+		return fmt.Errorf("malformed rpc cookie %s", a.path)
+	}
+	a.user, a.pass = user, pass
+	return nil
+}
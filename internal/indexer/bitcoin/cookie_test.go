@@ -0,0 +1,151 @@
+package bitcoin
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRPCRereadsCookieAfterNodeRestart(t *testing.T) {
+	cookie := filepath.Join(t.TempDir(), ".cookie")
+	writeCookie := func(pass string) {
+		if err := os.WriteFile(cookie, []byte("__cookie__:"+pass), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		mu   sync.Mutex
+		pass = "first"
+		sent []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		user, got, _ := r.BasicAuth()
+		sent = append(sent, got)
+		if user != "__cookie__" || got != pass {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeRPCAnswer(w, rpcAnswer{result: 100}, "indexer")
+	}))
+	defer server.Close()
+
+	writeCookie("first")
+	c := NewRPCClient(server.URL, "", "", RPCConfig{CookieFile: cookie})
+	ctx := context.Background()
+	if _, err := c.GetBlockCount(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the node restarts with a new cookie
+	mu.Lock()
+	pass = "second"
+	mu.Unlock()
+	writeCookie("second")
+	if _, err := c.GetBlockCount(ctx); err != nil {
+		t.Fatalf("call after the cookie changed: %v", err)
+	}
+
+	// credentials the node rejects are not sent over and over
+	mu.Lock()
+	pass = "third"
+	mu.Unlock()
+	if _, err := c.GetBlockCount(ctx); err == nil {
+		t.Error("call with a stale cookie succeeded")
+	}
+
+	want := []string{"first", "first", "second", "second"}
+	if !slices.Equal(sent, want) {
+		t.Errorf("passwords sent = %v, want %v", sent, want)
+	}
+}
+
+func TestRPCUsesTLSPerNode(t *testing.T) {
+	first, firstCA := newTLSNode(t)
+	second, secondCA := newTLSNode(t)
+
+	pool := NewNodePool(
+		NewRPCClient(first.URL, "user", "pass", RPCConfig{TLS: &tls.Config{RootCAs: firstCA}}),
+		NewRPCClient(second.URL, "user", "pass", RPCConfig{TLS: &tls.Config{RootCAs: secondCA}}),
+	)
+	for _, status := range pool.CheckHealth(context.Background()).Nodes {
+		if !status.Healthy {
+			t.Errorf("node %s over its own tls config: %v", status.Node, status.Err)
+		}
+	}
+
+	c := NewRPCClient(second.URL, "user", "pass", RPCConfig{TLS: &tls.Config{RootCAs: firstCA}})
+	if _, err := c.GetBlockCount(context.Background()); err == nil {
+		t.Error("node certificate accepted under the CA of another node")
+	}
+}
+
+// newTLSNode starts an https node with a certificate of its own, returned as
+// the pool to trust it with.
+func newTLSNode(t *testing.T) (*httptest.Server, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "bitcoind"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Method == "getblockchaininfo" {
+			writeRPCAnswer(w, rpcAnswer{result: BlockchainInfo{Chain: "main", Chainwork: "01"}}, "indexer")
+			return
+		}
+		writeRPCAnswer(w, rpcAnswer{result: 0}, "indexer")
+	}))
+	// the handshake another node's CA fails is expected
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return server, pool
+}
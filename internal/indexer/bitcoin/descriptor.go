@@ -0,0 +1,239 @@
+package bitcoin
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Output types of ranged descriptors: BIP44, BIP49, BIP84 and BIP86
+// accounts respectively.
+const (
+	DescriptorPKH    = "pkh"
+	DescriptorSHWPKH = "sh(wpkh)"
+	DescriptorWPKH   = "wpkh"
+	DescriptorTR     = "tr"
+)
+
+var ErrInvalidDescriptor = errors.New("invalid descriptor")
+
+// Descriptor is a ranged output descriptor over a single extended public
+// key, such as wpkh(xpub/<0;1>/*).
+type Descriptor struct {
+	Type string
+	Key  *ExtendedKey
+	// Path is derived from Key ahead of the chain.
+	Path []uint32
+	// Chains are the receive and change branches, empty when the key
+	// expression ends right in /*.
+	Chains []uint32
+}
+
+// ParseDescriptor parses a pkh, sh(wpkh), wpkh or tr descriptor with a ranged
+// extended public key. A bare xpub/ypub/zpub (or tpub/upub/vpub) is read as
+// an account key with receive and change chains, the script taken from its
+// version bytes. A trailing #checksum is dropped without being checked, the
+// keys themselves are Base58Check protected.
+func ParseDescriptor(s string) (*Descriptor, error) {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "#")
+
+	if !strings.Contains(s, "(") {
+		key, err := ParseExtendedKey(s)
+		if err != nil {
+			return nil, err
+		}
+		return &Descriptor{
+			Type:   extendedKeyVersions[key.Version].Type,
+			Key:    key,
+			Chains: []uint32{0, 1},
+		}, nil
+	}
+
+	for _, typ := range []string{DescriptorSHWPKH, DescriptorPKH, DescriptorWPKH, DescriptorTR} {
+		open := strings.ReplaceAll(typ, ")", "") + "("
+		closing := strings.Repeat(")", strings.Count(open, "("))
+		if !strings.HasPrefix(s, open) || !strings.HasSuffix(s, closing) {
+			continue
+		}
+
+		desc, err := parseKeyExpression(s[len(open) : len(s)-len(closing)])
+		if err != nil {
+			return nil, err
+		}
+		desc.Type = typ
+		return desc, nil
+	}
+
+	return nil, fmt.Errorf("%w: unsupported script, expected pkh, sh(wpkh), wpkh or tr", ErrInvalidDescriptor)
+}
+
+// parseKeyExpression parses [origin]xpub/path/*. The origin is informational
+// and dropped.
+func parseKeyExpression(expr string) (*Descriptor, error) {
+	if strings.HasPrefix(expr, "[") {
+		end := strings.IndexByte(expr, ']')
+		if end < 0 {
+			return nil, fmt.Errorf("%w: unterminated key origin", ErrInvalidDescriptor)
+		}
+		expr = expr[end+1:]
+	}
+
+	parts := strings.Split(expr, "/")
+	key, err := ParseExtendedKey(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	steps := parts[1:]
+	if len(steps) == 0 || steps[len(steps)-1] != "*" {
+		return nil, fmt.Errorf("%w: key must be ranged with a trailing /*", ErrInvalidDescriptor)
+	}
+	steps = steps[:len(steps)-1]
+
+	desc := &Descriptor{Key: key}
+	for n, step := range steps {
+		if strings.HasPrefix(step, "<") && strings.HasSuffix(step, ">") {
+			if n != len(steps)-1 {
+				return nil, fmt.Errorf("%w: multipath must come right before /*", ErrInvalidDescriptor)
+			}
+			for _, branch := range strings.Split(step[1:len(step)-1], ";") {
+				index, err := parsePathStep(branch)
+				if err != nil {
+					return nil, err
+				}
+				desc.Chains = append(desc.Chains, index)
+			}
+			continue
+		}
+
+		index, err := parsePathStep(step)
+		if err != nil {
+			return nil, err
+		}
+		if n == len(steps)-1 {
+			desc.Chains = []uint32{index}
+		} else {
+			desc.Path = append(desc.Path, index)
+		}
+	}
+
+	return desc, nil
+}
+
+func parsePathStep(step string) (uint32, error) {
+	if strings.HasSuffix(step, "'") || strings.HasSuffix(step, "h") {
+		return 0, ErrHardenedDerivation
+	}
+	index, err := strconv.ParseUint(step, 10, 32)
+	if err != nil || index >= HardenedKeyStart {
+		return 0, fmt.Errorf("%w: bad derivation step %q", ErrInvalidDescriptor, step)
+	}
+	return uint32(index), nil
+}
+
+// ChainCount is the number of address chains, 2 for receive and change.
+func (d *Descriptor) ChainCount() int {
+	if len(d.Chains) == 0 {
+		return 1
+	}
+	return len(d.Chains)
+}
+
+// Address derives the address at index of the chain-th chain.
+func (d *Descriptor) Address(params *ChainParams, chain int, index uint32) (string, error) {
+	path := append([]uint32{}, d.Path...)
+	if len(d.Chains) > 0 {
+		path = append(path, d.Chains[chain])
+	}
+
+	key, err := d.Key.Derive(append(path, index)...)
+	if err != nil {
+		return "", err
+	}
+	return params.keyAddress(d.Type, key.PubKey)
+}
+
+// String returns the descriptor in canonical form, without a checksum.
+func (d *Descriptor) String() string {
+	var sb strings.Builder
+	sb.WriteString(d.Key.String())
+	for _, index := range d.Path {
+		fmt.Fprintf(&sb, "/%d", index)
+	}
+	switch len(d.Chains) {
+	case 0:
+	case 1:
+		fmt.Fprintf(&sb, "/%d", d.Chains[0])
+	default:
+		branches := make([]string, len(d.Chains))
+		for n, index := range d.Chains {
+			branches[n] = strconv.FormatUint(uint64(index), 10)
+		}
+		fmt.Fprintf(&sb, "/<%s>", strings.Join(branches, ";"))
+	}
+	sb.WriteString("/*")
+
+	if d.Type == DescriptorSHWPKH {
+		return "sh(wpkh(" + sb.String() + "))"
+	}
+	return d.Type + "(" + sb.String() + ")"
+}
+
+// keyAddress returns the address of the output of the given descriptor type
+// paying to pubKey.
+func (p *ChainParams) keyAddress(typ string, pubKey []byte) (string, error) {
+	switch typ {
+	case DescriptorPKH:
+		return encodeBase58Check(append([]byte{p.PubKeyHashAddrID}, Hash160(pubKey)...)), nil
+	case DescriptorSHWPKH:
+		redeem := witnessScript(0, Hash160(pubKey))
+		return encodeBase58Check(append([]byte{p.ScriptHashAddrID}, Hash160(redeem)...)), nil
+	case DescriptorWPKH:
+		return encodeSegwit(p.Bech32HRP, 0, Hash160(pubKey))
+	case DescriptorTR:
+		outputKey, err := taprootOutputKey(pubKey)
+		if err != nil {
+			return "", err
+		}
+		return encodeSegwit(p.Bech32HRP, 1, outputKey)
+	default:
+		return "", fmt.Errorf("%w: unsupported type %q", ErrInvalidDescriptor, typ)
+	}
+}
+
+// taprootOutputKey tweaks the internal key with an empty script tree, as
+// BIP86 specifies.
+func taprootOutputKey(pubKey []byte) ([]byte, error) {
+	if len(pubKey) != 33 {
+		return nil, ErrInvalidPubKey
+	}
+	xOnly := pubKey[1:]
+
+	internal, err := liftX(new(big.Int).SetBytes(xOnly), false)
+	if err != nil {
+		return nil, err
+	}
+
+	tweak := new(big.Int).SetBytes(taggedHash("TapTweak", xOnly))
+	if tweak.Cmp(curveN) >= 0 {
+		return nil, ErrUnusableChild
+	}
+	output := curveG.mul(tweak).add(internal)
+	if output == nil {
+		return nil, ErrUnusableChild
+	}
+	return output.x.FillBytes(make([]byte, 32)), nil
+}
+
+// taggedHash is the BIP340 SHA256(SHA256(tag) || SHA256(tag) || msg).
+func taggedHash(tag string, msg []byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	h.Write(msg)
+	return h.Sum(nil)
+}
@@ -0,0 +1,133 @@
+package bitcoin
+
+import (
+	"errors"
+	"testing"
+)
+
+// descriptorVectors are the account keys and first addresses of BIP84, BIP49
+// and BIP86. The BIP49 vector is a testnet tpub, given here with the upub
+// version bytes a wallet exports it with.
+var descriptorVectors = []struct {
+	name       string
+	descriptor string
+	params     *ChainParams
+	typ        string
+	// addresses by chain and index
+	addresses map[[2]int]string
+}{
+	{
+		name:       "BIP84 zpub",
+		descriptor: "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs",
+		params:     &MainNetParams,
+		typ:        DescriptorWPKH,
+		addresses: map[[2]int]string{
+			{0, 0}: "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu",
+			{0, 1}: "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g",
+			{1, 0}: "bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el",
+		},
+	},
+	{
+		name:       "BIP49 upub",
+		descriptor: "upub5EFU65HtV5TeiSHmZZm7FUffBGy8UKeqp7vw43jYbvZPpoVsgU93oac7Wk3u6moKegAEWtGNF8DehrnHtv21XXEMYRUocHqguyjknFHYfgY",
+		params:     &TestNetParams,
+		typ:        DescriptorSHWPKH,
+		addresses: map[[2]int]string{
+			{0, 0}: "2Mww8dCYPUpKHofjgcXcBCEGmniw9CoaiD2",
+		},
+	},
+	{
+		name:       "BIP86 tr",
+		descriptor: "tr(xpub6BgBgsespWvERF3LHQu6CnqdvfEvtMcQjYrcRzx53QJjSxarj2afYWcLteoGVky7D3UKDP9QyrLprQ3VCECoY49yfdDEHGCtMMj92pReUsQ/<0;1>/*)",
+		params:     &MainNetParams,
+		typ:        DescriptorTR,
+		addresses: map[[2]int]string{
+			{0, 0}: "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr",
+			{0, 1}: "bc1p4qhjn9zdvkux4e44uhx8tc55attvtyu358kutcqkudyccelu0was9fqzwh",
+			{1, 0}: "bc1p3qkhfews2uk44qtvauqyr2ttdsw7svhkl9nkm9s9c3x4ax5h60wqwruhk7",
+		},
+	},
+}
+
+func TestDescriptorAddress(t *testing.T) {
+	for _, tt := range descriptorVectors {
+		desc, err := ParseDescriptor(tt.descriptor)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if desc.Type != tt.typ || desc.ChainCount() != 2 {
+			t.Errorf("%s: parsed as %s with %d chains", tt.name, desc.Type, desc.ChainCount())
+		}
+		if err := desc.Key.CheckNetwork(tt.params); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+
+		for at, want := range tt.addresses {
+			got, err := desc.Address(tt.params, at[0], uint32(at[1]))
+			if err != nil || got != want {
+				t.Errorf("%s %d/%d: address = %s, %v, want %s", tt.name, at[0], at[1], got, err, want)
+			}
+		}
+	}
+}
+
+func TestParseDescriptor(t *testing.T) {
+	const xpub = "xpub6BgBgsespWvERF3LHQu6CnqdvfEvtMcQjYrcRzx53QJjSxarj2afYWcLteoGVky7D3UKDP9QyrLprQ3VCECoY49yfdDEHGCtMMj92pReUsQ"
+
+	tests := []struct {
+		in string
+		// canonical is the expected String, empty when in is rejected
+		canonical string
+		err       error
+	}{
+		{in: "wpkh(" + xpub + "/<0;1>/*)#8nqzyvg3", canonical: "wpkh(" + xpub + "/<0;1>/*)"},
+		{in: "sh(wpkh([d34db33f/49'/0'/0']" + xpub + "/0/*))", canonical: "sh(wpkh(" + xpub + "/0/*))"},
+		{in: "pkh(" + xpub + "/2/<0;1>/*)", canonical: "pkh(" + xpub + "/2/<0;1>/*)"},
+		{in: xpub, canonical: "pkh(" + xpub + "/<0;1>/*)"},
+
+		{in: "wpkh(" + xpub + "/0'/*)", err: ErrHardenedDerivation},
+		{in: "wpkh(" + xpub + "/0h/*)", err: ErrHardenedDerivation},
+		{in: "wpkh(" + xpub + "/*')", err: ErrInvalidDescriptor},
+		{in: "wpkh(" + xpub + "/0)", err: ErrInvalidDescriptor},
+		{in: "wpkh(" + xpub + "/<0;1>/0/*)", err: ErrInvalidDescriptor},
+		{in: "wsh(" + xpub + "/*)", err: ErrInvalidDescriptor},
+		{in: "wpkh(" + xpub[:len(xpub)-1] + "R/*)", err: ErrInvalidExtendedKey},
+	}
+
+	for _, tt := range tests {
+		desc, err := ParseDescriptor(tt.in)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: err = %v, want %v", tt.in, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if desc.String() != tt.canonical {
+			t.Errorf("%s: canonical form = %s, want %s", tt.in, desc.String(), tt.canonical)
+		}
+	}
+}
+
+func TestDescriptorKeyNetwork(t *testing.T) {
+	mainnet, err := ParseDescriptor(descriptorVectors[0].descriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testnet, err := ParseDescriptor(descriptorVectors[1].descriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, params := range []*ChainParams{&TestNetParams, &SigNetParams, &RegTestParams} {
+		if err := mainnet.Key.CheckNetwork(params); !errors.Is(err, ErrKeyNetworkMismatch) {
+			t.Errorf("zpub on %s = %v, want ErrKeyNetworkMismatch", params.Name, err)
+		}
+	}
+	if err := testnet.Key.CheckNetwork(&MainNetParams); !errors.Is(err, ErrKeyNetworkMismatch) {
+		t.Errorf("upub on mainnet = %v, want ErrKeyNetworkMismatch", err)
+	}
+}
@@ -0,0 +1,251 @@
+package bitcoin
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/amount"
+)
+
+// esploraTxsPerPage is the fixed page size of /block/:hash/txs/:start_index.
+const esploraTxsPerPage = 25
+
+// EsploraClient reads the chain from an Esplora-style REST API, such as the
+// ones run by Blockstream and mempool.space.
+type EsploraClient struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewEsploraClient(url string) *EsploraClient {
+	return &EsploraClient{
+		URL:    strings.TrimRight(url, "/"),
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type esploraBlock struct {
+	ID                string  `json:"id"`
+	Height            int64   `json:"height"`
+	Version           int32   `json:"version"`
+	Timestamp         int64   `json:"timestamp"`
+	TxCount           int64   `json:"tx_count"`
+	MerkleRoot        string  `json:"merkle_root"`
+	PreviousBlockHash string  `json:"previousblockhash"`
+	Nonce             uint32  `json:"nonce"`
+	Bits              uint32  `json:"bits"`
+	Difficulty        float64 `json:"difficulty"`
+}
+
+type esploraOutput struct {
+	ScriptPubKey        string `json:"scriptpubkey"`
+	ScriptPubKeyAddress string `json:"scriptpubkey_address"`
+	Value               int64  `json:"value"`
+}
+
+type esploraTx struct {
+	TxID string `json:"txid"`
+	Vin  []struct {
+		TxID       string         `json:"txid"`
+		Vout       int64          `json:"vout"`
+		IsCoinbase bool           `json:"is_coinbase"`
+		Prevout    *esploraOutput `json:"prevout"`
+	} `json:"vin"`
+	Vout []esploraOutput `json:"vout"`
+}
+
+func (c *EsploraClient) GetBlockCount(ctx context.Context) (int64, error) {
+	body, err := c.get(ctx, "/blocks/tip/height")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(body), 10, 64)
+}
+
+func (c *EsploraClient) GetBlockHash(ctx context.Context, height int64) (string, error) {
+	body, err := c.get(ctx, fmt.Sprintf("/block-height/%d", height))
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+func (c *EsploraClient) GetBlockHeader(ctx context.Context, hash string) (*BlockHeader, error) {
+	var block esploraBlock
+	if err := c.getJSON(ctx, "/block/"+hash, &block); err != nil {
+		return nil, err
+	}
+
+	return &BlockHeader{
+		BlockHash:      block.ID,
+		Version:        block.Version,
+		PreviousHash:   block.PreviousBlockHash,
+		MerkleRoot:     block.MerkleRoot,
+		Timestamp:      block.Timestamp,
+		Bits:           fmt.Sprintf("%08x", block.Bits),
+		Nonce:          block.Nonce,
+		Height:         block.Height,
+		TransactionNum: block.TxCount,
+		Difficulty:     block.Difficulty,
+	}, nil
+}
+
+func (c *EsploraClient) GetBlock(ctx context.Context, hash string) ([]Transaction, error) {
+	var block esploraBlock
+	if err := c.getJSON(ctx, "/block/"+hash, &block); err != nil {
+		return nil, err
+	}
+
+	txs := make([]Transaction, 0, block.TxCount)
+	for start := int64(0); start < block.TxCount; start += esploraTxsPerPage {
+		var page []esploraTx
+		if err := c.getJSON(ctx, fmt.Sprintf("/block/%s/txs/%d", hash, start), &page); err != nil {
+			return nil, err
+		}
+		for _, tx := range page {
+			txs = append(txs, tx.toTransaction())
+		}
+	}
+
+	return txs, nil
+}
+
+// GetTxOutProof returns the proof against the block the API has the
+// transaction in, which after a reorg may not be blockhash. Such a proof is
+// reported as ErrBlockNotFound.
+func (c *EsploraClient) GetTxOutProof(ctx context.Context, txid, blockhash string) ([]byte, error) {
+	body, err := c.get(ctx, "/tx/"+txid+"/merkleblock-proof")
+	if err != nil {
+		return nil, err
+	}
+	proof, err := hex.DecodeString(string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	mb, err := ParseMerkleBlock(proof)
+	if err != nil {
+		return nil, err
+	}
+	if got := HashToHex(DoubleSHA256(mb.Header)); got != blockhash {
+		return nil, fmt.Errorf("%w: proof of %s is for block %s, not %s", ErrBlockNotFound, txid, got, blockhash)
+	}
+	return proof, nil
+}
+
+func (c *EsploraClient) GetRawTransaction(ctx context.Context, txid string) (*Transaction, error) {
+	var tx esploraTx
+	if err := c.getJSON(ctx, "/tx/"+txid, &tx); err != nil {
+		return nil, err
+	}
+
+	res := tx.toTransaction()
+	return &res, nil
+}
+
+func (c *EsploraClient) GetRawMempool(ctx context.Context) ([]string, error) {
+	var txids []string
+	err := c.getJSON(ctx, "/mempool/txids", &txids)
+	return txids, err
+}
+
+func (c *EsploraClient) getJSON(ctx context.Context, path string, result any) error {
+	body, err := c.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, result)
+}
+
+func (c *EsploraClient) get(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &esploraError{Path: path, Status: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+
+	return body, nil
+}
+
+// esploraError is a non-200 response. A 404 is the API's answer for unknown
+// blocks and transactions.
+type esploraError struct {
+	Path   string
+	Status int
+	Body   string
+}
+
+func (e *esploraError) Error() string {
+	return fmt.Sprintf("esplora %s returned %d: %s", e.Path, e.Status, e.Body)
+}
+
+func (e *esploraError) Is(target error) bool {
+	if e.Status != http.StatusNotFound {
+		return false
+	}
+	if strings.HasPrefix(e.Path, "/tx/") {
+		return target == ErrTxNotFound
+	}
+	return target == ErrBlockNotFound
+}
+
+func (tx esploraTx) toTransaction() Transaction {
+	res := Transaction{
+		TxID:    tx.TxID,
+		Inputs:  make([]TxInput, len(tx.Vin)),
+		Outputs: make([]TxOutput, len(tx.Vout)),
+	}
+
+	for n, in := range tx.Vin {
+		if in.IsCoinbase {
+			res.Inputs[n] = TxInput{Coinbase: "coinbase"}
+			continue
+		}
+
+		res.Inputs[n] = TxInput{
+			PrevTxID: in.TxID,
+			Vout:     in.Vout,
+		}
+		if in.Prevout != nil {
+			res.Inputs[n].Prevout = &Prevout{
+				Value: amount.Amount(in.Prevout.Value),
+				ScriptPubKey: ScriptPubKey{
+					Hex:     in.Prevout.ScriptPubKey,
+					Address: in.Prevout.ScriptPubKeyAddress,
+				},
+			}
+		}
+	}
+
+	for n, out := range tx.Vout {
+		res.Outputs[n] = TxOutput{
+			Value: amount.Amount(out.Value),
+			Vout:  int64(n),
+			ScriptPubKey: ScriptPubKey{
+				Hex:     out.ScriptPubKey,
+				Address: out.ScriptPubKeyAddress,
+			},
+		}
+	}
+
+	return res
+}
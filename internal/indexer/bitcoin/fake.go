@@ -0,0 +1,173 @@
+package bitcoin
+
+import (
+	"context"
+	"sync"
+)
+
+type FakeBlock struct {
+	Header BlockHeader
+	Txs    []Transaction
+}
+
+// FakeChain is an in-memory BlockSource for running the indexer without a
+// node. Blocks are scripted with SetBlock, replacing a block at an existing
+// height drops everything above it, like a reorg on a real node does.
+type FakeChain struct {
+	mu      sync.Mutex
+	blocks  []FakeBlock
+	heights map[string]int64
+	mempool map[string]Transaction
+	// Proofs, if set, replaces the proofs built from the scripted blocks
+	Proofs func(block FakeBlock, txid string) ([]byte, error)
+}
+
+func NewFakeChain() *FakeChain {
+	return &FakeChain{
+		heights: make(map[string]int64),
+		mempool: make(map[string]Transaction),
+	}
+}
+
+func (c *FakeChain) SetBlock(block FakeBlock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	height := block.Header.Height
+	for h := int64(len(c.blocks)) - 1; h >= height && h >= 0; h-- {
+		delete(c.heights, c.blocks[h].Header.BlockHash)
+	}
+	if height < int64(len(c.blocks)) {
+		c.blocks = c.blocks[:height]
+	}
+	for int64(len(c.blocks)) < height {
+		c.blocks = append(c.blocks, FakeBlock{})
+	}
+
+	c.blocks = append(c.blocks, block)
+	c.heights[block.Header.BlockHash] = height
+	for _, tx := range block.Txs {
+		delete(c.mempool, tx.TxID)
+	}
+}
+
+func (c *FakeChain) AddMempoolTx(tx Transaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mempool[tx.TxID] = tx
+}
+
+func (c *FakeChain) RemoveMempoolTx(txid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.mempool, txid)
+}
+
+func (c *FakeChain) GetBlockCount(context.Context) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int64(len(c.blocks)) - 1, nil
+}
+
+func (c *FakeChain) GetBlockHash(_ context.Context, height int64) (string, error) {
+	block, err := c.blockAt(height)
+	if err != nil {
+		return "", err
+	}
+	return block.Header.BlockHash, nil
+}
+
+func (c *FakeChain) GetBlockHeader(_ context.Context, hash string) (*BlockHeader, error) {
+	block, err := c.blockByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	header := block.Header
+	return &header, nil
+}
+
+func (c *FakeChain) GetBlock(_ context.Context, hash string) ([]Transaction, error) {
+	block, err := c.blockByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return append([]Transaction(nil), block.Txs...), nil
+}
+
+func (c *FakeChain) GetTxOutProof(_ context.Context, txid, blockhash string) ([]byte, error) {
+	block, err := c.blockByHash(blockhash)
+	if err != nil {
+		return nil, err
+	}
+	if c.Proofs != nil {
+		return c.Proofs(block, txid)
+	}
+
+	header, err := SerializeHeader(&block.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := HashFromHex(txid)
+	if err != nil {
+		return nil, err
+	}
+
+	txids := make([][32]byte, len(block.Txs))
+	for n, tx := range block.Txs {
+		if txids[n], err = HashFromHex(tx.TxID); err != nil {
+			return nil, err
+		}
+	}
+
+	return BuildMerkleBlock(header, txids, match)
+}
+
+func (c *FakeChain) GetRawTransaction(_ context.Context, txid string) (*Transaction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if tx, ok := c.mempool[txid]; ok {
+		return &tx, nil
+	}
+	for _, block := range c.blocks {
+		for _, tx := range block.Txs {
+			if tx.TxID == txid {
+				return &tx, nil
+			}
+		}
+	}
+	return nil, ErrTxNotFound
+}
+
+func (c *FakeChain) GetRawMempool(context.Context) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	txids := make([]string, 0, len(c.mempool))
+	for txid := range c.mempool {
+		txids = append(txids, txid)
+	}
+	return txids, nil
+}
+
+func (c *FakeChain) blockAt(height int64) (FakeBlock, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if height < 0 || height >= int64(len(c.blocks)) || c.blocks[height].Header.BlockHash == "" {
+		return FakeBlock{}, ErrBlockNotFound
+	}
+	return c.blocks[height], nil
+}
+
+func (c *FakeChain) blockByHash(hash string) (FakeBlock, error) {
+	c.mu.Lock()
+	height, ok := c.heights[hash]
+	c.mu.Unlock()
+
+	if !ok {
+		return FakeBlock{}, ErrBlockNotFound
+	}
+	return c.blockAt(height)
+}
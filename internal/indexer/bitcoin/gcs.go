@@ -0,0 +1,199 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"sort"
+)
+
+// Parameters of the BIP158 basic filter.
+const (
+	BasicFilterP = 19
+	BasicFilterM = 784931
+)
+
+var ErrFilterTruncated = errors.New("filter data is truncated")
+
+// Filter is a Golomb-coded set as used by BIP158 compact block filters.
+type Filter struct {
+	n    uint64
+	p    uint8
+	m    uint64
+	k0   uint64
+	k1   uint64
+	data []byte
+}
+
+// ParseBasicFilter parses a serialized basic filter of the block with the
+// given hash, in internal byte order. The first 16 bytes of the hash are the
+// SipHash key.
+func ParseBasicFilter(raw []byte, blockHash [32]byte) (*Filter, error) {
+	r := bytes.NewReader(raw)
+	n, err := readCompactSize(r)
+	if err != nil {
+		return nil, ErrFilterTruncated
+	}
+
+	return &Filter{
+		n:    n,
+		p:    BasicFilterP,
+		m:    BasicFilterM,
+		k0:   binary.LittleEndian.Uint64(blockHash[0:8]),
+		k1:   binary.LittleEndian.Uint64(blockHash[8:16]),
+		data: raw[len(raw)-r.Len():],
+	}, nil
+}
+
+// N is the number of items in the filter.
+func (f *Filter) N() uint64 {
+	return f.n
+}
+
+// Match reports whether item may be in the filter.
+func (f *Filter) Match(item []byte) (bool, error) {
+	return f.MatchAny([][]byte{item})
+}
+
+// MatchAny reports whether any of items may be in the filter. False positives
+// happen with a rate of 1/M per item, false negatives never do.
+func (f *Filter) MatchAny(items [][]byte) (bool, error) {
+	if f.n == 0 || len(items) == 0 {
+		return false, nil
+	}
+
+	nm := f.n * f.m
+	queries := make([]uint64, len(items))
+	for n, item := range items {
+		queries[n] = hashToRange(sipHash(f.k0, f.k1, item), nm)
+	}
+	sort.Slice(queries, func(a, b int) bool { return queries[a] < queries[b] })
+
+	r := bitReader{data: f.data}
+	var value uint64
+	q := 0
+	for k := uint64(0); k < f.n; k++ {
+		delta, err := r.readGolombRice(f.p)
+		if err != nil {
+			return false, err
+		}
+		value += delta
+
+		for queries[q] < value {
+			q++
+			if q == len(queries) {
+				return false, nil
+			}
+		}
+		if queries[q] == value {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// hashToRange maps a 64 bit hash uniformly onto [0, nm).
+func hashToRange(hash, nm uint64) uint64 {
+	hi, _ := bits.Mul64(hash, nm)
+	return hi
+}
+
+type bitReader struct {
+	data []byte
+	pos  uint64
+}
+
+func (r *bitReader) readBit() (uint64, error) {
+	idx := r.pos / 8
+	if idx >= uint64(len(r.data)) {
+		return 0, ErrFilterTruncated
+	}
+	bit := (r.data[idx] >> (7 - r.pos%8)) & 1
+	r.pos++
+	return uint64(bit), nil
+}
+
+func (r *bitReader) readBits(n uint8) (uint64, error) {
+	var v uint64
+	for k := uint8(0); k < n; k++ {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<1 | bit
+	}
+	return v, nil
+}
+
+// readGolombRice reads a unary quotient followed by a p bit remainder.
+func (r *bitReader) readGolombRice(p uint8) (uint64, error) {
+	var quotient uint64
+	for {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if bit == 0 {
+			break
+		}
+		quotient++
+	}
+
+	remainder, err := r.readBits(p)
+	if err != nil {
+		return 0, err
+	}
+	return quotient<<p | remainder, nil
+}
+
+// sipHash is SipHash-2-4 keyed with k0 and k1.
+func sipHash(k0, k1 uint64, p []byte) uint64 {
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+
+	b := uint64(len(p)) << 56
+	for ; len(p) >= 8; p = p[8:] {
+		m := binary.LittleEndian.Uint64(p)
+		v3 ^= m
+		round()
+		round()
+		v0 ^= m
+	}
+	for n, c := range p {
+		b |= uint64(c) << (8 * n)
+	}
+
+	v3 ^= b
+	round()
+	round()
+	v0 ^= b
+
+	v2 ^= 0xff
+	round()
+	round()
+	round()
+	round()
+
+	return v0 ^ v1 ^ v2 ^ v3
+}
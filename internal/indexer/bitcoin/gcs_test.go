@@ -0,0 +1,257 @@
+package bitcoin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+// bip158Vectors are rows of the BIP158 testnet-19.json test vectors: the
+// block, the output scripts its filter holds, the filter and its header.
+var bip158Vectors = []struct {
+	name         string
+	blockHash    string
+	scripts      []string
+	filter       string
+	prevHeader   string
+	filterHeader string
+}{
+	{
+		name:      "genesis block",
+		blockHash: "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943",
+		scripts: []string{
+			"4104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac",
+		},
+		filter:       "019dfca8",
+		prevHeader:   "0000000000000000000000000000000000000000000000000000000000000000",
+		filterHeader: "21584579b7eb08997773e5aeff3a7f932700042d0ed2a6129012b7d7ae81b750",
+	},
+}
+
+func TestBasicFilterVectors(t *testing.T) {
+	for _, v := range bip158Vectors {
+		t.Run(v.name, func(t *testing.T) {
+			raw := mustDecodeHex(t, v.filter)
+
+			// the vector itself: header = dsha256(dsha256(filter) || prev)
+			filterHash := DoubleSHA256(raw)
+			prev := mustHash(t, v.prevHeader)
+			if got := HashToHex(DoubleSHA256(append(filterHash[:], prev[:]...))); got != v.filterHeader {
+				t.Fatalf("filter header = %s, want %s", got, v.filterHeader)
+			}
+
+			var scripts [][]byte
+			for _, script := range v.scripts {
+				scripts = append(scripts, mustDecodeHex(t, script))
+			}
+			if built := buildBasicFilter(mustHash(t, v.blockHash), scripts); !bytes.Equal(built, raw) {
+				t.Errorf("built filter = %x, want %s", built, v.filter)
+			}
+
+			f, err := ParseBasicFilter(raw, mustHash(t, v.blockHash))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.N() != uint64(len(v.scripts)) {
+				t.Errorf("N = %d, want %d", f.N(), len(v.scripts))
+			}
+
+			for _, script := range scripts {
+				ok, err := f.Match(script)
+				if err != nil || !ok {
+					t.Errorf("Match(%x) = %v, %v, want true", script, ok, err)
+				}
+			}
+
+			other := mustDecodeHex(t, "0014751e76e8199196d454941c45d1b3a323f1433bd6")
+			ok, err := f.Match(other)
+			if err != nil || ok {
+				t.Errorf("Match(unrelated script) = %v, %v, want false", ok, err)
+			}
+
+			ok, err = f.MatchAny(append([][]byte{other}, scripts...))
+			if err != nil || !ok {
+				t.Errorf("MatchAny = %v, %v, want true", ok, err)
+			}
+		})
+	}
+}
+
+func TestSipHash(t *testing.T) {
+	// SipHash-2-4 reference vectors: key 00 01 .. 0f, message 00 01 .. n-1,
+	// output as little endian bytes
+	vectors := map[int]string{
+		0:  "310e0edd47db6f72",
+		1:  "fd67dc93c539f874",
+		2:  "5a4fa9d909806c0d",
+		3:  "2d7efbd796666785",
+		7:  "37d1018bf50002ab",
+		8:  "6224939a79f5f593",
+		15: "e545be4961ca29a1",
+		63: "724506eb4c328a95",
+	}
+
+	k0 := uint64(0x0706050403020100)
+	k1 := uint64(0x0f0e0d0c0b0a0908)
+	for n, want := range vectors {
+		msg := make([]byte, n)
+		for i := range msg {
+			msg[i] = byte(i)
+		}
+
+		got := binary.LittleEndian.AppendUint64(nil, sipHash(k0, k1, msg))
+		if hexGot := hex.EncodeToString(got); hexGot != want {
+			t.Errorf("sipHash(%d bytes) = %s, want %s", n, hexGot, want)
+		}
+	}
+}
+
+func TestHashToRange(t *testing.T) {
+	nm := uint64(10) * BasicFilterM
+	tests := []struct {
+		hash uint64
+		want uint64
+	}{
+		{0, 0},
+		{1 << 63, nm / 2},
+		{math.MaxUint64, nm - 1},
+	}
+	for _, tt := range tests {
+		if got := hashToRange(tt.hash, nm); got != tt.want {
+			t.Errorf("hashToRange(%#x) = %d, want %d", tt.hash, got, tt.want)
+		}
+	}
+}
+
+func TestGolombRiceReader(t *testing.T) {
+	values := []uint64{0, 1, 1<<BasicFilterP - 1, 1 << BasicFilterP, 3<<BasicFilterP | 12345}
+
+	var w bitWriter
+	for _, v := range values {
+		w.writeGolombRice(v, BasicFilterP)
+	}
+
+	r := bitReader{data: w.data}
+	for _, want := range values {
+		got, err := r.readGolombRice(BasicFilterP)
+		if err != nil || got != want {
+			t.Fatalf("readGolombRice = %d, %v, want %d", got, err, want)
+		}
+	}
+	// only the padding of the last byte is left
+	if _, err := r.readGolombRice(BasicFilterP); !errors.Is(err, ErrFilterTruncated) {
+		t.Errorf("reading past the data: %v, want ErrFilterTruncated", err)
+	}
+}
+
+func TestMatchAny(t *testing.T) {
+	blockHash := sha256.Sum256([]byte("block"))
+	var scripts [][]byte
+	for n := 0; n < 200; n++ {
+		script := sha256.Sum256(binary.BigEndian.AppendUint32(nil, uint32(n)))
+		scripts = append(scripts, script[:22])
+	}
+	members, others := scripts[:100], scripts[100:]
+
+	f, err := ParseBasicFilter(buildBasicFilter(blockHash, members), blockHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, script := range members {
+		if ok, err := f.Match(script); err != nil || !ok {
+			t.Fatalf("Match(member) = %v, %v", ok, err)
+		}
+	}
+
+	// with 100 items and M = 784931 a false positive among 100 queries is
+	// unlikely, and the inputs are fixed
+	if ok, err := f.MatchAny(others); err != nil || ok {
+		t.Errorf("MatchAny(non-members) = %v, %v, want false", ok, err)
+	}
+	if ok, err := f.MatchAny(append(slices.Clone(others), members[42])); err != nil || !ok {
+		t.Errorf("MatchAny(non-members and a member) = %v, %v, want true", ok, err)
+	}
+	if ok, err := f.MatchAny(nil); err != nil || ok {
+		t.Errorf("MatchAny(nil) = %v, %v, want false", ok, err)
+	}
+}
+
+func TestEmptyAndTruncatedFilters(t *testing.T) {
+	blockHash := sha256.Sum256([]byte("block"))
+	script := []byte{0x51}
+
+	empty, err := ParseBasicFilter([]byte{0x00}, blockHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := empty.Match(script); err != nil || ok {
+		t.Errorf("empty filter Match = %v, %v, want false", ok, err)
+	}
+
+	if _, err := ParseBasicFilter(nil, blockHash); !errors.Is(err, ErrFilterTruncated) {
+		t.Errorf("ParseBasicFilter(nil) = %v, want ErrFilterTruncated", err)
+	}
+
+	// a filter claiming more items than its data holds
+	raw := buildBasicFilter(blockHash, [][]byte{script})
+	raw[0] = 5
+	f, err := ParseBasicFilter(raw, blockHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Match([]byte("not in the filter")); !errors.Is(err, ErrFilterTruncated) {
+		t.Errorf("Match on truncated filter = %v, want ErrFilterTruncated", err)
+	}
+}
+
+// buildBasicFilter encodes items the way BIP158 builds a basic filter.
+func buildBasicFilter(blockHash [32]byte, items [][]byte) []byte {
+	k0 := binary.LittleEndian.Uint64(blockHash[0:8])
+	k1 := binary.LittleEndian.Uint64(blockHash[8:16])
+	nm := uint64(len(items)) * BasicFilterM
+
+	values := make([]uint64, len(items))
+	for n, item := range items {
+		values[n] = hashToRange(sipHash(k0, k1, item), nm)
+	}
+	slices.Sort(values)
+
+	var w bitWriter
+	var last uint64
+	for _, v := range values {
+		w.writeGolombRice(v-last, BasicFilterP)
+		last = v
+	}
+
+	raw := []byte{byte(len(items))}
+	return append(raw, w.data...)
+}
+
+type bitWriter struct {
+	data []byte
+	bits uint64
+}
+
+func (w *bitWriter) writeBit(bit uint64) {
+	if w.bits%8 == 0 {
+		w.data = append(w.data, 0)
+	}
+	w.data[len(w.data)-1] |= byte(bit << (7 - w.bits%8))
+	w.bits++
+}
+
+func (w *bitWriter) writeGolombRice(v uint64, p uint8) {
+	for q := v >> p; q > 0; q-- {
+		w.writeBit(1)
+	}
+	w.writeBit(0)
+	for k := int(p) - 1; k >= 0; k-- {
+		w.writeBit(v >> k & 1)
+	}
+}
@@ -0,0 +1,170 @@
+package bitcoin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+// HardenedKeyStart is the first hardened BIP32 child index.
+const HardenedKeyStart = 0x80000000
+
+var (
+	ErrInvalidExtendedKey = errors.New("invalid extended public key")
+	ErrHardenedDerivation = errors.New("cannot derive hardened child from a public key")
+	// ErrUnusableChild is returned for the ~2^-127 chance indexes BIP32
+	// says to skip.
+	ErrUnusableChild = errors.New("child key is invalid, skip the index")
+)
+
+// ErrKeyNetworkMismatch means an extended key is for another network.
+var ErrKeyNetworkMismatch = errors.New("extended public key is for another network")
+
+// Versions of xpub and tpub keys, which identify the network.
+const (
+	versionMainPubKey = 0x0488b21e
+	versionTestPubKey = 0x043587cf
+)
+
+// keyVersion is what the version bytes of an extended public key tell.
+type keyVersion struct {
+	// Type is the script its addresses use by convention.
+	Type string
+	// Network is the xpub or tpub version of the network it is for,
+	// compared with ChainParams.HDPublicKeyID.
+	Network uint32
+}
+
+// extendedKeyVersions maps the version bytes of serialized extended public
+// keys to their network and script (SLIP-0132).
+var extendedKeyVersions = map[uint32]keyVersion{
+	versionMainPubKey: {DescriptorPKH, versionMainPubKey},    // xpub
+	0x049d7cb2:        {DescriptorSHWPKH, versionMainPubKey}, // ypub
+	0x04b24746:        {DescriptorWPKH, versionMainPubKey},   // zpub
+	versionTestPubKey: {DescriptorPKH, versionTestPubKey},    // tpub
+	0x044a5262:        {DescriptorSHWPKH, versionTestPubKey}, // upub
+	0x045f1cf6:        {DescriptorWPKH, versionTestPubKey},   // vpub
+}
+
+// ExtendedKey is a BIP32 extended public key.
+type ExtendedKey struct {
+	Version           uint32
+	Depth             byte
+	ParentFingerprint uint32
+	ChildNumber       uint32
+	ChainCode         [32]byte
+	PubKey            []byte
+}
+
+// ParseExtendedKey decodes a Base58Check serialized extended public key, such
+// as an xpub, ypub or zpub.
+func ParseExtendedKey(s string) (*ExtendedKey, error) {
+	payload, err := decodeBase58Check(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExtendedKey, err)
+	}
+	if len(payload) != 78 {
+		return nil, fmt.Errorf("%w: unexpected length %d", ErrInvalidExtendedKey, len(payload))
+	}
+
+	key := &ExtendedKey{
+		Version:           binary.BigEndian.Uint32(payload[0:4]),
+		Depth:             payload[4],
+		ParentFingerprint: binary.BigEndian.Uint32(payload[5:9]),
+		ChildNumber:       binary.BigEndian.Uint32(payload[9:13]),
+		PubKey:            payload[45:78],
+	}
+	copy(key.ChainCode[:], payload[13:45])
+
+	if _, ok := extendedKeyVersions[key.Version]; !ok {
+		return nil, fmt.Errorf("%w: unknown version %#08x", ErrInvalidExtendedKey, key.Version)
+	}
+	if _, err := parsePubKey(key.PubKey); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExtendedKey, err)
+	}
+	return key, nil
+}
+
+// CheckNetwork makes sure the key's version bytes are for the network of p.
+func (k *ExtendedKey) CheckNetwork(p *ChainParams) error {
+	if extendedKeyVersions[k.Version].Network != p.HDPublicKeyID {
+		return fmt.Errorf("%w: version %#08x on %s", ErrKeyNetworkMismatch, k.Version, p.Name)
+	}
+	return nil
+}
+
+// String serializes the key back to Base58Check.
+func (k *ExtendedKey) String() string {
+	payload := make([]byte, 0, 78)
+	payload = binary.BigEndian.AppendUint32(payload, k.Version)
+	payload = append(payload, k.Depth)
+	payload = binary.BigEndian.AppendUint32(payload, k.ParentFingerprint)
+	payload = binary.BigEndian.AppendUint32(payload, k.ChildNumber)
+	payload = append(payload, k.ChainCode[:]...)
+	payload = append(payload, k.PubKey...)
+	return encodeBase58Check(payload)
+}
+
+// Child derives the non-hardened child key at index.
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	if index >= HardenedKeyStart {
+		return nil, ErrHardenedDerivation
+	}
+
+	parent, err := parsePubKey(k.PubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha512.New, k.ChainCode[:])
+	mac.Write(k.PubKey)
+	mac.Write(binary.BigEndian.AppendUint32(nil, index))
+	sum := mac.Sum(nil)
+
+	tweak := new(big.Int).SetBytes(sum[:32])
+	if tweak.Cmp(curveN) >= 0 {
+		return nil, ErrUnusableChild
+	}
+	child := curveG.mul(tweak).add(parent)
+	if child == nil {
+		return nil, ErrUnusableChild
+	}
+
+	fingerprint := Hash160(k.PubKey)
+	derived := &ExtendedKey{
+		Version:           k.Version,
+		Depth:             k.Depth + 1,
+		ParentFingerprint: binary.BigEndian.Uint32(fingerprint[:4]),
+		ChildNumber:       index,
+		PubKey:            child.compressed(),
+	}
+	copy(derived.ChainCode[:], sum[32:])
+	return derived, nil
+}
+
+// Derive follows path from k, one non-hardened child per step.
+func (k *ExtendedKey) Derive(path ...uint32) (*ExtendedKey, error) {
+	key := k
+	for _, index := range path {
+		var err error
+		if key, err = key.Child(index); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// Hash160 is RIPEMD160(SHA256(b)), the hash public keys and scripts are
+// committed to in addresses.
+func Hash160(b []byte) []byte {
+	sha := sha256.Sum256(b)
+	h := ripemd160.New()
+	h.Write(sha[:])
+	return h.Sum(nil)
+}
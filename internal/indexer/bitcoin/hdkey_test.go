@@ -0,0 +1,135 @@
+package bitcoin
+
+import (
+	"errors"
+	"testing"
+)
+
+// bip32Vectors are the public derivation steps of BIP32 test vectors 1 and
+// 2: each child is derived from the key before it, those behind a hardened
+// step start from the published extended public key.
+var bip32Vectors = []struct {
+	name   string
+	parent string
+	index  uint32
+	child  string
+}{
+	{
+		name:   "vector 1 m/0H/1",
+		parent: "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw",
+		index:  1,
+		child:  "xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ",
+	},
+	{
+		name:   "vector 1 m/0H/1/2H/2",
+		parent: "xpub6D4BDPcP2GT577Vvch3R8wDkScZWzQzMMUm3PWbmWvVJrZwQY4VUNgqFJPMM3No2dFDFGTsxxpG5uJh7n7epu4trkrX7x7DogT5Uv6fcLW5",
+		index:  2,
+		child:  "xpub6FHa3pjLCk84BayeJxFW2SP4XRrFd1JYnxeLeU8EqN3vDfZmbqBqaGJAyiLjTAwm6ZLRQUMv1ZACTj37sR62cfN7fe5JnJ7dh8zL4fiyLHV",
+	},
+	{
+		name:   "vector 1 m/0H/1/2H/2/1000000000",
+		parent: "xpub6FHa3pjLCk84BayeJxFW2SP4XRrFd1JYnxeLeU8EqN3vDfZmbqBqaGJAyiLjTAwm6ZLRQUMv1ZACTj37sR62cfN7fe5JnJ7dh8zL4fiyLHV",
+		index:  1000000000,
+		child:  "xpub6H1LXWLaKsWFhvm6RVpEL9P4KfRZSW7abD2ttkWP3SSQvnyA8FSVqNTEcYFgJS2UaFcxupHiYkro49S8yGasTvXEYBVPamhGW6cFJodrTHy",
+	},
+	{
+		name:   "vector 2 m/0",
+		parent: "xpub661MyMwAqRbcFW31YEwpkMuc5THy2PSt5bDMsktWQcFF8syAmRUapSCGu8ED9W6oDMSgv6Zz8idoc4a6mr8BDzTJY47LJhkJ8UB7WEGuduB",
+		index:  0,
+		child:  "xpub69H7F5d8KSRgmmdJg2KhpAK8SR3DjMwAdkxj3ZuxV27CprR9LgpeyGmXUbC6wb7ERfvrnKZjXoUmmDznezpbZb7ap6r1D3tgFxHmwMkQTPH",
+	},
+	{
+		name:   "vector 2 m/0/2147483647H/1",
+		parent: "xpub6ASAVgeehLbnwdqV6UKMHVzgqAG8Gr6riv3Fxxpj8ksbH9ebxaEyBLZ85ySDhKiLDBrQSARLq1uNRts8RuJiHjaDMBU4Zn9h8LZNnBC5y4a",
+		index:  1,
+		child:  "xpub6DF8uhdarytz3FWdA8TvFSvvAh8dP3283MY7p2V4SeE2wyWmG5mg5EwVvmdMVCQcoNJxGoWaU9DCWh89LojfZ537wTfunKau47EL2dhHKon",
+	},
+	{
+		name:   "vector 2 m/0/2147483647H/1/2147483646H/2",
+		parent: "xpub6ERApfZwUNrhLCkDtcHTcxd75RbzS1ed54G1LkBUHQVHQKqhMkhgbmJbZRkrgZw4koxb5JaHWkY4ALHY2grBGRjaDMzQLcgJvLJuZZvRcEL",
+		index:  2,
+		child:  "xpub6FnCn6nSzZAw5Tw7cgR9bi15UV96gLZhjDstkXXxvCLsUXBGXPdSnLFbdpq8p9HmGsApME5hQTZ3emM2rnY5agb9rXpVGyy3bdW6EEgAtqt",
+	},
+}
+
+func TestExtendedKeyChild(t *testing.T) {
+	for _, tt := range bip32Vectors {
+		parent, err := ParseExtendedKey(tt.parent)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if parent.String() != tt.parent {
+			t.Errorf("%s: parent serialized as %s", tt.name, parent.String())
+		}
+
+		child, err := parent.Child(tt.index)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if child.String() != tt.child {
+			t.Errorf("%s: child = %s, want %s", tt.name, child.String(), tt.child)
+		}
+	}
+}
+
+func TestExtendedKeyRejectsHardenedChild(t *testing.T) {
+	key, err := ParseExtendedKey(bip32Vectors[0].parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := key.Child(HardenedKeyStart); err != ErrHardenedDerivation {
+		t.Errorf("hardened child = %v, want ErrHardenedDerivation", err)
+	}
+	if _, err := key.Derive(1, HardenedKeyStart+2); err != ErrHardenedDerivation {
+		t.Errorf("path through a hardened step = %v, want ErrHardenedDerivation", err)
+	}
+}
+
+func TestParseExtendedKeyRejectsInvalidKeys(t *testing.T) {
+	// an xprv: private version bytes, a zero byte and the secret in place
+	// of the public key
+	xprv := []byte{0x04, 0x88, 0xad, 0xe4, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	xprv = append(xprv, make([]byte, 32)...)
+	xprv = append(xprv, 0)
+	for n := 0; n < 32; n++ {
+		xprv = append(xprv, byte(n+1))
+	}
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"xprv", encodeBase58Check(xprv)},
+		{"bad checksum", "xpub661MyMwAqRbcFW31YEwpkMuc5THy2PSt5bDMsktWQcFF8syAmRUapSCGu8ED9W6oDMSgv6Zz8idoc4a6mr8BDzTJY47LJhkJ8UB7WEGuduC"},
+		{"truncated", "xpub661MyMwAqRbcFW31YEwpkMuc5THy2PSt5bDMsktWQcFF8syAmRUapSCGu8ED9W6oDMSgv6Zz8idoc4a6mr8BDzTJY47LJ"},
+		{"not base58", "xpub0OIl"},
+	}
+
+	for _, tt := range tests {
+		if _, err := ParseExtendedKey(tt.key); !errors.Is(err, ErrInvalidExtendedKey) {
+			t.Errorf("%s: err = %v, want ErrInvalidExtendedKey", tt.name, err)
+		}
+	}
+}
+
+func TestExtendedKeyCheckNetwork(t *testing.T) {
+	xpub, err := ParseExtendedKey(bip32Vectors[3].parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpub := *xpub
+	tpub.Version = versionTestPubKey
+
+	for _, params := range Networks {
+		key, other := xpub, &tpub
+		if params.HDPublicKeyID == versionTestPubKey {
+			key, other = &tpub, xpub
+		}
+		if err := key.CheckNetwork(params); err != nil {
+			t.Errorf("%s: key of the network rejected: %v", params.Name, err)
+		}
+		if err := other.CheckNetwork(params); !errors.Is(err, ErrKeyNetworkMismatch) {
+			t.Errorf("%s: key of another network = %v, want ErrKeyNetworkMismatch", params.Name, err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package bitcoin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+// SerializeHeader returns the 80 byte consensus encoding of header.
+func SerializeHeader(header *BlockHeader) ([]byte, error) {
+	var prev [32]byte
+	if header.PreviousHash != "" {
+		var err error
+		prev, err = HashFromHex(header.PreviousHash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid previous hash: %w", err)
+		}
+	}
+
+	root, err := HashFromHex(header.MerkleRoot)
+	if err != nil {
+		return nil, fmt.Errorf("invalid merkle root: %w", err)
+	}
+
+	bits, err := strconv.ParseUint(header.Bits, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bits: %w", err)
+	}
+
+	buf := make([]byte, 0, headerSize)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(header.Version))
+	buf = append(buf, prev[:]...)
+	buf = append(buf, root[:]...)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(header.Timestamp))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(bits))
+	buf = binary.LittleEndian.AppendUint32(buf, header.Nonce)
+
+	return buf, nil
+}
@@ -0,0 +1,454 @@
+package bitcoin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const headerSize = 80
+
+// MerkleBlock is a deserialized CMerkleBlock: a block header followed by a
+// BIP37 partial merkle tree. This is what gettxoutproof returns.
+type MerkleBlock struct {
+	Header       []byte
+	Transactions uint32
+	Hashes       [][32]byte
+	Flags        []byte
+}
+
+func ParseMerkleBlock(raw []byte) (*MerkleBlock, error) {
+	r := bytes.NewReader(raw)
+
+	mb := &MerkleBlock{Header: make([]byte, headerSize)}
+	if _, err := io.ReadFull(r, mb.Header); err != nil {
+		return nil, errors.New("merkle block is too short for a header")
+	}
+	if err := binary.Read(r, binary.LittleEndian, &mb.Transactions); err != nil {
+		return nil, errors.New("merkle block has no transaction count")
+	}
+
+	hashCount, err := readCompactSize(r)
+	if err != nil {
+		return nil, err
+	}
+	if hashCount > uint64(r.Len())/32 {
+		return nil, errors.New("merkle block hash count exceeds its size")
+	}
+	mb.Hashes = make([][32]byte, hashCount)
+	for n := range mb.Hashes {
+		if _, err := io.ReadFull(r, mb.Hashes[n][:]); err != nil {
+			return nil, err
+		}
+	}
+
+	flagCount, err := readCompactSize(r)
+	if err != nil {
+		return nil, err
+	}
+	if flagCount > uint64(r.Len()) {
+		return nil, errors.New("merkle block flag count exceeds its size")
+	}
+	mb.Flags = make([]byte, flagCount)
+	if _, err := io.ReadFull(r, mb.Flags); err != nil {
+		return nil, err
+	}
+
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("merkle block has %d trailing bytes", r.Len())
+	}
+
+	return mb, nil
+}
+
+// MerkleRoot returns the root committed to by the header of the proof.
+func (m *MerkleBlock) MerkleRoot() [32]byte {
+	var root [32]byte
+	copy(root[:], m.Header[36:68])
+	return root
+}
+
+// ExtractMatches walks the partial merkle tree and returns the root it hashes
+// to together with the txids flagged as matched, in internal byte order.
+func (m *MerkleBlock) ExtractMatches() ([32]byte, [][32]byte, error) {
+	t, err := m.walk()
+	if err != nil {
+		return [32]byte{}, nil, err
+	}
+	return t.root, t.matches, nil
+}
+
+// MerkleBranchNode is a sibling on the path from a transaction to the merkle
+// root. IsLeft is set when the sibling is hashed in on the left.
+type MerkleBranchNode struct {
+	Hash   [32]byte
+	IsLeft bool
+}
+
+// Branch returns the siblings needed to hash txid up to the merkle root,
+// leaf level first. txid must be matched by the proof.
+func (m *MerkleBlock) Branch(txid [32]byte) ([]MerkleBranchNode, error) {
+	t, err := m.walk()
+	if err != nil {
+		return nil, err
+	}
+	return t.branch(txid)
+}
+
+func (t *partialTree) branch(txid [32]byte) ([]MerkleBranchNode, error) {
+	pos, ok := t.matchPositions[txid]
+	if !ok {
+		return nil, errors.New("transaction is not matched by the proof")
+	}
+
+	height := t.height()
+	branch := make([]MerkleBranchNode, 0, height)
+	for h := uint(0); h < height; h++ {
+		siblingPos := pos ^ 1
+		node := MerkleBranchNode{IsLeft: siblingPos < pos}
+
+		if siblingPos < t.width(h) {
+			hash, ok := t.nodes[treeNode{height: h, pos: siblingPos}]
+			if !ok {
+				return nil, errors.New("merkle block does not cover the branch")
+			}
+			node.Hash = hash
+		} else {
+			// the last node of an odd level is paired with itself
+			node.Hash = t.nodes[treeNode{height: h, pos: pos}]
+		}
+
+		branch = append(branch, node)
+		pos >>= 1
+	}
+
+	return branch, nil
+}
+
+func (m *MerkleBlock) walk() (*partialTree, error) {
+	if m.Transactions == 0 {
+		return nil, errors.New("merkle block has no transactions")
+	}
+	if uint64(len(m.Hashes)) > uint64(m.Transactions) {
+		return nil, errors.New("merkle block has more hashes than transactions")
+	}
+	if len(m.Flags)*8 < len(m.Hashes) {
+		return nil, errors.New("merkle block has too few flag bits")
+	}
+
+	t := &partialTree{
+		transactions:   m.Transactions,
+		hashes:         m.Hashes,
+		flags:          m.Flags,
+		nodes:          make(map[treeNode][32]byte),
+		matchPositions: make(map[[32]byte]uint32),
+	}
+
+	root, err := t.traverse(t.height(), 0)
+	if err != nil {
+		return nil, err
+	}
+	t.root = root
+
+	if t.hashesUsed != len(m.Hashes) {
+		return nil, errors.New("merkle block has unused hashes")
+	}
+	if (t.bitsUsed+7)/8 != len(m.Flags) {
+		return nil, errors.New("merkle block has unused flag bytes")
+	}
+
+	return t, nil
+}
+
+type treeNode struct {
+	height uint
+	pos    uint32
+}
+
+type partialTree struct {
+	transactions uint32
+	hashes       [][32]byte
+	flags        []byte
+
+	bitsUsed       int
+	hashesUsed     int
+	root           [32]byte
+	matches        [][32]byte
+	matchPositions map[[32]byte]uint32
+	// nodes holds every hash seen or computed during the walk
+	nodes map[treeNode][32]byte
+}
+
+func (t *partialTree) width(height uint) uint32 {
+	return uint32((uint64(t.transactions) + (1 << height) - 1) >> height)
+}
+
+func (t *partialTree) height() uint {
+	var height uint
+	for t.width(height) > 1 {
+		height++
+	}
+	return height
+}
+
+func (t *partialTree) traverse(height uint, pos uint32) ([32]byte, error) {
+	var hash [32]byte
+
+	if t.bitsUsed >= len(t.flags)*8 {
+		return hash, errors.New("merkle block ran out of flag bits")
+	}
+	flag := t.flags[t.bitsUsed/8]&(1<<(t.bitsUsed%8)) != 0
+	t.bitsUsed++
+
+	if height == 0 || !flag {
+		if t.hashesUsed >= len(t.hashes) {
+			return hash, errors.New("merkle block ran out of hashes")
+		}
+		hash = t.hashes[t.hashesUsed]
+		t.hashesUsed++
+
+		if height == 0 && flag {
+			t.matches = append(t.matches, hash)
+			t.matchPositions[hash] = pos
+		}
+		t.nodes[treeNode{height: height, pos: pos}] = hash
+		return hash, nil
+	}
+
+	left, err := t.traverse(height-1, pos*2)
+	if err != nil {
+		return hash, err
+	}
+
+	right := left
+	if pos*2+1 < t.width(height-1) {
+		right, err = t.traverse(height-1, pos*2+1)
+		if err != nil {
+			return hash, err
+		}
+		// identical siblings would allow a second tree with the same root,
+		// see CVE-2012-2459
+		if right == left {
+			return hash, errors.New("merkle block has duplicate sibling hashes")
+		}
+	}
+
+	hash = hashPair(left, right)
+	t.nodes[treeNode{height: height, pos: pos}] = hash
+	return hash, nil
+}
+
+// VerifyMerkleProof checks that proof, a serialized CMerkleBlock as returned
+// by gettxoutproof, includes txid under merkleRoot and returns the branch
+// proving it. Both are hex in the usual reversed byte order.
+func VerifyMerkleProof(txid string, proof []byte, merkleRoot string) ([]MerkleBranchNode, error) {
+	wantTx, err := HashFromHex(txid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid txid: %w", err)
+	}
+	wantRoot, err := HashFromHex(merkleRoot)
+	if err != nil {
+		return nil, fmt.Errorf("invalid merkle root: %w", err)
+	}
+
+	mb, err := ParseMerkleBlock(proof)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := mb.walk()
+	if err != nil {
+		return nil, err
+	}
+	if t.root != mb.MerkleRoot() {
+		return nil, errors.New("proof does not hash to the merkle root of its header")
+	}
+	if t.root != wantRoot {
+		return nil, errors.New("proof merkle root does not match the block header")
+	}
+
+	return t.branch(wantTx)
+}
+
+// BranchRoot hashes txid up through branch and returns the resulting root.
+func BranchRoot(txid [32]byte, branch []MerkleBranchNode) [32]byte {
+	hash := txid
+	for _, node := range branch {
+		if node.IsLeft {
+			hash = hashPair(node.Hash, hash)
+		} else {
+			hash = hashPair(hash, node.Hash)
+		}
+	}
+	return hash
+}
+
+func DoubleSHA256(b []byte) [32]byte {
+	first := sha256.Sum256(b)
+	return sha256.Sum256(first[:])
+}
+
+func hashPair(left, right [32]byte) [32]byte {
+	var buf [64]byte
+	copy(buf[:32], left[:])
+	copy(buf[32:], right[:])
+	return DoubleSHA256(buf[:])
+}
+
+// HashFromHex decodes a txid or block hash from its display form, which is
+// the internal byte order reversed.
+func HashFromHex(s string) ([32]byte, error) {
+	var hash [32]byte
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return hash, err
+	}
+	if len(b) != 32 {
+		return hash, fmt.Errorf("hash must be 32 bytes, got %d", len(b))
+	}
+
+	for n := range b {
+		hash[31-n] = b[n]
+	}
+	return hash, nil
+}
+
+func HashToHex(hash [32]byte) string {
+	var reversed [32]byte
+	for n := range hash {
+		reversed[31-n] = hash[n]
+	}
+	return hex.EncodeToString(reversed[:])
+}
+
+func readCompactSize(r io.Reader) (uint64, error) {
+	var prefix [1]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return 0, err
+	}
+
+	switch prefix[0] {
+	case 0xfd:
+		var v uint16
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return uint64(v), err
+	case 0xfe:
+		var v uint32
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return uint64(v), err
+	case 0xff:
+		var v uint64
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	default:
+		return uint64(prefix[0]), nil
+	}
+}
+
+func writeCompactSize(w *bytes.Buffer, v uint64) {
+	switch {
+	case v < 0xfd:
+		w.WriteByte(byte(v))
+	case v <= 0xffff:
+		w.WriteByte(0xfd)
+		_ = binary.Write(w, binary.LittleEndian, uint16(v))
+	case v <= 0xffffffff:
+		w.WriteByte(0xfe)
+		_ = binary.Write(w, binary.LittleEndian, uint32(v))
+	default:
+		w.WriteByte(0xff)
+		_ = binary.Write(w, binary.LittleEndian, v)
+	}
+}
+
+// BuildMerkleBlock serializes a CMerkleBlock for the block with header and
+// txids proving the inclusion of match, the way gettxoutproof does.
+func BuildMerkleBlock(header []byte, txids [][32]byte, match [32]byte) ([]byte, error) {
+	if len(header) != headerSize {
+		return nil, fmt.Errorf("header must be %d bytes, got %d", headerSize, len(header))
+	}
+
+	matched := make([]bool, len(txids))
+	found := false
+	for n, txid := range txids {
+		if txid == match {
+			matched[n] = true
+			found = true
+		}
+	}
+	if !found {
+		return nil, errors.New("transaction is not in the block")
+	}
+
+	b := &treeBuilder{
+		partialTree: partialTree{transactions: uint32(len(txids))},
+		txids:       txids,
+		matched:     matched,
+	}
+	b.build(b.height(), 0)
+
+	var buf bytes.Buffer
+	buf.Write(header)
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(txids)))
+	writeCompactSize(&buf, uint64(len(b.hashes)))
+	for _, hash := range b.hashes {
+		buf.Write(hash[:])
+	}
+
+	flags := make([]byte, (len(b.bits)+7)/8)
+	for n, bit := range b.bits {
+		if bit {
+			flags[n/8] |= 1 << (n % 8)
+		}
+	}
+	writeCompactSize(&buf, uint64(len(flags)))
+	buf.Write(flags)
+
+	return buf.Bytes(), nil
+}
+
+type treeBuilder struct {
+	partialTree
+	txids   [][32]byte
+	matched []bool
+	bits    []bool
+}
+
+func (b *treeBuilder) build(height uint, pos uint32) {
+	parentOfMatch := false
+	for p := uint64(pos) << height; p < uint64(pos+1)<<height && p < uint64(len(b.txids)); p++ {
+		if b.matched[p] {
+			parentOfMatch = true
+			break
+		}
+	}
+	b.bits = append(b.bits, parentOfMatch)
+
+	if height == 0 || !parentOfMatch {
+		b.hashes = append(b.hashes, b.hash(height, pos))
+		return
+	}
+
+	b.build(height-1, pos*2)
+	if pos*2+1 < b.width(height-1) {
+		b.build(height-1, pos*2+1)
+	}
+}
+
+func (b *treeBuilder) hash(height uint, pos uint32) [32]byte {
+	if height == 0 {
+		return b.txids[pos]
+	}
+
+	left := b.hash(height-1, pos*2)
+	right := left
+	if pos*2+1 < b.width(height-1) {
+		right = b.hash(height-1, pos*2+1)
+	}
+	return hashPair(left, right)
+}
@@ -0,0 +1,211 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// merkleVectors are gettxoutproof results from mainnet, with every txid of
+// the block so proofs can be rebuilt.
+var merkleVectors = []struct {
+	name       string
+	blockHash  string
+	merkleRoot string
+	txid       string
+	txids      []string
+	proof      string
+}{
+	{
+		name:       "genesis",
+		blockHash:  "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+		merkleRoot: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+		txid:       "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+		txids: []string{
+			"4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+		},
+		proof: "0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c" +
+			"01000000013ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a0101",
+	},
+	{
+		// the first transaction between two people
+		name:       "block 170",
+		blockHash:  "00000000d1145790a8694403d4063f323d499e655c83426834d4ce2f8dd4a2ee",
+		merkleRoot: "7dac2c5666815c17a3b36427de37bb9d2e2c5ccec3f8633eb91a4205cb4c10ff",
+		txid:       "f4184fc596403b9d638783cf57adfe4c75c605f6356fbc91338530e9831e9e16",
+		txids: []string{
+			"b1fea52486ce0c62bb442b530a3f0132b826c74e473d1f2c220bfa78111c5082",
+			"f4184fc596403b9d638783cf57adfe4c75c605f6356fbc91338530e9831e9e16",
+		},
+		proof: "0100000055bd840a78798ad0da853f68974f3d183e2bd1db6a842c1feecf222a00000000ff104ccb05421ab93e63f8c3ce5c2c2e9dbb37de2764b3a3175c8166562cac7d51b96a49ffff001d283e9e70" +
+			"020000000282501c1178fa0b222c1f3d474ec726b832013f0a532b44bb620cce8624a5feb1169e1e83e930853391bc6f35f605c6754cfead57cf8387639d3b4096c54f18f40105",
+	},
+	{
+		name:       "block 100000",
+		blockHash:  "000000000003ba27aa200b1cecaad478d2b00432346c3f1f3986da1afd33e506",
+		merkleRoot: "f3e94742aca4b5ef85488dc37c06c3282295ffec960994b2c0d5ac2a25a95766",
+		txid:       "6359f0868171b1d194cbee1af2f16ea598ae8fad666d9b012c8ed2b79a236ec4",
+		txids: []string{
+			"8c14f0db3df150123e6f3dbbf30f8b955a8249b62ac1d1ff16284aefa3d06d87",
+			"fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4",
+			"6359f0868171b1d194cbee1af2f16ea598ae8fad666d9b012c8ed2b79a236ec4",
+			"e9a66845e05d5abc0ad04ec80f774a7e585c6e8db975962d069a522137b80c1d",
+		},
+		proof: "0100000050120119172a610421a6c3011dd330d9df07b63616c2cc1f1cd00200000000006657a9252aacd5c0b2940996ecff952228c3067cc38d4885efb5a4ac4247e9f337221b4d4c86041b0f2b5710" +
+			"040000000315b88c5107195bf09eb9da89b83d95b3d070079a3c5c5d3d17d0dcd873fbdaccc46e239ab7d28e2c019b6d66ad8fae98a56ef1f21aeecb94d1b1718186f059631d0cb83721529a062d9675b98d6e5c587e4a770fc84ed00abc5a5de04568a6e9010d",
+	},
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func mustHash(t *testing.T, s string) [32]byte {
+	t.Helper()
+	hash, err := HashFromHex(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestVerifyMerkleProof(t *testing.T) {
+	for _, v := range merkleVectors {
+		t.Run(v.name, func(t *testing.T) {
+			proof := mustDecodeHex(t, v.proof)
+
+			mb, err := ParseMerkleBlock(proof)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := HashToHex(DoubleSHA256(mb.Header)); got != v.blockHash {
+				t.Errorf("block hash = %s, want %s", got, v.blockHash)
+			}
+			if got := HashToHex(mb.MerkleRoot()); got != v.merkleRoot {
+				t.Errorf("merkle root = %s, want %s", got, v.merkleRoot)
+			}
+
+			branch, err := VerifyMerkleProof(v.txid, proof, v.merkleRoot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := HashToHex(BranchRoot(mustHash(t, v.txid), branch)); got != v.merkleRoot {
+				t.Errorf("branch hashes to %s, want %s", got, v.merkleRoot)
+			}
+
+			_, matches, err := mb.ExtractMatches()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(matches) != 1 || HashToHex(matches[0]) != v.txid {
+				t.Errorf("matches = %v, want only %s", matches, v.txid)
+			}
+		})
+	}
+}
+
+func TestBuildMerkleBlock(t *testing.T) {
+	for _, v := range merkleVectors {
+		t.Run(v.name, func(t *testing.T) {
+			proof := mustDecodeHex(t, v.proof)
+			txids := make([][32]byte, len(v.txids))
+			for n, txid := range v.txids {
+				txids[n] = mustHash(t, txid)
+			}
+
+			got, err := BuildMerkleBlock(proof[:headerSize], txids, mustHash(t, v.txid))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, proof) {
+				t.Fatalf("built proof differs from the node's:\n got %x\nwant %x", got, proof)
+			}
+
+			// every transaction of the block can be proven
+			for _, txid := range v.txids {
+				built, err := BuildMerkleBlock(proof[:headerSize], txids, mustHash(t, txid))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := VerifyMerkleProof(txid, built, v.merkleRoot); err != nil {
+					t.Errorf("proof of %s: %v", txid, err)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyMerkleProofRejects(t *testing.T) {
+	v := merkleVectors[2]
+	proof := mustDecodeHex(t, v.proof)
+	hashesAt := headerSize + 4 + 1
+
+	t.Run("mutated branch", func(t *testing.T) {
+		mutated := bytes.Clone(proof)
+		mutated[hashesAt] ^= 1
+		if _, err := VerifyMerkleProof(v.txid, mutated, v.merkleRoot); err == nil {
+			t.Error("accepted a proof with a mutated branch hash")
+		}
+	})
+
+	t.Run("mutated txid", func(t *testing.T) {
+		mutated := bytes.Clone(proof)
+		mutated[hashesAt+32] ^= 1
+		if _, err := VerifyMerkleProof(v.txid, mutated, v.merkleRoot); err == nil {
+			t.Error("accepted a proof with a mutated txid")
+		}
+	})
+
+	t.Run("unmatched txid", func(t *testing.T) {
+		if _, err := VerifyMerkleProof(v.txids[0], proof, v.merkleRoot); err == nil {
+			t.Error("accepted a proof for a transaction it does not match")
+		}
+	})
+
+	t.Run("other root", func(t *testing.T) {
+		if _, err := VerifyMerkleProof(v.txid, proof, merkleVectors[1].merkleRoot); err == nil {
+			t.Error("accepted a proof against another merkle root")
+		}
+	})
+
+	t.Run("trailing bytes", func(t *testing.T) {
+		if _, err := VerifyMerkleProof(v.txid, append(bytes.Clone(proof), 0), v.merkleRoot); err == nil {
+			t.Error("accepted a proof with trailing bytes")
+		}
+	})
+}
+
+// TestVerifyMerkleProofRejectsDuplicatedBranch covers CVE-2012-2459: the
+// tree of transactions a, b, c has the same root as that of a, b, c, c, so
+// a proof must not pair a node with an identical explicit sibling.
+func TestVerifyMerkleProofRejectsDuplicatedBranch(t *testing.T) {
+	block := merkleVectors[2]
+	a := mustHash(t, block.txids[0])
+	b := mustHash(t, block.txids[1])
+	c := mustHash(t, block.txids[2])
+
+	root := hashPair(hashPair(a, b), hashPair(c, c))
+	header := bytes.Clone(mustDecodeHex(t, block.proof)[:headerSize])
+	copy(header[36:68], root[:])
+
+	honest, err := BuildMerkleBlock(header, [][32]byte{a, b, c}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyMerkleProof(HashToHex(c), honest, HashToHex(root)); err != nil {
+		t.Fatalf("rejected the honest proof: %v", err)
+	}
+
+	duplicated, err := BuildMerkleBlock(header, [][32]byte{a, b, c, c}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyMerkleProof(HashToHex(c), duplicated, HashToHex(root)); err == nil {
+		t.Error("accepted a proof with a duplicated branch")
+	}
+}
@@ -0,0 +1,321 @@
+package bitcoin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrNodeWrongChain = errors.New("node follows another chain")
+	ErrNodeSyncing    = errors.New("node is in initial block download")
+)
+
+// NodePool spreads the indexer over several bitcoind nodes. Calls go to the
+// primary node and fail over to the next one when it cannot be reached; RPC
+// errors are the node's answer and are returned as is. CheckHealth picks the
+// healthy node with the most work as primary.
+type NodePool struct {
+	// Chain is the chain name all nodes must report, unchecked if empty.
+	Chain string
+
+	mu    sync.Mutex
+	nodes []*poolNode
+	// order lists nodes by preference, the primary first.
+	order []*poolNode
+	// lastPrimary is the primary at the end of the previous health check.
+	lastPrimary *poolNode
+}
+
+type poolNode struct {
+	name    string
+	client  *RPCClient
+	healthy bool
+	work    *big.Int
+}
+
+// NodeStatus is the state of one node after a health check.
+type NodeStatus struct {
+	Node      string
+	Healthy   bool
+	Blocks    int64
+	Chainwork string
+	Err       error
+}
+
+// TipConflict reports nodes that have different blocks at the same height,
+// a sign that one of them is on a fork or eclipsed.
+type TipConflict struct {
+	Height int64
+	// Hashes maps nodes to their block at Height.
+	Hashes map[string]string
+}
+
+// NodePoolHealth is the result of CheckHealth.
+type NodePoolHealth struct {
+	Nodes   []NodeStatus
+	Primary string
+	// Switched is set when the primary changed since the previous check,
+	// either here or by a failover on a failed call.
+	Switched bool
+	Conflict *TipConflict
+}
+
+func NewNodePool(clients ...*RPCClient) *NodePool {
+	p := &NodePool{}
+	for _, client := range clients {
+		node := &poolNode{
+			name:    nodeName(client.URL),
+			client:  client,
+			healthy: true,
+			work:    new(big.Int),
+		}
+		p.nodes = append(p.nodes, node)
+	}
+	p.order = append([]*poolNode(nil), p.nodes...)
+	p.lastPrimary = p.order[0]
+	return p
+}
+
+// nodeName identifies a node in logs without its credentials.
+func nodeName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return u.Host
+}
+
+// CheckHealth asks every node for getblockchaininfo, makes the healthy node
+// with the most work the primary and compares the nodes' blocks at the
+// height of the lowest healthy tip.
+func (p *NodePool) CheckHealth(ctx context.Context) NodePoolHealth {
+	infos := make([]*BlockchainInfo, len(p.nodes))
+	errs := make([]error, len(p.nodes))
+
+	var wg sync.WaitGroup
+	for n, node := range p.nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			infos[n], errs[n] = node.client.GetBlockchainInfo(ctx)
+			if errs[n] != nil {
+				return
+			}
+			switch {
+			case p.Chain != "" && infos[n].Chain != p.Chain:
+				errs[n] = fmt.Errorf("%w: %s", ErrNodeWrongChain, infos[n].Chain)
+			case infos[n].InitialBlockDownload:
+				errs[n] = ErrNodeSyncing
+			}
+		}()
+	}
+	wg.Wait()
+
+	health := NodePoolHealth{Nodes: make([]NodeStatus, len(p.nodes))}
+	var healthy []int
+
+	p.mu.Lock()
+	for n, node := range p.nodes {
+		status := NodeStatus{Node: node.name, Err: errs[n]}
+		node.healthy = errs[n] == nil
+		if node.healthy {
+			status.Healthy = true
+			status.Blocks = infos[n].Blocks
+			status.Chainwork = infos[n].Chainwork
+			if _, ok := node.work.SetString(infos[n].Chainwork, 16); !ok {
+				node.work.SetInt64(0)
+			}
+			healthy = append(healthy, n)
+		}
+		health.Nodes[n] = status
+	}
+	p.reorder()
+	primary := p.order[0]
+	health.Primary = primary.name
+	health.Switched = primary != p.lastPrimary
+	p.lastPrimary = primary
+	p.mu.Unlock()
+
+	health.Conflict = p.findConflict(ctx, infos, healthy)
+	return health
+}
+
+// reorder sorts nodes healthy first, then by work. The primary keeps its
+// place on equal work, so nodes at the same tip do not take turns.
+func (p *NodePool) reorder() {
+	current := p.order[0]
+	sort.SliceStable(p.order, func(a, b int) bool {
+		na, nb := p.order[a], p.order[b]
+		if na.healthy != nb.healthy {
+			return na.healthy
+		}
+		if cmp := na.work.Cmp(nb.work); cmp != 0 {
+			return cmp > 0
+		}
+		return na == current && nb != current
+	})
+}
+
+// findConflict compares the blocks of the healthy nodes at the lowest of
+// their tips, where all of them must have one.
+func (p *NodePool) findConflict(ctx context.Context, infos []*BlockchainInfo, healthy []int) *TipConflict {
+	if len(healthy) < 2 {
+		return nil
+	}
+
+	height := infos[healthy[0]].Blocks
+	for _, n := range healthy {
+		height = min(height, infos[n].Blocks)
+	}
+
+	hashes := make(map[string]string, len(healthy))
+	distinct := make(map[string]struct{})
+	for _, n := range healthy {
+		hash := infos[n].BestBlockHash
+		if infos[n].Blocks != height {
+			var err error
+			if hash, err = p.nodes[n].client.GetBlockHash(ctx, height); err != nil {
+				// the node is checked again next time
+				continue
+			}
+		}
+		hashes[p.nodes[n].name] = hash
+		distinct[hash] = struct{}{}
+	}
+
+	if len(distinct) < 2 {
+		return nil
+	}
+	return &TipConflict{Height: height, Hashes: hashes}
+}
+
+// call runs fn on the primary and, when the node cannot be reached, on the
+// other nodes in order of preference. A node that failed is moved to the back
+// until the next health check.
+func (p *NodePool) call(ctx context.Context, fn func(*RPCClient) error) error {
+	p.mu.Lock()
+	order := append([]*poolNode(nil), p.order...)
+	p.mu.Unlock()
+
+	var err error
+	for _, node := range order {
+		err = fn(node.client)
+		if err == nil || !isNodeFailure(err) || ctx.Err() != nil {
+			return err
+		}
+		p.demote(node)
+	}
+	return err
+}
+
+// isNodeFailure reports whether err means the node could not answer, rather
+// than answered with an error.
+func isNodeFailure(err error) bool {
+	var rpcErr *RPCError
+	return !errors.As(err, &rpcErr)
+}
+
+func (p *NodePool) demote(node *poolNode) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	node.healthy = false
+	for n, other := range p.order {
+		if other == node {
+			p.order = append(append(p.order[:n:n], p.order[n+1:]...), node)
+			return
+		}
+	}
+}
+
+// poolCall is call for a method with a result.
+func poolCall[T any](ctx context.Context, p *NodePool, fn func(*RPCClient) (T, error)) (T, error) {
+	var res T
+	err := p.call(ctx, func(c *RPCClient) error {
+		var err error
+		res, err = fn(c)
+		return err
+	})
+	return res, err
+}
+
+// poolBatch is call for a batch method.
+func poolBatch[T any](ctx context.Context, p *NodePool, fn func(*RPCClient) ([]T, []error, error)) ([]T, []error, error) {
+	var (
+		res  []T
+		errs []error
+	)
+	err := p.call(ctx, func(c *RPCClient) error {
+		var err error
+		res, errs, err = fn(c)
+		return err
+	})
+	return res, errs, err
+}
+
+func (p *NodePool) GetBlockCount(ctx context.Context) (int64, error) {
+	return poolCall(ctx, p, func(c *RPCClient) (int64, error) { return c.GetBlockCount(ctx) })
+}
+
+func (p *NodePool) GetBlockHash(ctx context.Context, height int64) (string, error) {
+	return poolCall(ctx, p, func(c *RPCClient) (string, error) { return c.GetBlockHash(ctx, height) })
+}
+
+func (p *NodePool) GetBlockHeader(ctx context.Context, hash string) (*BlockHeader, error) {
+	return poolCall(ctx, p, func(c *RPCClient) (*BlockHeader, error) { return c.GetBlockHeader(ctx, hash) })
+}
+
+func (p *NodePool) GetBlock(ctx context.Context, hash string) ([]Transaction, error) {
+	return poolCall(ctx, p, func(c *RPCClient) ([]Transaction, error) { return c.GetBlock(ctx, hash) })
+}
+
+func (p *NodePool) GetTxOutProof(ctx context.Context, txid, blockhash string) ([]byte, error) {
+	return poolCall(ctx, p, func(c *RPCClient) ([]byte, error) { return c.GetTxOutProof(ctx, txid, blockhash) })
+}
+
+func (p *NodePool) GetRawTransaction(ctx context.Context, txid string) (*Transaction, error) {
+	return poolCall(ctx, p, func(c *RPCClient) (*Transaction, error) { return c.GetRawTransaction(ctx, txid) })
+}
+
+func (p *NodePool) GetRawMempool(ctx context.Context) ([]string, error) {
+	return poolCall(ctx, p, func(c *RPCClient) ([]string, error) { return c.GetRawMempool(ctx) })
+}
+
+func (p *NodePool) GetBlockFilter(ctx context.Context, hash string) ([]byte, error) {
+	return poolCall(ctx, p, func(c *RPCClient) ([]byte, error) { return c.GetBlockFilter(ctx, hash) })
+}
+
+func (p *NodePool) GetBlockchainInfo(ctx context.Context) (*BlockchainInfo, error) {
+	return poolCall(ctx, p, func(c *RPCClient) (*BlockchainInfo, error) { return c.GetBlockchainInfo(ctx) })
+}
+
+func (p *NodePool) ScanTxOutSet(ctx context.Context, descriptors []string) (*TxOutSetScan, error) {
+	return poolCall(ctx, p, func(c *RPCClient) (*TxOutSetScan, error) { return c.ScanTxOutSet(ctx, descriptors) })
+}
+
+// MaxBatchSize is the smallest batch size of the nodes, so batches fit
+// whichever node they fail over to.
+func (p *NodePool) MaxBatchSize() int {
+	size := p.nodes[0].client.MaxBatchSize()
+	for _, node := range p.nodes[1:] {
+		size = min(size, node.client.MaxBatchSize())
+	}
+	return size
+}
+
+func (p *NodePool) GetBlockHashes(ctx context.Context, heights []int64) ([]string, []error, error) {
+	return poolBatch(ctx, p, func(c *RPCClient) ([]string, []error, error) { return c.GetBlockHashes(ctx, heights) })
+}
+
+func (p *NodePool) GetBlockHeaders(ctx context.Context, hashes []string) ([]*BlockHeader, []error, error) {
+	return poolBatch(ctx, p, func(c *RPCClient) ([]*BlockHeader, []error, error) { return c.GetBlockHeaders(ctx, hashes) })
+}
+
+func (p *NodePool) GetTxOutProofs(ctx context.Context, txids []string, blockhash string) ([][]byte, []error, error) {
+	return poolBatch(ctx, p, func(c *RPCClient) ([][]byte, []error, error) { return c.GetTxOutProofs(ctx, txids, blockhash) })
+}
@@ -0,0 +1,112 @@
+package bitcoin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"testing"
+)
+
+// newChainNode starts a node at the tip of blocks, which maps heights to
+// block hashes, with chainwork as its total work.
+func newChainNode(t *testing.T, blocks map[int64]string, tip int64, chainwork string) *rpcNode {
+	t.Helper()
+	return newRPCNode(t, func(_ int, method string, params []json.RawMessage) rpcAnswer {
+		switch method {
+		case "getblockchaininfo":
+			return rpcAnswer{result: BlockchainInfo{
+				Chain:         "main",
+				Blocks:        tip,
+				Headers:       tip,
+				BestBlockHash: blocks[tip],
+				Chainwork:     chainwork,
+			}}
+		case "getblockcount":
+			return rpcAnswer{result: tip}
+		case "getblockhash":
+			var height int64
+			json.Unmarshal(params[0], &height)
+			if hash, ok := blocks[height]; ok && height <= tip {
+				return rpcAnswer{result: hash}
+			}
+			return rpcAnswer{status: http.StatusInternalServerError, err: &RPCError{Code: RPCErrInvalidParameter, Message: "Block height out of range"}}
+		}
+		return rpcAnswer{status: http.StatusNotFound, err: &RPCError{Code: -32601, Message: "Method not found"}}
+	})
+}
+
+func TestNodePoolPrefersMostWorkAndFailsOver(t *testing.T) {
+	blocks := map[int64]string{10: testBlockHash(10), 11: testBlockHash(11)}
+	behind := newChainNode(t, blocks, 10, "0a")
+	ahead := newChainNode(t, blocks, 11, "0b")
+	pool := NewNodePool(
+		NewRPCClient(behind.URL, "user", "pass", RPCConfig{}),
+		NewRPCClient(ahead.URL, "user", "pass", RPCConfig{}),
+	)
+	pool.Chain = "main"
+	ctx := context.Background()
+
+	health := pool.CheckHealth(ctx)
+	if health.Primary != nodeName(ahead.URL) || !health.Switched || health.Conflict != nil {
+		t.Fatalf("health = %+v, want a switch to the node with more work", health)
+	}
+	if count, err := pool.GetBlockCount(ctx); err != nil || count != 11 {
+		t.Errorf("block count = %d, %v, want 11 from the primary", count, err)
+	}
+
+	// the node's answer is returned, not taken as a failure
+	calls := behind.calls.Load()
+	if _, err := pool.GetBlockHash(ctx, 12); !errors.Is(err, ErrBlockNotFound) {
+		t.Errorf("err = %v, want ErrBlockNotFound", err)
+	}
+	if behind.calls.Load() != calls {
+		t.Error("failed over on an rpc error")
+	}
+
+	ahead.Close()
+	if count, err := pool.GetBlockCount(ctx); err != nil || count != 10 {
+		t.Errorf("block count = %d, %v, want 10 from the other node", count, err)
+	}
+
+	health = pool.CheckHealth(ctx)
+	if health.Primary != nodeName(behind.URL) || !health.Switched {
+		t.Errorf("health = %+v, want the node left as primary", health)
+	}
+	if status := health.Nodes[1]; status.Healthy || status.Err == nil {
+		t.Errorf("status of the node that went down = %+v", status)
+	}
+}
+
+func TestNodePoolReportsDivergingBlocks(t *testing.T) {
+	blocks := map[int64]string{10: testBlockHash(10), 11: testBlockHash(11)}
+	fork := maps.Clone(blocks)
+	fork[10] = testBlockHash(0xf10)
+	agrees := newChainNode(t, blocks, 11, "0b")
+	same := newChainNode(t, blocks, 10, "0a")
+	forked := newChainNode(t, fork, 10, "0a")
+	ctx := context.Background()
+
+	pool := NewNodePool(
+		NewRPCClient(agrees.URL, "user", "pass", RPCConfig{}),
+		NewRPCClient(same.URL, "user", "pass", RPCConfig{}),
+	)
+	if health := pool.CheckHealth(ctx); health.Conflict != nil {
+		t.Errorf("conflict = %+v between nodes on the same chain", health.Conflict)
+	}
+
+	// compared at the lower tip, where both nodes have a block
+	pool = NewNodePool(
+		NewRPCClient(agrees.URL, "user", "pass", RPCConfig{}),
+		NewRPCClient(forked.URL, "user", "pass", RPCConfig{}),
+	)
+	health := pool.CheckHealth(ctx)
+	want := map[string]string{
+		nodeName(agrees.URL): blocks[10],
+		nodeName(forked.URL): fork[10],
+	}
+	if c := health.Conflict; c == nil || c.Height != 10 || !maps.Equal(c.Hashes, want) {
+		t.Errorf("conflict = %+v, want %v at 10", health.Conflict, want)
+	}
+}
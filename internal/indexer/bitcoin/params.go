@@ -0,0 +1,150 @@
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+var ErrUnknownNetwork = errors.New("unknown network")
+
+// ChainParams holds the consensus rules headers are validated against.
+type ChainParams struct {
+	Name string
+	// Chain is the name bitcoind reports in getblockchaininfo.
+	Chain string
+	// PowLimit is the easiest target a block may have.
+	PowLimit *big.Int
+	// TargetTimespan and TargetSpacing define the retarget period, 2016
+	// blocks on every public network.
+	TargetTimespan time.Duration
+	TargetSpacing  time.Duration
+	// PowAllowMinDifficultyBlocks allows a block to use PowLimit when it
+	// comes more than twice the target spacing after its parent.
+	PowAllowMinDifficultyBlocks bool
+	// PowNoRetargeting keeps the difficulty fixed forever.
+	PowNoRetargeting bool
+	// Checkpoints are known good blocks, ordered by height. The genesis
+	// block is always the first one.
+	Checkpoints []Checkpoint
+	// PubKeyHashAddrID, ScriptHashAddrID and Bech32HRP are the address
+	// prefixes of the network.
+	PubKeyHashAddrID byte
+	ScriptHashAddrID byte
+	Bech32HRP        string
+	// HDPublicKeyID is the version of the network's xpub or tpub keys.
+	HDPublicKeyID uint32
+	// AllowMissingProofs indexes transactions whose merkle proof fails to
+	// verify instead of rejecting them, for test chains only.
+	AllowMissingProofs bool
+}
+
+// RetargetInterval is the number of blocks between difficulty adjustments.
+func (p *ChainParams) RetargetInterval() int64 {
+	return int64(p.TargetTimespan / p.TargetSpacing)
+}
+
+var MainNetParams = ChainParams{
+	Name:           "mainnet",
+	Chain:          "main",
+	PowLimit:       mustTarget("00000000ffffffffffffffffffffffffffffffffffffffffffffffffffffffff"),
+	TargetTimespan: 14 * 24 * time.Hour,
+	TargetSpacing:  10 * time.Minute,
+	Checkpoints: []Checkpoint{
+		{0, "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"},
+		{11111, "0000000069e244f73d78e8fd29ba2fd2ed618bd6fa2ee92559f542fdb26e7c1d"},
+		{33333, "000000002dd5588a74784eaa7ab0507a18ad16a236e7b1ce69f00d7ddfb5d0a6"},
+		{74000, "0000000000573993a3c9e41ce34471c079dcf5f52a0e824a81e7f953b8661a20"},
+		{105000, "00000000000291ce28027faea320c8d2b054b2e0fe44a773f3eefb151d6bdc97"},
+		{134444, "00000000000005b12ffd4cd315cd34ffd4a594f430ac814c91184a0d42d2b0fe"},
+		{168000, "000000000000099e61ea72015e79632f216fe6cb33d7899acb35b75c8303b763"},
+		{193000, "000000000000059f452a5f7340de6682a977387c17010ff6e6c3bd83ca8b1317"},
+		{210000, "000000000000048b95347e83192f69cf0366076336c639f9b7228e9ba171342e"},
+		{216116, "00000000000001b4f4b433e81ee46494af945cf96014816a4e2370f11b23df4e"},
+		{225430, "00000000000001c108384350f74090433e7fcf79a606b8e797f065b130575932"},
+		{250000, "000000000000003887df1f29024b06fc2200b55f8af8f35453d7be294df2d214"},
+		{279000, "0000000000000001ae8c72a0b0c301f67e3afca10e819efa9041e458e9bd7e40"},
+		{295000, "00000000000000004d9b4ef50f0f9d686fd69db2e03af35a100370c64632a983"},
+	},
+	PubKeyHashAddrID: versionMainPubKeyHash,
+	ScriptHashAddrID: versionMainScriptHash,
+	Bech32HRP:        "bc",
+	HDPublicKeyID:    versionMainPubKey,
+}
+
+var TestNetParams = ChainParams{
+	Name:                        "testnet",
+	Chain:                       "test",
+	PowLimit:                    mustTarget("00000000ffffffffffffffffffffffffffffffffffffffffffffffffffffffff"),
+	TargetTimespan:              14 * 24 * time.Hour,
+	TargetSpacing:               10 * time.Minute,
+	PowAllowMinDifficultyBlocks: true,
+	Checkpoints: []Checkpoint{
+		{0, "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943"},
+		{546, "000000002a936ca763904c3c35fce2f3556c559c0214345d31b1bcebf76acb70"},
+	},
+	PubKeyHashAddrID: versionTestPubKeyHash,
+	ScriptHashAddrID: versionTestScriptHash,
+	Bech32HRP:        "tb",
+	HDPublicKeyID:    versionTestPubKey,
+}
+
+var SigNetParams = ChainParams{
+	Name:           "signet",
+	Chain:          "signet",
+	PowLimit:       mustTarget("00000377ae000000000000000000000000000000000000000000000000000000"),
+	TargetTimespan: 14 * 24 * time.Hour,
+	TargetSpacing:  10 * time.Minute,
+	Checkpoints: []Checkpoint{
+		{0, "00000008819873e925422c1ff0f99f7cc9bbb232af63a077a480a3633bee1ef6"},
+	},
+	PubKeyHashAddrID: versionTestPubKeyHash,
+	ScriptHashAddrID: versionTestScriptHash,
+	Bech32HRP:        "tb",
+	HDPublicKeyID:    versionTestPubKey,
+}
+
+var RegTestParams = ChainParams{
+	Name:                        "regtest",
+	Chain:                       "regtest",
+	PowLimit:                    mustTarget("7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"),
+	TargetTimespan:              14 * 24 * time.Hour,
+	TargetSpacing:               10 * time.Minute,
+	PowAllowMinDifficultyBlocks: true,
+	PowNoRetargeting:            true,
+	Checkpoints: []Checkpoint{
+		{0, "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206"},
+	},
+	PubKeyHashAddrID:   versionTestPubKeyHash,
+	ScriptHashAddrID:   versionTestScriptHash,
+	Bech32HRP:          "bcrt",
+	HDPublicKeyID:      versionTestPubKey,
+	AllowMissingProofs: true,
+}
+
+// Networks are the parameters of the supported networks, by name.
+var Networks = map[string]*ChainParams{
+	MainNetParams.Name: &MainNetParams,
+	TestNetParams.Name: &TestNetParams,
+	SigNetParams.Name:  &SigNetParams,
+	RegTestParams.Name: &RegTestParams,
+}
+
+// ParamsForNetwork returns the parameters of a network: mainnet, testnet,
+// signet or regtest.
+func ParamsForNetwork(name string) (*ChainParams, error) {
+	params, ok := Networks[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownNetwork, name)
+	}
+	return params, nil
+}
+
+func mustTarget(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("invalid target " + s)
+	}
+	return n
+}
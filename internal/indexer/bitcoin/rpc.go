@@ -2,83 +2,393 @@ package bitcoin
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
+// RPC error codes of bitcoind the client gives meaning to.
+const (
+	RPCErrInvalidAddressOrKey = -5
+	RPCErrInvalidParameter    = -8
+	RPCErrInWarmup            = -28
+)
+
+// Defaults for the zero values of RPCConfig.
+const (
+	defaultRPCTimeout      = 10 * time.Second
+	defaultRPCRetryBackoff = 500 * time.Millisecond
+	maxRPCRetryBackoff     = 30 * time.Second
+	defaultRPCMaxBatchSize = 100
+)
+
+// RPCError is an error returned by the node in a JSON-RPC response.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// Is matches the not found errors of BlockSource: bitcoind answers -5 for an
+// unknown block hash or transaction and -8 for a height above its tip.
+func (e *RPCError) Is(target error) bool {
+	switch target {
+	case ErrBlockNotFound:
+		return e.Code == RPCErrInvalidAddressOrKey || e.Code == RPCErrInvalidParameter
+	case ErrTxNotFound:
+		return e.Code == RPCErrInvalidAddressOrKey
+	}
+	return false
+}
+
+// RPCConfig tunes how the client talks to the node.
+type RPCConfig struct {
+	// Timeout bounds each attempt of a call, 10s if zero.
+	Timeout time.Duration
+	// Retries is how many times a call failing with a transient error is
+	// repeated before giving up.
+	Retries int
+	// RetryBackoff is the delay before the first retry, doubled on each
+	// following one and jittered. 500ms if zero.
+	RetryBackoff time.Duration
+	// MaxBatchSize caps the calls sent in one JSON-RPC batch, 100 if zero.
+	MaxBatchSize int
+	// CookieFile is the .cookie file of the node, used instead of user and
+	// password when set.
+	CookieFile string
+	// TLS is used for https node URLs, such as behind a TLS terminating
+	// proxy with a private CA or client certificates.
+	TLS *tls.Config
+	// Wallet sends calls to /wallet/<name>, for nodes with several wallets
+	// loaded.
+	Wallet string
+}
+
 type RPCClient struct {
 	URL      string
 	User     string
 	Password string
 	Client   *http.Client
+	Config   RPCConfig
+
+	cookie *cookieAuth
 }
 
-func NewRPCClient(url, user, pass string) *RPCClient {
-	return &RPCClient{
-		URL:      url,
+func NewRPCClient(rawURL, user, pass string, cfg RPCConfig) *RPCClient {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultRPCTimeout
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultRPCRetryBackoff
+	}
+	if cfg.MaxBatchSize <= 0 {
+		cfg.MaxBatchSize = defaultRPCMaxBatchSize
+	}
+
+	if cfg.Wallet != "" {
+		rawURL = strings.TrimRight(rawURL, "/") + "/wallet/" + url.PathEscape(cfg.Wallet)
+	}
+
+	client := &http.Client{}
+	if cfg.TLS != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = cfg.TLS
+		client.Transport = transport
+	}
+
+	c := &RPCClient{
+		URL:      rawURL,
 		User:     user,
 		Password: pass,
-		Client:   &http.Client{Timeout: 10 * time.Second},
+		Client:   client,
+		Config:   cfg,
+	}
+	if cfg.CookieFile != "" {
+		// read on first use, the node may not have written it yet
+		c.cookie = &cookieAuth{path: cfg.CookieFile}
+	}
+	return c
+}
+
+// Call runs method on the node and decodes its result into result. Transport
+// failures, 5xx responses without an RPC error and a node still warming up
+// are retried with backoff; RPC errors come back as *RPCError.
+func (c *RPCClient) Call(ctx context.Context, method string, params []any, result any) error {
+	body, err := json.Marshal(rpcRequest{
+		JSONRPC: "1.0",
+		ID:      "indexer",
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", method, err)
+	}
+
+	var raw json.RawMessage
+	err = c.retry(ctx, method, func() error {
+		status, respBody, err := c.post(ctx, body)
+		if err != nil {
+			return err
+		}
+		raw, err = decodeResponse(status, respBody)
+		return err
+	})
+	if err != nil || result == nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      any    `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+type rpcResponse struct {
+	ID     any             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
+
+// retry runs attempt until it succeeds, fails for good or runs out of
+// retries, sleeping a jittered exponential backoff in between.
+func (c *RPCClient) retry(ctx context.Context, method string, attempt func() error) error {
+	backoff := c.Config.RetryBackoff
+	for n := 0; ; n++ {
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+
+		if n >= c.Config.Retries || !isTransient(err) || ctx.Err() != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+
+		// full jitter over the upper half, so clients restarted together
+		// do not retry in lockstep
+		wait := backoff/2 + rand.N(backoff/2+1)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", method, ctx.Err())
+		case <-time.After(wait):
+		}
+		backoff = min(2*backoff, maxRPCRetryBackoff)
 	}
 }
 
-func (c *RPCClient) Call(method string, params []any, result any) error {
-	payload := map[string]any{
-		"jsonrpc": "1.0",
-		"id":      "indexer",
-		"method":  method,
-		"params":  params,
+// statusError is a non-2xx response that carried no RPC error.
+type statusError struct {
+	Status int
+	Body   string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("node returned http %d: %s", e.Status, e.Body)
+}
+
+// post sends one HTTP request, bounded by Timeout, and returns the response.
+// With a cookie file, a 401 re-reads the cookie and, if the node restarted
+// with new credentials, sends the request once more.
+func (c *RPCClient) post(ctx context.Context, body []byte) (int, []byte, error) {
+	user, pass := c.User, c.Password
+	if c.cookie != nil {
+		var err error
+		if user, pass, err = c.cookie.credentials(); err != nil {
+			return 0, nil, err
+		}
 	}
 
-	body, _ := json.Marshal(payload)
-	req, _ := http.NewRequest("POST", c.URL, bytes.NewReader(body))
-	req.SetBasicAuth(c.User, c.Password)
+	status, respBody, err := c.send(ctx, body, user, pass)
+	if err != nil || status != http.StatusUnauthorized || c.cookie == nil {
+		return status, respBody, err
+	}
+
+	changed, err := c.cookie.reload(user, pass)
+	if err != nil || !changed {
+		return status, respBody, err
+	}
+	user, pass, _ = c.cookie.credentials()
+	return c.send(ctx, body, user, pass)
+}
+
+func (c *RPCClient) send(ctx context.Context, body []byte, user, pass string) (int, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.SetBasicAuth(user, pass)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.Client.Do(req)
 	if err != nil {
-		return err
+		return 0, nil, err
 	}
 	defer resp.Body.Close()
 
-	var rpcResp struct {
-		Result json.RawMessage `json:"result"`
-		Error  *struct {
-			Code    int    `json:"code"`
-			Message string `json:"message"`
-		} `json:"error"`
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
-		return err
+	return resp.StatusCode, respBody, nil
+}
+
+// decodeResponse returns the result of a single call. bitcoind answers RPC
+// errors with 404 or 500 and a JSON-RPC body, so the body is tried before
+// the status.
+func decodeResponse(status int, body []byte) (json.RawMessage, error) {
+	var resp rpcResponse
+	if err := json.Unmarshal(body, &resp); err == nil {
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		if status == http.StatusOK {
+			return resp.Result, nil
+		}
+	}
+	if status != http.StatusOK {
+		return nil, &statusError{Status: status, Body: strings.TrimSpace(string(body))}
+	}
+	return nil, fmt.Errorf("malformed response: %q", truncate(body, 200))
+}
+
+// isTransient reports whether a failed call may succeed if repeated.
+func isTransient(err error) bool {
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code == RPCErrInWarmup
+	}
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Status >= 500
 	}
-	if rpcResp.Error != nil {
-		return errors.New(rpcResp.Error.Message)
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	// transport errors and attempts cut off by Timeout
+	return true
+}
+
+func truncate(b []byte, n int) string {
+	if len(b) > n {
+		return string(b[:n]) + "..."
 	}
-	return json.Unmarshal(rpcResp.Result, result)
+	return string(b)
 }
 
-func (c *RPCClient) GetBlockHeader(hash string) (*BlockHeader, error) {
+func (c *RPCClient) GetBlockCount(ctx context.Context) (int64, error) {
+	var count int64
+	err := c.Call(ctx, "getblockcount", []any{}, &count)
+	return count, err
+}
+
+func (c *RPCClient) GetBlockHash(ctx context.Context, height int64) (string, error) {
+	var hash string
+	err := c.Call(ctx, "getblockhash", []any{height}, &hash)
+	return hash, err
+}
+
+func (c *RPCClient) GetBlockHeader(ctx context.Context, hash string) (*BlockHeader, error) {
 	var header BlockHeader
-	err := c.Call("getblockheader", []any{hash}, &header)
+	err := c.Call(ctx, "getblockheader", []any{hash}, &header)
 	return &header, err
 }
 
-func (c *RPCClient) GetBlock(hash string) ([]Transaction, error) {
+// GetBlock fetches block transactions with verbosity 3, so inputs carry their
+// prevouts. Nodes older than v23 ignore the extra level and answer as for 2.
+func (c *RPCClient) GetBlock(ctx context.Context, hash string) ([]Transaction, error) {
 	var block struct {
 		Tx []Transaction `json:"tx"`
 	}
-	err := c.Call("getblock", []any{hash, 2}, &block)
+	err := c.Call(ctx, "getblock", []any{hash, 3}, &block)
 	return block.Tx, err
 }
 
-func (c *RPCClient) GetTxOutProof(txid, blockhash string) ([]byte, error) {
+func (c *RPCClient) GetRawTransaction(ctx context.Context, txid string) (*Transaction, error) {
+	var tx Transaction
+	err := c.Call(ctx, "getrawtransaction", []any{txid, true}, &tx)
+	if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+func (c *RPCClient) GetTxOutProof(ctx context.Context, txid, blockhash string) ([]byte, error) {
 	var proofHex string
-	err := c.Call("gettxoutproof", []any{[]string{txid}, blockhash}, &proofHex)
+	err := c.Call(ctx, "gettxoutproof", []any{[]string{txid}, blockhash}, &proofHex)
 	if err != nil {
 		return nil, err
 	}
 	return hex.DecodeString(proofHex)
 }
+
+func (c *RPCClient) GetRawMempool(ctx context.Context) ([]string, error) {
+	var txids []string
+	err := c.Call(ctx, "getrawmempool", []any{}, &txids)
+	return txids, err
+}
+
+// GetBlockFilter returns the BIP158 basic filter of a block. The node has to
+// run with -blockfilterindex.
+func (c *RPCClient) GetBlockFilter(ctx context.Context, hash string) ([]byte, error) {
+	var res struct {
+		Filter string `json:"filter"`
+	}
+	err := c.Call(ctx, "getblockfilter", []any{hash, "basic"}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(res.Filter)
+}
+
+// GetBlockchainInfo returns the node's view of the chain it follows.
+func (c *RPCClient) GetBlockchainInfo(ctx context.Context) (*BlockchainInfo, error) {
+	var info BlockchainInfo
+	if err := c.Call(ctx, "getblockchaininfo", []any{}, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// scanTxOutSetTimeout bounds scantxoutset, which walks the whole UTXO set and
+// takes minutes on mainnet.
+const scanTxOutSetTimeout = 10 * time.Minute
+
+// ScanTxOutSet returns the unspent outputs matching descriptors, such as
+// "addr(<address>)". The node runs one scan at a time.
+func (c *RPCClient) ScanTxOutSet(ctx context.Context, descriptors []string) (*TxOutSetScan, error) {
+	// a scan is not repeated, the node refuses a second one while the
+	// first is still running
+	slow := *c
+	slow.Config.Timeout = scanTxOutSetTimeout
+	slow.Config.Retries = 0
+
+	var scan TxOutSetScan
+	err := slow.Call(ctx, "scantxoutset", []any{"start", descriptors}, &scan)
+	if err != nil {
+		return nil, err
+	}
+	if !scan.Success {
+		return nil, errors.New("scantxoutset did not complete")
+	}
+	return &scan, nil
+}
@@ -0,0 +1,171 @@
+package bitcoin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testTimeout = 5 * time.Second
+
+// rpcNode is a bitcoind stand-in answering single JSON-RPC calls with
+// answer, which returns the HTTP status and either a result or an RPC error.
+type rpcNode struct {
+	*httptest.Server
+	calls atomic.Int32
+}
+
+type rpcAnswer struct {
+	status int
+	result any
+	err    *RPCError
+	// body replaces the JSON-RPC response, such as for a proxy error page
+	body string
+}
+
+func newRPCNode(t *testing.T, answer func(n int, method string, params []json.RawMessage) rpcAnswer) *rpcNode {
+	t.Helper()
+	node := &rpcNode{}
+	node.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a := answer(int(node.calls.Add(1)), req.Method, req.Params)
+		writeRPCAnswer(w, a, "indexer")
+	}))
+	t.Cleanup(node.Close)
+	return node
+}
+
+func writeRPCAnswer(w http.ResponseWriter, a rpcAnswer, id any) {
+	if a.status == 0 {
+		a.status = http.StatusOK
+	}
+	w.WriteHeader(a.status)
+	if a.body != "" {
+		io.WriteString(w, a.body)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"id": id, "result": a.result, "error": a.err})
+}
+
+func TestRPCRetriesTransientErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		failure rpcAnswer
+	}{
+		{"proxy 503", rpcAnswer{status: http.StatusServiceUnavailable, body: "<html>bad gateway</html>"}},
+		{"warmup", rpcAnswer{status: http.StatusInternalServerError, err: &RPCError{Code: RPCErrInWarmup, Message: "Loading block index..."}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := newRPCNode(t, func(n int, _ string, _ []json.RawMessage) rpcAnswer {
+				if n < 3 {
+					return tt.failure
+				}
+				return rpcAnswer{result: 850000}
+			})
+			c := NewRPCClient(node.URL, "user", "pass", RPCConfig{Retries: 2, RetryBackoff: time.Millisecond})
+
+			count, err := c.GetBlockCount(context.Background())
+			if err != nil || count != 850000 {
+				t.Fatalf("GetBlockCount = %d, %v, want 850000", count, err)
+			}
+			if calls := node.calls.Load(); calls != 3 {
+				t.Errorf("node called %d times, want 3", calls)
+			}
+		})
+	}
+}
+
+func TestRPCGivesUpAfterRetries(t *testing.T) {
+	node := newRPCNode(t, func(int, string, []json.RawMessage) rpcAnswer {
+		return rpcAnswer{status: http.StatusBadGateway, body: "bad gateway"}
+	})
+	c := NewRPCClient(node.URL, "user", "pass", RPCConfig{Retries: 2, RetryBackoff: time.Millisecond})
+
+	_, err := c.GetBlockCount(context.Background())
+	var statusErr *statusError
+	if !errors.As(err, &statusErr) || statusErr.Status != http.StatusBadGateway {
+		t.Errorf("err = %v, want the 502 of the last attempt", err)
+	}
+	if calls := node.calls.Load(); calls != 3 {
+		t.Errorf("node called %d times, want 3", calls)
+	}
+}
+
+func TestRPCReturnsTypedErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		code          int
+		blockNotFound bool
+		txNotFound    bool
+	}{
+		{"unknown hash", RPCErrInvalidAddressOrKey, true, true},
+		{"height out of range", RPCErrInvalidParameter, true, false},
+		{"other", -1, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// bitcoind answers RPC errors with a 500 or 404
+			node := newRPCNode(t, func(int, string, []json.RawMessage) rpcAnswer {
+				return rpcAnswer{status: http.StatusNotFound, err: &RPCError{Code: tt.code, Message: "no"}}
+			})
+			c := NewRPCClient(node.URL, "user", "pass", RPCConfig{Retries: 3, RetryBackoff: time.Millisecond})
+
+			_, err := c.GetBlockHash(context.Background(), 1)
+			var rpcErr *RPCError
+			if !errors.As(err, &rpcErr) || rpcErr.Code != tt.code {
+				t.Fatalf("err = %v, want rpc error %d", err, tt.code)
+			}
+			if errors.Is(err, ErrBlockNotFound) != tt.blockNotFound || errors.Is(err, ErrTxNotFound) != tt.txNotFound {
+				t.Errorf("err = %v, block not found %t, tx not found %t", err,
+					errors.Is(err, ErrBlockNotFound), errors.Is(err, ErrTxNotFound))
+			}
+			if calls := node.calls.Load(); calls != 1 {
+				t.Errorf("node called %d times, want an answer taken as is", calls)
+			}
+		})
+	}
+}
+
+func TestRPCStopsBackingOffOnCancel(t *testing.T) {
+	failed := make(chan struct{}, 1)
+	node := newRPCNode(t, func(int, string, []json.RawMessage) rpcAnswer {
+		failed <- struct{}{}
+		return rpcAnswer{status: http.StatusServiceUnavailable, body: "unavailable"}
+	})
+	c := NewRPCClient(node.URL, "user", "pass", RPCConfig{Retries: 5, RetryBackoff: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := c.GetBlockCount(ctx)
+		done <- err
+	}()
+
+	<-failed
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want context.Canceled", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("call kept backing off after its context was cancelled")
+	}
+	if calls := node.calls.Load(); calls != 1 {
+		t.Errorf("node called %d times, want 1", calls)
+	}
+}
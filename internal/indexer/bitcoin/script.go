@@ -0,0 +1,147 @@
+package bitcoin
+
+// Output script types, named as bitcoind reports them in scriptPubKey.type.
+const (
+	ScriptTypePubKey              = "pubkey"
+	ScriptTypePubKeyHash          = "pubkeyhash"
+	ScriptTypeScriptHash          = "scripthash"
+	ScriptTypeMultisig            = "multisig"
+	ScriptTypeNullData            = "nulldata"
+	ScriptTypeWitnessV0KeyHash    = "witness_v0_keyhash"
+	ScriptTypeWitnessV0ScriptHash = "witness_v0_scripthash"
+	ScriptTypeWitnessV1Taproot    = "witness_v1_taproot"
+	ScriptTypeWitnessUnknown      = "witness_unknown"
+	ScriptTypeNonStandard         = "nonstandard"
+)
+
+const (
+	opReturn        = 0x6a
+	opPushData1     = 0x4c
+	opPushData4     = 0x4e
+	op16            = 0x60
+	opCheckMultiSig = 0xae
+)
+
+// ClassifyScript returns the type of an output script.
+func ClassifyScript(script []byte) string {
+	n := len(script)
+	switch {
+	case n == 25 && script[0] == opDup && script[1] == opHash160 && script[2] == 20 &&
+		script[23] == opEqualVerify && script[24] == opCheckSig:
+		return ScriptTypePubKeyHash
+	case n == 23 && script[0] == opHash160 && script[1] == 20 && script[22] == opEqual:
+		return ScriptTypeScriptHash
+	case n == 22 && script[0] == op0 && script[1] == 20:
+		return ScriptTypeWitnessV0KeyHash
+	case n == 34 && script[0] == op0 && script[1] == 32:
+		return ScriptTypeWitnessV0ScriptHash
+	case n == 34 && script[0] == op1 && script[1] == 32:
+		return ScriptTypeWitnessV1Taproot
+	case isWitnessProgram(script):
+		return ScriptTypeWitnessUnknown
+	case (n == 35 && script[0] == 33 || n == 67 && script[0] == 65) && script[n-1] == opCheckSig:
+		return ScriptTypePubKey
+	case n > 0 && script[0] == opReturn && isPushOnly(script[1:]):
+		return ScriptTypeNullData
+	case isMultisig(script):
+		return ScriptTypeMultisig
+	}
+	return ScriptTypeNonStandard
+}
+
+// ScriptAddress returns the address an output script pays to and the script
+// type. Scripts without an address, such as bare multisig and OP_RETURN,
+// return an empty address. A P2PK output is given the P2PKH address of its
+// key, the one wallets show for it.
+func (p *ChainParams) ScriptAddress(script []byte) (string, string) {
+	typ := ClassifyScript(script)
+
+	var address string
+	var err error
+	switch typ {
+	case ScriptTypePubKeyHash:
+		address = encodeBase58Check(append([]byte{p.PubKeyHashAddrID}, script[3:23]...))
+	case ScriptTypeScriptHash:
+		address = encodeBase58Check(append([]byte{p.ScriptHashAddrID}, script[2:22]...))
+	case ScriptTypePubKey:
+		address = encodeBase58Check(append([]byte{p.PubKeyHashAddrID}, Hash160(script[1:len(script)-1])...))
+	case ScriptTypeWitnessV0KeyHash, ScriptTypeWitnessV0ScriptHash, ScriptTypeWitnessV1Taproot, ScriptTypeWitnessUnknown:
+		address, err = encodeSegwit(p.Bech32HRP, witnessVersion(script[0]), script[2:])
+	}
+	if err != nil {
+		return "", typ
+	}
+	return address, typ
+}
+
+// isWitnessProgram reports whether script is a version byte followed by a
+// single 2 to 40 byte push.
+func isWitnessProgram(script []byte) bool {
+	n := len(script)
+	if n < 4 || n > 42 || (script[0] != op0 && (script[0] < op1 || script[0] > op16)) {
+		return false
+	}
+	return int(script[1])+2 == n
+}
+
+func witnessVersion(op byte) byte {
+	if op == op0 {
+		return 0
+	}
+	return op - op1 + 1
+}
+
+// isMultisig matches OP_m <pubkey>... OP_n OP_CHECKMULTISIG.
+func isMultisig(script []byte) bool {
+	n := len(script)
+	if n < 3 || script[n-1] != opCheckMultiSig {
+		return false
+	}
+	m, keys := script[0], script[n-2]
+	if m < op1 || m > op16 || keys < m || keys > op16 {
+		return false
+	}
+
+	count := 0
+	for rest := script[1 : n-2]; len(rest) > 0; count++ {
+		size := int(rest[0])
+		if (size != 33 && size != 65) || len(rest) < size+1 {
+			return false
+		}
+		rest = rest[size+1:]
+	}
+	return count == int(keys-op1+1)
+}
+
+// isPushOnly reports whether script only pushes data.
+func isPushOnly(script []byte) bool {
+	for len(script) > 0 {
+		op := script[0]
+		script = script[1:]
+
+		var size int
+		switch {
+		case op == op0 || (op >= op1 && op <= op16) || op == 0x4f:
+			continue
+		case op < opPushData1:
+			size = int(op)
+		case op <= opPushData4:
+			width := 1 << (op - opPushData1)
+			if len(script) < width {
+				return false
+			}
+			for k := width - 1; k >= 0; k-- {
+				size = size<<8 | int(script[k])
+			}
+			script = script[width:]
+		default:
+			return false
+		}
+
+		if len(script) < size {
+			return false
+		}
+		script = script[size:]
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package bitcoin
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestClassifyScript(t *testing.T) {
+	const (
+		// the keys of G and 2G
+		key1 = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+		key2 = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+	)
+
+	tests := []struct {
+		name    string
+		script  string
+		params  *ChainParams
+		typ     string
+		address string
+	}{
+		{"p2pkh", "76a91477bff20c60e522dfaa3350c39b030a5d004e839a88ac", &MainNetParams, ScriptTypePubKeyHash, "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"},
+		{"p2pkh testnet", "76a914243f1394f44554f4ce3fd68649c19adc483ce92488ac", &TestNetParams, ScriptTypePubKeyHash, "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn"},
+		{"p2sh", "a914b472a266d0bd89c13706a4132ccfb16f7c3b9fcb87", &MainNetParams, ScriptTypeScriptHash, "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy"},
+		{"p2wpkh", "0014751e76e8199196d454941c45d1b3a323f1433bd6", &MainNetParams, ScriptTypeWitnessV0KeyHash, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{"p2wpkh regtest", "0014751e76e8199196d454941c45d1b3a323f1433bd6", &RegTestParams, ScriptTypeWitnessV0KeyHash, "bcrt1qw508d6qejxtdg4y5r3zarvary0c5xw7kygt080"},
+		{"p2wsh", "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262", &TestNetParams, ScriptTypeWitnessV0ScriptHash, "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7"},
+		{"p2tr", "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", &MainNetParams, ScriptTypeWitnessV1Taproot, "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"},
+		{"witness v16", "6002751e", &MainNetParams, ScriptTypeWitnessUnknown, "bc1sw50qgdz25j"},
+		{"witness v2", "5210751e76e8199196d454941c45d1b3a323", &MainNetParams, ScriptTypeWitnessUnknown, "bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs"},
+		// the genesis coinbase, shown as the P2PKH address of its key
+		{"p2pk", "4104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac", &MainNetParams, ScriptTypePubKey, "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"},
+		{"op_return", "6a0b68656c6c6f20776f726c64", &MainNetParams, ScriptTypeNullData, ""},
+		{"bare op_return", "6a", &MainNetParams, ScriptTypeNullData, ""},
+		{"op_return pushdata1", "6a4c0401020304", &MainNetParams, ScriptTypeNullData, ""},
+		{"op_return with an opcode", "6a76", &MainNetParams, ScriptTypeNonStandard, ""},
+		{"multisig 1 of 2", "5121" + key1 + "21" + key2 + "52ae", &MainNetParams, ScriptTypeMultisig, ""},
+		{"multisig with a missing key", "5221" + key1 + "52ae", &MainNetParams, ScriptTypeNonStandard, ""},
+		{"one byte witness program", "000100", &MainNetParams, ScriptTypeNonStandard, ""},
+		{"truncated p2pkh", "76a91477bff20c60e522dfaa3350c39b030a5d004e839a88", &MainNetParams, ScriptTypeNonStandard, ""},
+		{"empty", "", &MainNetParams, ScriptTypeNonStandard, ""},
+	}
+
+	for _, tt := range tests {
+		script, err := hex.DecodeString(tt.script)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if typ := ClassifyScript(script); typ != tt.typ {
+			t.Errorf("%s: type = %s, want %s", tt.name, typ, tt.typ)
+		}
+		address, typ := tt.params.ScriptAddress(script)
+		if address != tt.address || typ != tt.typ {
+			t.Errorf("%s: address = %q (%s), want %q", tt.name, address, typ, tt.address)
+		}
+	}
+}
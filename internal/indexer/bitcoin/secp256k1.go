@@ -0,0 +1,129 @@
+package bitcoin
+
+import (
+	"errors"
+	"math/big"
+)
+
+// secp256k1 curve y^2 = x^3 + 7 over the field of size curveP. Only the
+// public key operations needed for address derivation are implemented; none
+// of them handle secrets, so they need not be constant time.
+var (
+	curveP = mustTarget("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f")
+	curveN = mustTarget("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141")
+	curveG = &point{
+		x: mustTarget("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"),
+		y: mustTarget("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"),
+	}
+	curveB = big.NewInt(7)
+)
+
+var ErrInvalidPubKey = errors.New("invalid public key")
+
+// point is an affine curve point, nil is the point at infinity.
+type point struct {
+	x, y *big.Int
+}
+
+func (a *point) add(b *point) *point {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.x.Cmp(b.x) == 0:
+		if a.y.Cmp(b.y) != 0 || a.y.Sign() == 0 {
+			return nil
+		}
+		return a.double()
+	}
+
+	// lambda = (by - ay) / (bx - ax)
+	num := new(big.Int).Sub(b.y, a.y)
+	den := new(big.Int).Sub(b.x, a.x)
+	den.ModInverse(den.Mod(den, curveP), curveP)
+	lambda := num.Mul(num, den)
+	lambda.Mod(lambda, curveP)
+	return a.withSlope(b, lambda)
+}
+
+func (a *point) double() *point {
+	if a == nil || a.y.Sign() == 0 {
+		return nil
+	}
+
+	// lambda = 3 ax^2 / 2 ay
+	num := new(big.Int).Mul(a.x, a.x)
+	num.Mul(num, big.NewInt(3))
+	den := new(big.Int).Lsh(a.y, 1)
+	den.ModInverse(den.Mod(den, curveP), curveP)
+	lambda := num.Mul(num, den)
+	lambda.Mod(lambda, curveP)
+	return a.withSlope(a, lambda)
+}
+
+// withSlope returns a + b given the slope of the line through them.
+func (a *point) withSlope(b *point, lambda *big.Int) *point {
+	x := new(big.Int).Mul(lambda, lambda)
+	x.Sub(x, a.x)
+	x.Sub(x, b.x)
+	x.Mod(x, curveP)
+
+	y := new(big.Int).Sub(a.x, x)
+	y.Mul(y, lambda)
+	y.Sub(y, a.y)
+	y.Mod(y, curveP)
+
+	return &point{x: x, y: y}
+}
+
+func (a *point) mul(k *big.Int) *point {
+	var res *point
+	for n := k.BitLen() - 1; n >= 0; n-- {
+		res = res.double()
+		if k.Bit(n) == 1 {
+			res = res.add(a)
+		}
+	}
+	return res
+}
+
+// compressed serializes the point as a 33 byte SEC1 public key.
+func (a *point) compressed() []byte {
+	out := make([]byte, 33)
+	out[0] = 0x02 + byte(a.y.Bit(0))
+	a.x.FillBytes(out[1:])
+	return out
+}
+
+// liftX returns the point with coordinate x and the given parity of y.
+func liftX(x *big.Int, odd bool) (*point, error) {
+	if x.Cmp(curveP) >= 0 {
+		return nil, ErrInvalidPubKey
+	}
+
+	// y = (x^3 + 7)^((p+1)/4), p = 3 mod 4
+	ySq := new(big.Int).Exp(x, big.NewInt(3), curveP)
+	ySq.Add(ySq, curveB)
+	ySq.Mod(ySq, curveP)
+
+	exp := new(big.Int).Add(curveP, big.NewInt(1))
+	exp.Rsh(exp, 2)
+	y := new(big.Int).Exp(ySq, exp, curveP)
+	if new(big.Int).Exp(y, big.NewInt(2), curveP).Cmp(ySq) != 0 {
+		return nil, ErrInvalidPubKey
+	}
+
+	if (y.Bit(0) == 1) != odd {
+		y.Sub(curveP, y)
+	}
+	return &point{x: new(big.Int).Set(x), y: y}, nil
+}
+
+// parsePubKey parses a compressed SEC1 public key.
+func parsePubKey(b []byte) (*point, error) {
+	if len(b) != 33 || (b[0] != 0x02 && b[0] != 0x03) {
+		return nil, ErrInvalidPubKey
+	}
+	return liftX(new(big.Int).SetBytes(b[1:]), b[0] == 0x03)
+}
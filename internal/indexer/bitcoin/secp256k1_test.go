@@ -0,0 +1,90 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestPointMul(t *testing.T) {
+	tests := []struct {
+		k    *big.Int
+		want string
+	}{
+		{big.NewInt(1), "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+		{big.NewInt(2), "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"},
+		{big.NewInt(3), "02f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9"},
+		// -G has the same x and odd y
+		{new(big.Int).Sub(curveN, big.NewInt(1)), "0379be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+	}
+
+	for _, tt := range tests {
+		got := curveG.mul(tt.k)
+		if got == nil || hex.EncodeToString(got.compressed()) != tt.want {
+			t.Errorf("%x G = %v, want %s", tt.k, got, tt.want)
+		}
+	}
+
+	if p := curveG.mul(curveN); p != nil {
+		t.Errorf("n G = %v, want the point at infinity", p)
+	}
+}
+
+func TestPointAdd(t *testing.T) {
+	two := curveG.double()
+	if three := two.add(curveG); !bytes.Equal(three.compressed(), curveG.mul(big.NewInt(3)).compressed()) {
+		t.Error("2G + G != 3G")
+	}
+	if sum := curveG.add(curveG); !bytes.Equal(sum.compressed(), two.compressed()) {
+		t.Error("G + G != 2G")
+	}
+
+	minusG := curveG.mul(new(big.Int).Sub(curveN, big.NewInt(1)))
+	if sum := curveG.add(minusG); sum != nil {
+		t.Errorf("G + -G = %v, want the point at infinity", sum)
+	}
+	if sum := (*point)(nil).add(curveG); sum != curveG {
+		t.Error("infinity + G != G")
+	}
+}
+
+func TestParsePubKey(t *testing.T) {
+	for _, k := range []int64{1, 2, 3, 1000} {
+		want := curveG.mul(big.NewInt(k))
+		got, err := parsePubKey(want.compressed())
+		if err != nil || got.x.Cmp(want.x) != 0 || got.y.Cmp(want.y) != 0 {
+			t.Errorf("parse %d G = %v, %v", k, got, err)
+		}
+	}
+
+	gx := curveG.compressed()[1:]
+	tests := []struct {
+		name string
+		key  []byte
+	}{
+		{"uncompressed", append([]byte{0x04}, append(gx, curveG.y.FillBytes(make([]byte, 32))...)...)},
+		{"short", append([]byte{0x02}, gx[1:]...)},
+		{"bad prefix", append([]byte{0x05}, gx...)},
+		// x^3 + 7 is not a square for x = 5
+		{"not on the curve", append([]byte{0x02}, big.NewInt(5).FillBytes(make([]byte, 32))...)},
+		{"x past the field", append([]byte{0x02}, curveP.FillBytes(make([]byte, 32))...)},
+	}
+	for _, tt := range tests {
+		if _, err := parsePubKey(tt.key); err != ErrInvalidPubKey {
+			t.Errorf("%s: err = %v, want ErrInvalidPubKey", tt.name, err)
+		}
+	}
+}
+
+// TestTaprootOutputKey checks the BIP86 tweak against the first receive key
+// of its test vector.
+func TestTaprootOutputKey(t *testing.T) {
+	internal := mustDecodeHex(t, "03cc8a4bc64d897bddc5fbc2f670f7a8ba0b386779106cf1223c6fc5d7cd6fc115")
+	want := "a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c"
+
+	got, err := taprootOutputKey(internal)
+	if err != nil || hex.EncodeToString(got) != want {
+		t.Errorf("output key = %x, %v, want %s", got, err, want)
+	}
+}
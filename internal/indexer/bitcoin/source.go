@@ -0,0 +1,68 @@
+package bitcoin
+
+import (
+	"context"
+	"errors"
+)
+
+// Backends a BlockSource can be built for, selected by the bitcoin.source
+// config option.
+const (
+	SourceRPC     = "rpc"
+	SourceEsplora = "esplora"
+)
+
+// Errors matched with errors.Is when the backend does not know a block, by
+// height or hash, or a transaction. Any other error means the backend could
+// not answer.
+var (
+	ErrBlockNotFound = errors.New("block not found")
+	ErrTxNotFound    = errors.New("transaction not found")
+)
+
+// BlockSource is everything the indexer needs from a chain backend. Hashes are
+// hex in the usual reversed byte order and proofs are serialized CMerkleBlock
+// as returned by gettxoutproof.
+type BlockSource interface {
+	GetBlockCount(ctx context.Context) (int64, error)
+	GetBlockHash(ctx context.Context, height int64) (string, error)
+	GetBlockHeader(ctx context.Context, hash string) (*BlockHeader, error)
+	GetBlock(ctx context.Context, hash string) ([]Transaction, error)
+	GetTxOutProof(ctx context.Context, txid, blockhash string) ([]byte, error)
+	GetRawTransaction(ctx context.Context, txid string) (*Transaction, error)
+	GetRawMempool(ctx context.Context) ([]string, error)
+}
+
+// FilterSource is implemented by backends that serve BIP158 compact block
+// filters, returned serialized.
+type FilterSource interface {
+	GetBlockFilter(ctx context.Context, hash string) ([]byte, error)
+}
+
+// UTXOScanner is implemented by backends that can search the current UTXO
+// set, so balances can be seeded without a rescan.
+type UTXOScanner interface {
+	ScanTxOutSet(ctx context.Context, descriptors []string) (*TxOutSetScan, error)
+}
+
+// ChainInfoSource is implemented by backends that report which chain they
+// follow.
+type ChainInfoSource interface {
+	GetBlockchainInfo(ctx context.Context) (*BlockchainInfo, error)
+}
+
+var (
+	_ ChainInfoSource = (*RPCClient)(nil)
+	_ FilterSource    = (*RPCClient)(nil)
+	_ UTXOScanner     = (*RPCClient)(nil)
+
+	_ ChainInfoSource = (*NodePool)(nil)
+	_ FilterSource    = (*NodePool)(nil)
+	_ UTXOScanner     = (*NodePool)(nil)
+	_ BatchSource     = (*NodePool)(nil)
+
+	_ BlockSource = (*RPCClient)(nil)
+	_ BlockSource = (*NodePool)(nil)
+	_ BlockSource = (*EsploraClient)(nil)
+	_ BlockSource = (*FakeChain)(nil)
+)
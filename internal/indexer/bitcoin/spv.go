@@ -1,62 +1,299 @@
 package bitcoin
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"errors"
+	"fmt"
 	"math/big"
+	"sort"
+	"strconv"
+	"time"
 )
 
-func ValidateHeader(header, prev *BlockHeader) error {
-	if header.PreviousHash != prev.BlockHash {
-		return errors.New("invalid previous hash")
+const (
+	// MedianTimeBlocks is the number of blocks the median time past is taken over.
+	MedianTimeBlocks = 11
+	// maxFutureBlockTime is how far ahead of the local clock a header may be.
+	maxFutureBlockTime = 2 * time.Hour
+)
+
+var (
+	// ErrPrevHashMismatch means the header does not extend the given parent.
+	// It is not a consensus failure: the chain may simply have been reorged.
+	ErrPrevHashMismatch = errors.New("header does not extend the previous block")
+
+	ErrHashMismatch   = errors.New("header hash does not match its contents")
+	ErrBadTarget      = errors.New("target is invalid or above the proof of work limit")
+	ErrHighHash       = errors.New("block hash is above the target")
+	ErrBadDifficulty  = errors.New("bits do not match the expected difficulty")
+	ErrTimeTooOld     = errors.New("timestamp is not after the median time past")
+	ErrTimeTooNew     = errors.New("timestamp is too far in the future")
+	ErrHeightMismatch = errors.New("height does not follow the previous block")
+)
+
+var bigOne = big.NewInt(1)
+
+// HeaderContext is the part of the chain a header is validated against.
+type HeaderContext struct {
+	// Prev is the header being extended, nil for the first indexed block.
+	Prev *BlockHeader
+	// Timestamps of up to 11 blocks ending at Prev, in any order.
+	Timestamps []int64
+	// PeriodStart is the first block of the retarget period that ends at
+	// Prev. It is only needed on retarget heights and the retarget check is
+	// skipped when it is not known.
+	PeriodStart *BlockHeader
+	// LastBits are the bits of the last block up to Prev that was not mined
+	// at the minimum difficulty or starts a retarget period. They are only
+	// needed on networks that allow min difficulty blocks, and the check is
+	// skipped when they are zero.
+	LastBits uint32
+	Now      time.Time
+}
+
+// ValidateHeader runs the context free and contextual header checks: the hash
+// commits to the header, the proof of work meets the target encoded in bits,
+// bits follow the difficulty rules and the timestamp is within bounds.
+func (p *ChainParams) ValidateHeader(header *BlockHeader, ctx HeaderContext) error {
+	bits, err := ParseBits(header.Bits)
+	if err != nil {
+		return err
+	}
+
+	raw, err := SerializeHeader(header)
+	if err != nil {
+		return err
+	}
+	hash := DoubleSHA256(raw)
+	if HashToHex(hash) != header.BlockHash {
+		return ErrHashMismatch
+	}
+
+	if err := p.CheckProofOfWork(hash, bits); err != nil {
+		return err
+	}
+
+	if !ctx.Now.IsZero() && time.Unix(header.Timestamp, 0).After(ctx.Now.Add(maxFutureBlockTime)) {
+		return ErrTimeTooNew
+	}
+
+	if ctx.Prev == nil {
+		return nil
+	}
+
+	if header.PreviousHash != ctx.Prev.BlockHash {
+		return ErrPrevHashMismatch
+	}
+	if header.Height != ctx.Prev.Height+1 {
+		return ErrHeightMismatch
+	}
+
+	if len(ctx.Timestamps) > 0 && header.Timestamp <= MedianTime(ctx.Timestamps) {
+		return ErrTimeTooOld
+	}
+
+	return p.checkDifficulty(header, bits, ctx)
+}
+
+func (p *ChainParams) checkDifficulty(header *BlockHeader, bits uint32, ctx HeaderContext) error {
+	prevBits, err := ParseBits(ctx.Prev.Bits)
+	if err != nil {
+		return err
+	}
+
+	if p.PowNoRetargeting {
+		if bits != prevBits {
+			return ErrBadDifficulty
+		}
+		return nil
+	}
+
+	var expected uint32
+	switch {
+	case header.Height%p.RetargetInterval() == 0:
+		if ctx.PeriodStart == nil {
+			return nil
+		}
+		// Like the node, this takes the bits of the previous block even if
+		// it was mined at the minimum difficulty.
+		expected = p.NextRequiredBits(prevBits, ctx.Prev.Timestamp-ctx.PeriodStart.Timestamp)
+	case !p.PowAllowMinDifficultyBlocks:
+		expected = prevBits
+	case header.Timestamp > ctx.Prev.Timestamp+2*int64(p.TargetSpacing/time.Second):
+		expected = p.MinDifficultyBits()
+	case ctx.LastBits == 0:
+		// The target is still bounded by CheckProofOfWork.
+		return nil
+	default:
+		expected = ctx.LastBits
+	}
+
+	if bits != expected {
+		return fmt.Errorf("%w: got %08x, expected %08x", ErrBadDifficulty, bits, expected)
+	}
+	return nil
+}
+
+// CheckProofOfWork checks that hash, in internal byte order, does not exceed
+// the target encoded in bits and that the target is within the limit.
+func (p *ChainParams) CheckProofOfWork(hash [32]byte, bits uint32) error {
+	target, err := CompactToTarget(bits)
+	if err != nil {
+		return err
 	}
-	if !CheckProofOfWork(header) {
-		return errors.New("invalid proof of work")
+	if target.Cmp(p.PowLimit) > 0 {
+		return ErrBadTarget
 	}
-	if header.Timestamp < prev.Timestamp {
-		return errors.New("invalid timestamp")
+
+	if HashToBig(hash).Cmp(target) > 0 {
+		return ErrHighHash
 	}
 	return nil
 }
 
-func decodeHex(s string) []byte {
-	b, _ := hex.DecodeString(s)
-	return b
+// MinDifficultyBits are the bits of a block mined at PowLimit.
+func (p *ChainParams) MinDifficultyBits() uint32 {
+	return BigToCompact(p.PowLimit)
 }
 
-func calculateTarget(difficulty float64) *big.Int {
-	maxTarget := new(big.Int).Lsh(big.NewInt(1), 256)
-	maxTarget.Sub(maxTarget, big.NewInt(1))
+// NextRequiredBits returns the bits of the first block of a new retarget
+// period, given the bits of the last block and how long the period took.
+func (p *ChainParams) NextRequiredBits(prevBits uint32, actualTimespan int64) uint32 {
+	timespan := int64(p.TargetTimespan / time.Second)
+	if actualTimespan < timespan/4 {
+		actualTimespan = timespan / 4
+	}
+	if actualTimespan > timespan*4 {
+		actualTimespan = timespan * 4
+	}
 
-	if difficulty <= 0 {
-		return maxTarget
+	target := CompactToBig(prevBits)
+	target.Mul(target, big.NewInt(actualTimespan))
+	target.Div(target, big.NewInt(timespan))
+	if target.Cmp(p.PowLimit) > 0 {
+		target.Set(p.PowLimit)
 	}
 
-	diffFloat := big.NewFloat(difficulty)
-	maxTargetFloat := new(big.Float).SetInt(maxTarget)
+	return BigToCompact(target)
+}
 
-	resFloat := new(big.Float).Quo(maxTargetFloat, diffFloat)
+// ParseBits parses the hex encoded compact target returned by the node.
+func ParseBits(s string) (uint32, error) {
+	bits, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bits %q: %w", s, err)
+	}
+	return uint32(bits), nil
+}
 
-	result := new(big.Int)
-	resFloat.Int(result)
-	return result
+// CompactToTarget decodes bits and rejects negative, zero and overflowing
+// targets, the same way the node does.
+func CompactToTarget(bits uint32) (*big.Int, error) {
+	mantissa := bits & 0x007fffff
+	exponent := bits >> 24
+	if mantissa != 0 && (exponent > 34 ||
+		(mantissa > 0xff && exponent > 33) ||
+		(mantissa > 0xffff && exponent > 32)) {
+		return nil, ErrBadTarget
+	}
+
+	target := CompactToBig(bits)
+	if target.Sign() <= 0 {
+		return nil, ErrBadTarget
+	}
+	return target, nil
 }
 
-func CheckProofOfWork(header *BlockHeader) bool {
-	hashBytes := decodeHex(header.BlockHash)
-	hashInt := new(big.Int).SetBytes(hashBytes)
+// CompactToBig decodes the compact representation used in block headers: one
+// exponent byte followed by a 23 bit mantissa and a sign bit.
+func CompactToBig(compact uint32) *big.Int {
+	mantissa := compact & 0x007fffff
+	negative := compact&0x00800000 != 0
+	exponent := uint(compact >> 24)
 
-	target := calculateTarget(header.Difficulty)
+	var n *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		n = big.NewInt(int64(mantissa))
+	} else {
+		n = big.NewInt(int64(mantissa))
+		n.Lsh(n, 8*(exponent-3))
+	}
 
-	return hashInt.Cmp(target) <= 0
+	if negative {
+		n.Neg(n)
+	}
+	return n
 }
 
-func VerifyMerkleProof(txid string, proof [][]byte, root string) bool {
-	hash := sha256.Sum256([]byte(txid))
-	for _, p := range proof {
-		h := sha256.Sum256(append(hash[:], p...))
-		hash = h
+// BigToCompact is the inverse of CompactToBig.
+func BigToCompact(n *big.Int) uint32 {
+	if n.Sign() == 0 {
+		return 0
+	}
+
+	var mantissa uint32
+	exponent := uint(len(n.Bytes()))
+	if exponent <= 3 {
+		mantissa = uint32(new(big.Int).Abs(n).Uint64())
+		mantissa <<= 8 * (3 - exponent)
+	} else {
+		shifted := new(big.Int).Rsh(new(big.Int).Abs(n), 8*(exponent-3))
+		mantissa = uint32(shifted.Uint64())
 	}
-	return hex.EncodeToString(hash[:]) == root
+
+	// The sign bit is part of the mantissa, so shift it out of the way.
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+
+	compact := uint32(exponent<<24) | mantissa
+	if n.Sign() < 0 {
+		compact |= 0x00800000
+	}
+	return compact
+}
+
+// HashToBig interprets a hash in internal byte order as the little endian
+// number proof of work is measured with.
+func HashToBig(hash [32]byte) *big.Int {
+	var be [32]byte
+	for n := range hash {
+		be[n] = hash[len(hash)-1-n]
+	}
+	return new(big.Int).SetBytes(be[:])
+}
+
+// CalcWork returns the expected number of hashes needed to mine a block with
+// the given bits, 2^256 / (target + 1).
+func CalcWork(bits uint32) *big.Int {
+	target := CompactToBig(bits)
+	if target.Sign() <= 0 {
+		return big.NewInt(0)
+	}
+
+	denominator := new(big.Int).Add(target, bigOne)
+	return new(big.Int).Div(new(big.Int).Lsh(bigOne, 256), denominator)
+}
+
+// AddChainwork adds the work of a block with the given bits to the hex
+// encoded chainwork of its parent.
+func AddChainwork(prev string, bits uint32) (string, error) {
+	total := new(big.Int)
+	if prev != "" {
+		if _, ok := total.SetString(prev, 16); !ok {
+			return "", fmt.Errorf("invalid chainwork %q", prev)
+		}
+	}
+
+	total.Add(total, CalcWork(bits))
+	return fmt.Sprintf("%064x", total), nil
+}
+
+// MedianTime returns the median of timestamps.
+func MedianTime(timestamps []int64) int64 {
+	sorted := make([]int64, len(timestamps))
+	copy(sorted, timestamps)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+	return sorted[len(sorted)/2]
 }
@@ -0,0 +1,240 @@
+package bitcoin
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+var (
+	mainnetGenesis = BlockHeader{
+		BlockHash:  "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+		Version:    1,
+		MerkleRoot: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+		Timestamp:  1231006505,
+		Bits:       "1d00ffff",
+		Nonce:      2083236893,
+	}
+	mainnetBlock1 = BlockHeader{
+		BlockHash:    "00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048",
+		Version:      1,
+		PreviousHash: "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+		MerkleRoot:   "0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098",
+		Timestamp:    1231469665,
+		Bits:         "1d00ffff",
+		Nonce:        2573394689,
+		Height:       1,
+	}
+)
+
+func TestCompactToBig(t *testing.T) {
+	tests := []struct {
+		compact uint32
+		want    string
+	}{
+		{0x00000000, "0"},
+		{0x01003456, "0"},
+		{0x01123456, "12"},
+		{0x02008000, "80"},
+		{0x05009234, "92340000"},
+		{0x04923456, "-12345600"},
+		{0x04123456, "12345600"},
+		{0x1d00ffff, "ffff0000000000000000000000000000000000000000000000000000"},
+	}
+
+	for _, tt := range tests {
+		want, _ := new(big.Int).SetString(tt.want, 16)
+		if got := CompactToBig(tt.compact); got.Cmp(want) != 0 {
+			t.Errorf("CompactToBig(%08x) = %x, want %s", tt.compact, got, tt.want)
+		}
+		if want.Sign() != 0 && tt.compact != 0x01123456 {
+			if got := BigToCompact(want); got != tt.compact {
+				t.Errorf("BigToCompact(%s) = %08x, want %08x", tt.want, got, tt.compact)
+			}
+		}
+	}
+
+	if got := BigToCompact(big.NewInt(0x12)); got != 0x01120000 {
+		t.Errorf("BigToCompact(0x12) = %08x, want 01120000", got)
+	}
+	if got := MainNetParams.MinDifficultyBits(); got != 0x1d00ffff {
+		t.Errorf("mainnet minimum difficulty bits = %08x, want 1d00ffff", got)
+	}
+	if got := RegTestParams.MinDifficultyBits(); got != 0x207fffff {
+		t.Errorf("regtest minimum difficulty bits = %08x, want 207fffff", got)
+	}
+}
+
+func TestCompactToTargetRejectsInvalid(t *testing.T) {
+	for _, bits := range []uint32{
+		0x00000000, // zero
+		0x01003456, // rounds to zero
+		0x04923456, // negative
+		0xff123456, // overflows 256 bits
+		0x23000100, // overflows with a wide mantissa
+	} {
+		if _, err := CompactToTarget(bits); err != ErrBadTarget {
+			t.Errorf("CompactToTarget(%08x) = %v, want ErrBadTarget", bits, err)
+		}
+	}
+}
+
+// TestNextRequiredBits replays mainnet retargets, the same ones the node
+// tests its difficulty adjustment with.
+func TestNextRequiredBits(t *testing.T) {
+	tests := []struct {
+		name        string
+		prevBits    uint32
+		periodStart int64
+		last        int64
+		want        uint32
+	}{
+		// blocks 30240 and 32255
+		{"retarget at 32256", 0x1d00ffff, 1261130161, 1262152739, 0x1d00d86a},
+		// blocks 0 and 2015, clamped to the proof of work limit
+		{"limited by pow limit", 0x1d00ffff, 1231006505, 1233061996, 0x1d00ffff},
+		// blocks 66528 and 68543, the period took under a quarter
+		{"lower limit of the timespan", 0x1c05a3f4, 1279008237, 1279297671, 0x1c0168fd},
+		// blocks 46368 and 48383, the period took over four times longer
+		{"upper limit of the timespan", 0x1c387f6f, 1263163443, 1269211443, 0x1d00e1fd},
+	}
+
+	for _, tt := range tests {
+		if got := MainNetParams.NextRequiredBits(tt.prevBits, tt.last-tt.periodStart); got != tt.want {
+			t.Errorf("%s: bits = %08x, want %08x", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMedianTime(t *testing.T) {
+	timestamps := []int64{10, 3, 7, 1, 9, 2, 8, 4, 6, 5, 11}
+	if got := MedianTime(timestamps); got != 6 {
+		t.Errorf("median = %d, want 6", got)
+	}
+	if timestamps[0] != 10 {
+		t.Error("MedianTime reordered its input")
+	}
+	if got := MedianTime([]int64{5}); got != 5 {
+		t.Errorf("median of one = %d, want 5", got)
+	}
+}
+
+func TestValidateHeader(t *testing.T) {
+	if err := MainNetParams.ValidateHeader(&mainnetGenesis, HeaderContext{}); err != nil {
+		t.Fatalf("genesis: %v", err)
+	}
+
+	ctx := HeaderContext{
+		Prev:       &mainnetGenesis,
+		Timestamps: []int64{mainnetGenesis.Timestamp},
+		Now:        time.Unix(mainnetBlock1.Timestamp, 0),
+	}
+	if err := MainNetParams.ValidateHeader(&mainnetBlock1, ctx); err != nil {
+		t.Fatalf("block 1: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(h *BlockHeader, ctx *HeaderContext)
+		want   error
+	}{
+		{"hash does not commit to the header", func(h *BlockHeader, _ *HeaderContext) { h.Nonce++ }, ErrHashMismatch},
+		{"other parent", func(_ *BlockHeader, ctx *HeaderContext) {
+			prev := *ctx.Prev
+			prev.BlockHash = mainnetBlock1.BlockHash
+			ctx.Prev = &prev
+		}, ErrPrevHashMismatch},
+		{"height gap", func(h *BlockHeader, _ *HeaderContext) { h.Height = 2 }, ErrHeightMismatch},
+		{"not after the median time past", func(_ *BlockHeader, ctx *HeaderContext) {
+			ctx.Timestamps = []int64{mainnetBlock1.Timestamp}
+		}, ErrTimeTooOld},
+		{"more than two hours ahead", func(_ *BlockHeader, ctx *HeaderContext) {
+			ctx.Now = time.Unix(mainnetBlock1.Timestamp, 0).Add(-2*time.Hour - time.Second)
+		}, ErrTimeTooNew},
+	}
+
+	for _, tt := range tests {
+		header, ctx := mainnetBlock1, ctx
+		tt.modify(&header, &ctx)
+		if err := MainNetParams.ValidateHeader(&header, ctx); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestCheckProofOfWork(t *testing.T) {
+	hash, err := HashFromHex(mainnetGenesis.BlockHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := MainNetParams.CheckProofOfWork(hash, 0x1d00ffff); err != nil {
+		t.Errorf("genesis: %v", err)
+	}
+	// the genesis hash is above a target 2^16 times lower
+	if err := MainNetParams.CheckProofOfWork(hash, 0x1b00ffff); err != ErrHighHash {
+		t.Errorf("genesis with higher difficulty = %v, want ErrHighHash", err)
+	}
+	// regtest bits are far above the mainnet limit
+	if err := MainNetParams.CheckProofOfWork(hash, 0x207fffff); err != ErrBadTarget {
+		t.Errorf("regtest bits on mainnet = %v, want ErrBadTarget", err)
+	}
+}
+
+// TestTestnetMinDifficulty checks the testnet rule that a block more than 20
+// minutes after its parent may be mined at the minimum difficulty, and that
+// the blocks after it return to the difficulty before.
+func TestTestnetMinDifficulty(t *testing.T) {
+	const (
+		realBits = 0x1c0ffff0
+		minBits  = 0x1d00ffff
+	)
+	prev := &BlockHeader{Height: 100, Timestamp: 1_600_000_000, Bits: "1d00ffff"}
+
+	tests := []struct {
+		name  string
+		delay int64
+		bits  uint32
+		want  error
+	}{
+		{"min difficulty after 20 minutes", 20*60 + 1, minBits, nil},
+		{"min difficulty at exactly 20 minutes", 20 * 60, minBits, ErrBadDifficulty},
+		{"real difficulty after 20 minutes", 20*60 + 1, realBits, ErrBadDifficulty},
+		{"back to the last real difficulty", 60, realBits, nil},
+		{"min difficulty within 20 minutes", 60, minBits, ErrBadDifficulty},
+	}
+
+	for _, tt := range tests {
+		header := &BlockHeader{Height: 101, Timestamp: prev.Timestamp + tt.delay}
+		ctx := HeaderContext{Prev: prev, LastBits: realBits}
+		if err := TestNetParams.checkDifficulty(header, tt.bits, ctx); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// without the last real bits only the proof of work bounds the target
+	header := &BlockHeader{Height: 101, Timestamp: prev.Timestamp + 60}
+	if err := TestNetParams.checkDifficulty(header, realBits, HeaderContext{Prev: prev}); err != nil {
+		t.Errorf("unknown last bits: %v", err)
+	}
+	// mainnet has no such rule
+	mainPrev := &BlockHeader{Height: 100, Timestamp: prev.Timestamp, Bits: "1c0ffff0"}
+	header.Timestamp = prev.Timestamp + 20*60 + 1
+	if err := MainNetParams.checkDifficulty(header, minBits, HeaderContext{Prev: mainPrev}); !errors.Is(err, ErrBadDifficulty) {
+		t.Errorf("mainnet min difficulty rule = %v, want ErrBadDifficulty", err)
+	}
+}
+
+func TestAddChainwork(t *testing.T) {
+	genesis, err := AddChainwork("", 0x1d00ffff)
+	if err != nil || genesis != "0000000000000000000000000000000000000000000000000000000100010001" {
+		t.Fatalf("genesis chainwork = %s, %v", genesis, err)
+	}
+	block1, err := AddChainwork(genesis, 0x1d00ffff)
+	if err != nil || block1 != "0000000000000000000000000000000000000000000000000000000200020002" {
+		t.Errorf("block 1 chainwork = %s, %v", block1, err)
+	}
+	if _, err := AddChainwork("not hex", 0x1d00ffff); err == nil {
+		t.Error("invalid chainwork accepted")
+	}
+}
@@ -1,7 +1,14 @@
 package bitcoin
 
+import (
+	"encoding/json"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/amount"
+)
+
 type BlockHeader struct {
 	BlockHash      string  `json:"hash"`
+	Version        int32   `json:"version"`
 	PreviousHash   string  `json:"previousblockhash"`
 	MerkleRoot     string  `json:"merkleroot"`
 	Timestamp      int64   `json:"time"`
@@ -10,6 +17,17 @@ type BlockHeader struct {
 	Height         int64   `json:"height"`
 	TransactionNum int64   `json:"nTx"`
 	Difficulty     float64 `json:"difficulty"`
+	Chainwork      string  `json:"chainwork"`
+}
+
+// BlockchainInfo is the part of getblockchaininfo the indexer uses.
+type BlockchainInfo struct {
+	Chain                string `json:"chain"`
+	Blocks               int64  `json:"blocks"`
+	Headers              int64  `json:"headers"`
+	BestBlockHash        string `json:"bestblockhash"`
+	Chainwork            string `json:"chainwork"`
+	InitialBlockDownload bool   `json:"initialblockdownload"`
 }
 
 type Transaction struct {
@@ -19,16 +37,86 @@ type Transaction struct {
 }
 
 type TxInput struct {
-	PrevTxID string `json:"txid"`
-	Vout     int64  `json:"vout"`
+	PrevTxID string   `json:"txid"`
+	Vout     int64    `json:"vout"`
+	Coinbase string   `json:"coinbase,omitempty"`
+	Prevout  *Prevout `json:"prevout,omitempty"`
+}
+
+// Prevout is the spent output, returned by getblock with verbosity 3 only.
+type Prevout struct {
+	Value        amount.Amount `json:"value"`
+	Height       int64         `json:"height"`
+	ScriptPubKey ScriptPubKey  `json:"scriptPubKey"`
 }
 
 type TxOutput struct {
-	Value        float64 `json:"value"`
-	Vout         int64   `json:"n"`
-	ScriptPubKey struct {
-		Address   string   `json:"address"`
-		Addresses []string `json:"addresses"`
-	} `json:"scriptPubKey"`
-	Address string `json:"address,omitempty"`
+	Value        amount.Amount `json:"value"`
+	Vout         int64         `json:"n"`
+	ScriptPubKey ScriptPubKey  `json:"scriptPubKey"`
+	Address      string        `json:"address,omitempty"`
+}
+
+type ScriptPubKey struct {
+	Hex       string   `json:"hex"`
+	Type      string   `json:"type"`
+	Address   string   `json:"address"`
+	Addresses []string `json:"addresses"`
+}
+
+// TxOutSetScan is the result of scantxoutset.
+type TxOutSetScan struct {
+	Success   bool          `json:"success"`
+	Height    int64         `json:"height"`
+	BestBlock string        `json:"bestblock"`
+	Unspents  []ScannedUTXO `json:"unspents"`
+}
+
+type ScannedUTXO struct {
+	TxID   string        `json:"txid"`
+	Vout   int64         `json:"vout"`
+	Amount amount.Amount `json:"amount"`
+	Height int64         `json:"height"`
+}
+
+// The node reports values in BTC, the methods below decode them into
+// satoshis through btcAmount.
+
+func (p *Prevout) UnmarshalJSON(b []byte) error {
+	type plain Prevout
+	raw := struct {
+		*plain
+		Value btcAmount `json:"value"`
+	}{plain: (*plain)(p)}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	p.Value = amount.Amount(raw.Value)
+	return nil
+}
+
+func (o *TxOutput) UnmarshalJSON(b []byte) error {
+	type plain TxOutput
+	raw := struct {
+		*plain
+		Value btcAmount `json:"value"`
+	}{plain: (*plain)(o)}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	o.Value = amount.Amount(raw.Value)
+	return nil
+}
+
+func (u *ScannedUTXO) UnmarshalJSON(b []byte) error {
+	type plain ScannedUTXO
+	raw := struct {
+		*plain
+		Amount btcAmount `json:"amount"`
+	}{plain: (*plain)(u)}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	u.Amount = amount.Amount(raw.Amount)
+	return nil
 }
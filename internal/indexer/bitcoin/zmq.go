@@ -0,0 +1,211 @@
+package bitcoin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// Topics published by bitcoind with -zmqpubhashblock and -zmqpubrawtx.
+const (
+	ZMQTopicHashBlock = "hashblock"
+	ZMQTopicRawTx     = "rawtx"
+)
+
+const (
+	zmqFlagMore    = 0x01
+	zmqFlagLong    = 0x02
+	zmqFlagCommand = 0x04
+
+	zmqGreetingSize = 64
+	zmqMaxFrameSize = 32 << 20
+)
+
+type ZMQMessage struct {
+	Topic    string
+	Body     []byte
+	Sequence uint32
+}
+
+// ZMQSubscriber is a minimal ZMTP 3.0 SUB socket with the NULL security
+// mechanism, which is all bitcoind's notification publisher speaks.
+type ZMQSubscriber struct {
+	endpoint string
+	topics   []string
+	conn     net.Conn
+	reader   *bufio.Reader
+}
+
+func NewZMQSubscriber(endpoint string, topics ...string) *ZMQSubscriber {
+	return &ZMQSubscriber{
+		endpoint: endpoint,
+		topics:   topics,
+	}
+}
+
+func (s *ZMQSubscriber) Connect(ctx context.Context) error {
+	addr := strings.TrimPrefix(s.endpoint, "tcp://")
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	s.reader = bufio.NewReader(conn)
+
+	if err := s.handshake(); err != nil {
+		conn.Close()
+		return err
+	}
+
+	for _, topic := range s.topics {
+		if err := s.writeFrame(0, append([]byte{1}, topic...)); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *ZMQSubscriber) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// Receive blocks until the next multipart message arrives. bitcoind sends
+// topic, body and a little-endian sequence number.
+func (s *ZMQSubscriber) Receive() (*ZMQMessage, error) {
+	var parts [][]byte
+	for {
+		flags, body, err := s.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		if flags&zmqFlagCommand != 0 {
+			continue
+		}
+
+		parts = append(parts, body)
+		if flags&zmqFlagMore == 0 {
+			break
+		}
+	}
+
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("unexpected zmq message with %d parts", len(parts))
+	}
+
+	msg := &ZMQMessage{
+		Topic: string(parts[0]),
+		Body:  parts[1],
+	}
+	if len(parts) > 2 && len(parts[2]) == 4 {
+		msg.Sequence = binary.LittleEndian.Uint32(parts[2])
+	}
+
+	return msg, nil
+}
+
+func (s *ZMQSubscriber) handshake() error {
+	greeting := make([]byte, zmqGreetingSize)
+	greeting[0] = 0xff
+	greeting[9] = 0x7f
+	greeting[10] = 3
+	copy(greeting[12:32], "NULL")
+
+	if _, err := s.conn.Write(greeting); err != nil {
+		return err
+	}
+
+	peer := make([]byte, zmqGreetingSize)
+	if _, err := io.ReadFull(s.reader, peer); err != nil {
+		return err
+	}
+	if peer[0] != 0xff || peer[9] != 0x7f {
+		return errors.New("invalid zmq greeting signature")
+	}
+	if peer[10] < 3 {
+		return fmt.Errorf("unsupported zmtp version %d", peer[10])
+	}
+	if mechanism := string(bytes.TrimRight(peer[12:32], "\x00")); mechanism != "NULL" {
+		return fmt.Errorf("unsupported zmq security mechanism %q", mechanism)
+	}
+
+	ready := []byte{5}
+	ready = append(ready, "READY"...)
+	ready = append(ready, 11)
+	ready = append(ready, "Socket-Type"...)
+	ready = binary.BigEndian.AppendUint32(ready, 3)
+	ready = append(ready, "SUB"...)
+
+	if err := s.writeFrame(zmqFlagCommand, ready); err != nil {
+		return err
+	}
+
+	flags, body, err := s.readFrame()
+	if err != nil {
+		return err
+	}
+	if flags&zmqFlagCommand == 0 || len(body) < 6 || string(body[1:6]) != "READY" {
+		return errors.New("zmq peer did not send READY")
+	}
+
+	return nil
+}
+
+func (s *ZMQSubscriber) writeFrame(flags byte, body []byte) error {
+	var frame []byte
+	if len(body) > 255 {
+		frame = append(frame, flags|zmqFlagLong)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(body)))
+	} else {
+		frame = append(frame, flags, byte(len(body)))
+	}
+	frame = append(frame, body...)
+
+	_, err := s.conn.Write(frame)
+	return err
+}
+
+func (s *ZMQSubscriber) readFrame() (byte, []byte, error) {
+	flags, err := s.reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var size uint64
+	if flags&zmqFlagLong != 0 {
+		var buf [8]byte
+		if _, err := io.ReadFull(s.reader, buf[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(buf[:])
+	} else {
+		b, err := s.reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(b)
+	}
+
+	if size > zmqMaxFrameSize {
+		return 0, nil, fmt.Errorf("zmq frame of %d bytes is too large", size)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(s.reader, body); err != nil {
+		return 0, nil, err
+	}
+
+	return flags, body, nil
+}
@@ -0,0 +1,200 @@
+package indexer
+
+import (
+	"context"
+	"time"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+)
+
+const catchUpProgressInterval = 10 * time.Second
+
+// catchUpBatch bounds how many headers are loaded per catch-up range.
+const catchUpBatch = 1000
+
+type fetchJob struct {
+	header data.BlockHeader
+	result chan fetchedBlock
+}
+
+// CatchUp indexes blocks back to back, without waiting for the next poll, for
+// as long as the header chain is ahead of the block cursor. Blocks are fetched
+// by a bounded pool of workers ahead of the commit point and committed
+// strictly in height order.
+func (i *Indexer) CatchUp(ctx context.Context) {
+	for ctx.Err() == nil {
+		next, err := i.nextBlockHeight()
+		if err != nil {
+			i.logger.WithError(err).Error("failed to get block cursor")
+			return
+		}
+
+		tip, err := i.db.BlockHeader().GetLast()
+		if err != nil {
+			i.logger.WithError(err).Error("failed to get header tip")
+			return
+		}
+		if tip == nil || tip.Height < next {
+			return
+		}
+
+		to := next + catchUpBatch - 1
+		if to > tip.Height {
+			to = tip.Height
+		}
+
+		if !i.catchUpRange(ctx, next, to, tip.Height) {
+			return
+		}
+	}
+}
+
+// catchUpRange commits blocks from..to and reports whether catching up can go
+// on. It stops early when a wallet derived new addresses, so the next range
+// matches block filters against them. target is only used for progress
+// reporting.
+func (i *Indexer) catchUpRange(ctx context.Context, from, to, target int64) bool {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	headers, err := i.db.BlockHeader().SelectRange(from, to)
+	if err != nil {
+		i.logger.WithError(err).Error("failed to get block headers")
+		return false
+	}
+
+	i.walletExtended = false
+	scripts, err := i.trackedScripts()
+	if err != nil {
+		i.logger.WithError(err).Error("failed to get tracked scripts")
+		return false
+	}
+
+	i.logger.WithFields(map[string]interface{}{
+		"from":    from,
+		"to":      to,
+		"target":  target,
+		"workers": i.catchUpWorkers(),
+	}).Info("catching up with header chain")
+
+	progress := newSyncProgress(from, target)
+	for job := range i.fetchBlocks(ctx, headers, scripts) {
+		var block fetchedBlock
+		select {
+		case <-ctx.Done():
+			return false
+		case block = <-job.result:
+		}
+
+		if block.err != nil {
+			i.logger.WithError(block.err).WithField("height", block.height).Error("failed to fetch block")
+			return false
+		}
+
+		if err := i.processBlock(ctx, block.header, block.txs, i.advanceBlockCursor); err != nil {
+			i.logger.WithError(err).WithField("height", block.height).Error("failed to index block")
+			return false
+		}
+
+		progress.commit(i, block.height)
+
+		if scripts != nil && i.walletExtended {
+			return true
+		}
+	}
+
+	return ctx.Err() == nil
+}
+
+// fetchBlocks starts the worker pool and returns jobs in height order. The
+// channel buffer bounds how far fetching runs ahead of the caller.
+func (i *Indexer) fetchBlocks(ctx context.Context, headers []data.BlockHeader, scripts [][]byte) <-chan fetchJob {
+	workers := i.catchUpWorkers()
+	jobs := make(chan fetchJob)
+	ordered := make(chan fetchJob, workers)
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			for job := range jobs {
+				job.result <- i.fetchBlock(ctx, job.header, scripts)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		defer close(ordered)
+
+		for _, header := range headers {
+			job := fetchJob{
+				header: header,
+				result: make(chan fetchedBlock, 1),
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case ordered <- job:
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- job:
+			}
+		}
+	}()
+
+	return ordered
+}
+
+func (i *Indexer) catchUpWorkers() int {
+	if i.cfg.CatchUpWorkers < 1 {
+		return 1
+	}
+	return i.cfg.CatchUpWorkers
+}
+
+type syncProgress struct {
+	started   time.Time
+	lastLog   time.Time
+	from      int64
+	to        int64
+	committed int64
+}
+
+func newSyncProgress(from, to int64) *syncProgress {
+	now := time.Now()
+	return &syncProgress{
+		started: now,
+		lastLog: now,
+		from:    from,
+		to:      to,
+	}
+}
+
+func (p *syncProgress) commit(i *Indexer, height int64) {
+	p.committed++
+
+	now := time.Now()
+	if now.Sub(p.lastLog) < catchUpProgressInterval && height != p.to {
+		return
+	}
+	p.lastLog = now
+
+	rate := float64(p.committed) / now.Sub(p.started).Seconds()
+	remaining := p.to - height
+
+	var eta time.Duration
+	if rate > 0 {
+		eta = time.Duration(float64(remaining) / rate * float64(time.Second)).Round(time.Second)
+	}
+
+	i.logger.WithFields(map[string]interface{}{
+		"height":     height,
+		"target":     p.to,
+		"remaining":  remaining,
+		"blocks_sec": rate,
+		"eta":        eta.String(),
+	}).Info("catch-up progress")
+}
@@ -0,0 +1,56 @@
+package indexer
+
+import (
+	"context"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+	"gitlab.com/distributed_lab/logan/v3"
+	"gitlab.com/distributed_lab/logan/v3/errors"
+)
+
+// trackedScripts returns the scriptPubKeys of all tracked addresses to match
+// block filters against. It returns nil when filter scanning is off or some
+// address has no known script, in which case every block is downloaded.
+func (i *Indexer) trackedScripts() ([][]byte, error) {
+	if i.filters == nil {
+		return nil, nil
+	}
+
+	addresses, err := i.db.Address().SelectAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select addresses")
+	}
+
+	scripts := make([][]byte, 0, len(addresses))
+	for _, addr := range addresses {
+		script, err := bitcoin.AddressToScript(addr.Address)
+		if err != nil {
+			i.logger.WithError(err).WithField("address", addr.Address).Warn("cannot match address against block filters, downloading full blocks")
+			return nil, nil
+		}
+		scripts = append(scripts, script)
+	}
+
+	return scripts, nil
+}
+
+// matchFilter reports whether the basic filter of the block may contain any
+// of scripts, either as an output or as a spent prevout.
+func (i *Indexer) matchFilter(ctx context.Context, blockHash string, scripts [][]byte) (bool, error) {
+	raw, err := i.filters.GetBlockFilter(ctx, blockHash)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get block filter")
+	}
+
+	hash, err := bitcoin.HashFromHex(blockHash)
+	if err != nil {
+		return false, errors.Wrap(err, "invalid block hash")
+	}
+
+	filter, err := bitcoin.ParseBasicFilter(raw, hash)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to parse block filter", logan.F{"hash": blockHash})
+	}
+
+	return filter.MatchAny(scripts)
+}
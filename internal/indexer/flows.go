@@ -0,0 +1,35 @@
+package indexer
+
+import (
+	"github.com/Myrtilli/transaction-indexing-svc/internal/amount"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+)
+
+// addressFlows accumulates the net amount a transaction moves for every
+// tracked address it touches, keeping the order addresses were first seen in.
+type addressFlows struct {
+	addressIDs []int64
+	amounts    map[int64]amount.Amount
+}
+
+func newAddressFlows() *addressFlows {
+	return &addressFlows{
+		amounts: make(map[int64]amount.Amount),
+	}
+}
+
+func (f *addressFlows) add(addressID int64, value amount.Amount) {
+	if _, ok := f.amounts[addressID]; !ok {
+		f.addressIDs = append(f.addressIDs, addressID)
+	}
+	f.amounts[addressID] += value
+}
+
+// get returns the direction and the absolute amount of the flow for addressID.
+func (f *addressFlows) get(addressID int64) (string, amount.Amount) {
+	value := f.amounts[addressID]
+	if value < 0 {
+		return data.TxDirectionOutgoing, -value
+	}
+	return data.TxDirectionIncoming, value
+}
@@ -0,0 +1,259 @@
+package indexer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+	"gitlab.com/distributed_lab/logan/v3"
+	"gitlab.com/distributed_lab/logan/v3/errors"
+)
+
+const defaultHeaderBatchSize = 2000
+
+// SyncHeaders downloads, validates and stores headers until the header chain
+// reaches the node's tip. The chain starts at the last checkpoint at or below
+// the start height and runs ahead of block indexing, which only processes
+// blocks whose header is already stored. Reorgs are detected here.
+func (i *Indexer) SyncHeaders(ctx context.Context) {
+	for ctx.Err() == nil && i.halted == nil {
+		tip, err := i.db.BlockHeader().GetLast()
+		if err != nil {
+			i.logger.WithError(err).Error("failed to get header tip")
+			return
+		}
+
+		if tip == nil {
+			if err := i.bootstrapHeaders(ctx); err != nil {
+				i.logger.WithError(err).Error("failed to store first header")
+				return
+			}
+			continue
+		}
+
+		rpcHash, err := i.source.GetBlockHash(ctx, tip.Height)
+		if err != nil {
+			i.logger.WithError(err).WithField("height", tip.Height).Error("failed to get tip hash from node")
+			return
+		}
+
+		if tip.BlockHash != rpcHash {
+			i.logger.WithFields(map[string]interface{}{
+				"height":   tip.Height,
+				"db_hash":  tip.BlockHash,
+				"rpc_hash": rpcHash,
+			}).Warn("reorg detected at tip!")
+
+			i.HandleReorg(ctx, tip.Height+1)
+			return
+		}
+
+		nodeHeight, err := i.source.GetBlockCount(ctx)
+		if err != nil {
+			i.logger.WithError(err).Error("failed to get node block count")
+			return
+		}
+		if nodeHeight <= tip.Height {
+			return
+		}
+
+		to := tip.Height + int64(i.headerBatchSize())
+		if to > nodeHeight {
+			to = nodeHeight
+		}
+
+		headers, err := i.fetchHeaders(ctx, tip.Height+1, to)
+		if err != nil {
+			i.logger.WithError(err).Error("failed to fetch headers")
+			return
+		}
+
+		if err := i.storeHeaders(headers); err != nil {
+			if vErr, ok := err.(*ValidationError); ok {
+				i.halt(vErr)
+				return
+			}
+			i.logger.WithError(err).WithField("from", tip.Height+1).Error("failed to store headers")
+			return
+		}
+
+		if to < nodeHeight {
+			i.logger.WithFields(map[string]interface{}{
+				"height": to,
+				"target": nodeHeight,
+			}).Info("header sync progress")
+		}
+	}
+}
+
+// bootstrapHeaders stores the checkpoint the header chain starts from.
+func (i *Indexer) bootstrapHeaders(ctx context.Context) error {
+	cp := bitcoin.LastCheckpoint(i.checkpoints, int64(i.cfg.StartHeight))
+	if cp == nil {
+		return errors.From(errors.New("no checkpoint at or below the start height"), logan.F{
+			"start_height": i.cfg.StartHeight,
+		})
+	}
+
+	hash, err := i.source.GetBlockHash(ctx, cp.Height)
+	if err != nil {
+		return errors.Wrap(err, "failed to get checkpoint hash")
+	}
+	if hash != cp.Hash {
+		i.halt(&ValidationError{Height: cp.Height, Hash: hash, Err: bitcoin.ErrCheckpointMismatch})
+		return nil
+	}
+
+	header, err := i.source.GetBlockHeader(ctx, hash)
+	if err != nil {
+		return errors.Wrap(err, "failed to get checkpoint header")
+	}
+
+	err = i.storeHeaders([]*bitcoin.BlockHeader{header})
+	if vErr, ok := err.(*ValidationError); ok {
+		i.halt(vErr)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	i.logger.WithField("height", cp.Height).Info("header chain starts at checkpoint")
+	return nil
+}
+
+// storeHeaders validates headers one by one against the stored chain and
+// commits them together.
+func (i *Indexer) storeHeaders(headers []*bitcoin.BlockHeader) error {
+	var vErr *ValidationError
+	err := i.db.NewTransaction(func() error {
+		for _, header := range headers {
+			chainwork, err := i.validateHeader(header)
+			if err != nil {
+				if e, ok := err.(*ValidationError); ok {
+					vErr = e
+				}
+				return err
+			}
+
+			err = i.db.BlockHeader().Insert(data.BlockHeader{
+				BlockHash:      header.BlockHash,
+				PreviousHash:   header.PreviousHash,
+				Height:         header.Height,
+				MerkleRoot:     header.MerkleRoot,
+				Timestamp:      time.Unix(header.Timestamp, 0),
+				Difficulty:     int64(header.Difficulty),
+				Nonce:          int64(header.Nonce),
+				TransactionNum: header.TransactionNum,
+				Version:        header.Version,
+				Bits:           header.Bits,
+				Chainwork:      chainwork,
+			})
+			if err != nil {
+				return errors.Wrap(err, "failed to insert block header", logan.F{"height": header.Height})
+			}
+		}
+		return nil
+	})
+	if vErr != nil {
+		return vErr
+	}
+	return err
+}
+
+// fetchHeaders fetches the headers from..to with the catch-up worker pool and
+// returns them in height order. Each worker fetches a batch of heights at a
+// time when the source supports batches.
+func (i *Indexer) fetchHeaders(ctx context.Context, from, to int64) ([]*bitcoin.BlockHeader, error) {
+	headers := make([]*bitcoin.BlockHeader, to-from+1)
+	chunks := make(chan []int64)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for w := 0; w < i.catchUpWorkers(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for heights := range chunks {
+				chunk, err := i.fetchHeaderChunk(ctx, heights)
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					continue
+				}
+				copy(headers[heights[0]-from:], chunk)
+			}
+		}()
+	}
+
+	size := int64(bitcoin.BatchSize(i.source))
+feed:
+	for start := from; start <= to; start += size {
+		heights := make([]int64, 0, size)
+		for h := start; h <= to && h < start+size; h++ {
+			heights = append(heights, h)
+		}
+
+		select {
+		case <-ctx.Done():
+			break feed
+		case chunks <- heights:
+		}
+	}
+	close(chunks)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
+
+// fetchHeaderChunk fetches the headers at consecutive heights, in height
+// order.
+func (i *Indexer) fetchHeaderChunk(ctx context.Context, heights []int64) ([]*bitcoin.BlockHeader, error) {
+	hashes, errs, err := bitcoin.GetBlockHashes(ctx, i.source, heights)
+	if err == nil {
+		err = firstItemError(errs)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get block hashes", logan.F{"from": heights[0]})
+	}
+
+	headers, errs, err := bitcoin.GetBlockHeaders(ctx, i.source, hashes)
+	if err == nil {
+		err = firstItemError(errs)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch headers", logan.F{"from": heights[0]})
+	}
+	return headers, nil
+}
+
+// firstItemError returns the first error of a batch of items, if any.
+func firstItemError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *Indexer) headerBatchSize() int {
+	if i.cfg.HeaderBatchSize < 1 {
+		return defaultHeaderBatchSize
+	}
+	return i.cfg.HeaderBatchSize
+}
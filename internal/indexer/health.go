@@ -0,0 +1,60 @@
+package indexer
+
+import (
+	"context"
+	"time"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+)
+
+// RunNodeHealth checks the nodes of a node pool every NodeHealthInterval,
+// failing over to the healthy node with the most work and alerting when the
+// nodes disagree on a block.
+func (i *Indexer) RunNodeHealth(ctx context.Context) {
+	pool, ok := i.source.(*bitcoin.NodePool)
+	if !ok || i.cfg.NodeHealthInterval <= 0 {
+		return
+	}
+
+	logger := i.logger.WithField("worker", "node_health")
+	logger.Info("node health checks started")
+	ticker := time.NewTicker(i.cfg.NodeHealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("node health checks stopped")
+			return
+		case <-ticker.C:
+		}
+
+		health := pool.CheckHealth(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		healthy := 0
+		for _, node := range health.Nodes {
+			if !node.Healthy {
+				logger.WithError(node.Err).WithField("node", node.Node).Warn("bitcoin node is unhealthy")
+				continue
+			}
+			healthy++
+		}
+		if healthy == 0 {
+			logger.Error("no healthy bitcoin node")
+		}
+
+		if health.Switched {
+			logger.WithField("node", health.Primary).Warn("failed over to another bitcoin node")
+		}
+
+		if c := health.Conflict; c != nil {
+			logger.WithFields(map[string]interface{}{
+				"height": c.Height,
+				"hashes": c.Hashes,
+			}).Error("bitcoin nodes disagree on the block at the same height, one of them may be eclipsed")
+		}
+	}
+}
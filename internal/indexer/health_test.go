@@ -0,0 +1,59 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+	"gitlab.com/distributed_lab/logan/v3"
+)
+
+// newInfoNode starts a node that only answers getblockchaininfo, with its tip
+// at height 10.
+func newInfoNode(t *testing.T, bestBlock string) *bitcoin.RPCClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"id": "indexer",
+			"result": bitcoin.BlockchainInfo{
+				Chain:         "regtest",
+				Blocks:        10,
+				Headers:       10,
+				BestBlockHash: bestBlock,
+				Chainwork:     "16",
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return bitcoin.NewRPCClient(server.URL, "user", "pass", bitcoin.RPCConfig{})
+}
+
+func TestNodeHealthAlertsOnDivergingNodes(t *testing.T) {
+	pool := bitcoin.NewNodePool(
+		newInfoNode(t, strings.Repeat("a", 64)),
+		newInfoNode(t, strings.Repeat("b", 64)),
+	)
+	logs := make(logLines, 100)
+	i := New(logan.New().Out(logs), nil, pool, Config{
+		Params:             &bitcoin.RegTestParams,
+		NodeHealthInterval: 10 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		i.RunNodeHealth(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitLog(t, logs, "bitcoin nodes disagree on the block at the same height")
+}
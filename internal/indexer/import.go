@@ -0,0 +1,161 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+	"gitlab.com/distributed_lab/logan/v3"
+	"gitlab.com/distributed_lab/logan/v3/errors"
+)
+
+var ErrImportUnsupported = errors.New("the bitcoin source cannot scan the utxo set")
+
+// CanImportUTXOs reports whether ImportUTXOs is supported by the source.
+func (i *Indexer) CanImportUTXOs() bool {
+	_, ok := i.source.(bitcoin.UTXOScanner)
+	return ok
+}
+
+// ImportUTXOs seeds the unspent outputs of address from the node's UTXO set
+// in the background, so its balance is known before the rescan finishes. The
+// rows are flagged as imported and reconciled once their block is indexed.
+// The import is cancelled with ctx, which has to outlive the request.
+func (i *Indexer) ImportUTXOs(ctx context.Context, addressID int64, address string) error {
+	scanner, ok := i.source.(bitcoin.UTXOScanner)
+	if !ok {
+		return ErrImportUnsupported
+	}
+
+	w := i.worker(i.logger.WithFields(map[string]interface{}{
+		"worker":     "import",
+		"address_id": addressID,
+	}))
+
+	go func() {
+		err := w.importUTXOs(ctx, scanner, addressID, address)
+		if err == nil || err == context.Canceled {
+			return
+		}
+		w.logger.WithError(err).Error("failed to import utxos")
+		if err := w.markImportFailed(addressID); err != nil {
+			w.logger.WithError(err).Error("failed to mark utxo import failed")
+		}
+	}()
+	return nil
+}
+
+// importUTXOs inserts the UTXOs of address as the header chain reaches their
+// blocks, as they reference the header of their block. Those the node found
+// in blocks the headers have not reached yet are retried every poll
+// interval, those below the first stored header are older than anything the
+// indexer tracks and are skipped.
+func (i *Indexer) importUTXOs(ctx context.Context, scanner bitcoin.UTXOScanner, addressID int64, address string) error {
+	scan, err := scanner.ScanTxOutSet(ctx, []string{fmt.Sprintf("addr(%s)", address)})
+	if err != nil {
+		return errors.Wrap(err, "failed to scan utxo set")
+	}
+
+	pending := scan.Unspents
+	imported, skipped := 0, 0
+	for {
+		first, err := i.db.BlockHeader().GetFirst()
+		if err != nil {
+			return errors.Wrap(err, "failed to get first block header")
+		}
+		last, err := i.db.BlockHeader().GetLast()
+		if err != nil {
+			return errors.Wrap(err, "failed to get last block header")
+		}
+
+		var ready, later []bitcoin.ScannedUTXO
+		for _, u := range pending {
+			switch {
+			case first == nil || u.Height > last.Height:
+				later = append(later, u)
+			case u.Height < first.Height:
+				skipped++
+			default:
+				ready = append(ready, u)
+			}
+		}
+
+		n, err := i.insertImported(addressID, ready)
+		if err != nil {
+			return err
+		}
+		imported += n
+
+		if len(later) == 0 {
+			break
+		}
+		pending = later
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(i.cfg.PollInterval):
+		}
+	}
+
+	i.logger.WithFields(map[string]interface{}{
+		"height":   scan.Height,
+		"imported": imported,
+		"skipped":  skipped,
+	}).Info("utxos imported")
+	return nil
+}
+
+// insertImported inserts the UTXOs that are not indexed yet, flagged as
+// imported, and returns how many it inserted.
+func (i *Indexer) insertImported(addressID int64, utxos []bitcoin.ScannedUTXO) (int, error) {
+	imported := 0
+	err := i.db.NewTransaction(func() error {
+		imported = 0
+		for _, u := range utxos {
+			existing, err := i.db.UTXO().GetByOutpoint(u.TxID, u.Vout)
+			if err != nil {
+				return errors.Wrap(err, "failed to get utxo")
+			}
+			if existing != nil {
+				continue
+			}
+
+			err = i.db.UTXO().Insert(data.UTXO{
+				TxID:        u.TxID,
+				Vout:        u.Vout,
+				AddressID:   addressID,
+				Amount:      u.Amount,
+				BlockHeight: u.Height,
+				Imported:    true,
+			})
+			if err != nil {
+				return errors.Wrap(err, "failed to insert utxo", logan.F{"tx_id": u.TxID, "vout": u.Vout})
+			}
+			// rolled back with its block like an indexed one
+			if err := i.recordUndo(u.Height, data.UndoCreateUTXO, u.TxID, u.Vout); err != nil {
+				return errors.Wrap(err, "failed to record undo")
+			}
+			imported++
+		}
+		return nil
+	})
+	return imported, err
+}
+
+// markImportFailed shows a failed import on the address while it is still
+// syncing, the rescan sets it active once it caught the balance up.
+func (i *Indexer) markImportFailed(addressID int64) error {
+	return i.db.NewTransaction(func() error {
+		addr, err := i.db.Address().GetByID(addressID)
+		if err != nil {
+			return errors.Wrap(err, "failed to get address")
+		}
+		if addr == nil || addr.Status != data.AddressStatusSyncing {
+			return nil
+		}
+		return i.db.Address().SetStatus(addressID, data.AddressStatusImportFailed)
+	})
+}
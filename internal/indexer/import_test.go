@@ -0,0 +1,54 @@
+package indexer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+)
+
+// fakeScanner finds the same unspent outputs whatever it is asked for.
+type fakeScanner []bitcoin.ScannedUTXO
+
+func (s fakeScanner) ScanTxOutSet(context.Context, []string) (*bitcoin.TxOutSetScan, error) {
+	return &bitcoin.TxOutSetScan{Success: true, Unspents: s}, nil
+}
+
+func TestImportWaitsForHeaders(t *testing.T) {
+	i, db := newTestIndexer(t, nil, Config{PollInterval: time.Hour})
+	addr, _ := db.Address().GetByAddress(alice)
+	// the header chain starts at a checkpoint at 1 and reaches 2
+	for _, height := range []int64{1, 2} {
+		db.BlockHeader().Insert(data.BlockHeader{Height: height})
+	}
+
+	scanner := fakeScanner{
+		{TxID: testTxID("before the checkpoint"), Height: 0},
+		{TxID: testTxID("in a stored block"), Height: 2},
+		{TxID: testTxID("ahead of the headers"), Height: 3},
+	}
+
+	// stopped while it waits for the header of block 3
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := i.importUTXOs(ctx, scanner, addr.ID, alice); err != context.Canceled {
+		t.Fatalf("import = %v, want it waiting for headers", err)
+	}
+	assertUTXOs(t, db, addr.ID, map[string]bool{testTxID("in a stored block"): false})
+
+	db.BlockHeader().Insert(data.BlockHeader{Height: 3})
+	if err := i.importUTXOs(context.Background(), scanner, addr.ID, alice); err != nil {
+		t.Fatal(err)
+	}
+	assertUTXOs(t, db, addr.ID, map[string]bool{
+		testTxID("in a stored block"):    false,
+		testTxID("ahead of the headers"): false,
+	})
+	for _, height := range []int64{2, 3} {
+		if actions, _ := db.UndoLog().SelectByHeight(height); len(actions) != 1 {
+			t.Errorf("undo log of block %d = %+v, want the imported utxo", height, actions)
+		}
+	}
+}
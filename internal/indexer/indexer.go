@@ -1,133 +1,272 @@
 package indexer
 
 import (
+	"context"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"time"
 
+	"github.com/Myrtilli/transaction-indexing-svc/internal/amount"
 	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
 	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+	"gitlab.com/distributed_lab/logan/v3"
+	"gitlab.com/distributed_lab/logan/v3/errors"
 )
 
-func (i *Indexer) SyncNextBlock() {
-	tip, err := i.db.BlockHeader().GetLast()
+// SyncNextBlock indexes the transactions of the block after the block cursor,
+// once its header has been synced.
+func (i *Indexer) SyncNextBlock(ctx context.Context) {
+	nextHeight, err := i.nextBlockHeight()
 	if err != nil {
-		i.logger.WithError(err).Error("failed to get tip")
+		i.logger.WithError(err).Error("failed to get block cursor")
 		return
 	}
 
-	var checkHeight int64
-	if tip == nil {
-		checkHeight = int64(i.cfg.StartHeight)
-	} else {
-		checkHeight = tip.Height
+	headers, err := i.db.BlockHeader().SelectRange(nextHeight, nextHeight)
+	if err != nil {
+		i.logger.WithError(err).Error("failed to get block header")
+		return
+	}
+	if len(headers) == 0 {
+		return
 	}
 
-	var rpcHash string
-	err = i.rpcClient.Call("getblockhash", []any{checkHeight}, &rpcHash)
+	scripts, err := i.trackedScripts()
 	if err != nil {
+		i.logger.WithError(err).Error("failed to get tracked scripts")
 		return
 	}
 
-	if tip != nil && tip.BlockHash != rpcHash {
-		i.logger.WithFields(map[string]interface{}{
-			"height":   checkHeight,
-			"db_hash":  tip.BlockHash,
-			"rpc_hash": rpcHash,
-		}).Warn("reorg detected at tip!")
-
-		i.HandleReorg(checkHeight + 1)
+	block := i.fetchBlock(ctx, headers[0], scripts)
+	if block.err != nil {
+		i.logger.WithError(block.err).WithField("height", nextHeight).Error("failed to fetch block")
 		return
 	}
 
-	nextHeight := checkHeight
-	if tip != nil {
-		nextHeight = tip.Height + 1
+	if err := i.processBlock(ctx, block.header, block.txs, i.advanceBlockCursor); err != nil {
+		i.logger.WithError(err).WithField("height", nextHeight).Error("failed to index block")
 	}
+}
 
-	var nextBlockHash string
-	err = i.rpcClient.Call("getblockhash", []any{nextHeight}, &nextBlockHash)
+// nextBlockHeight returns the height of the next block to index.
+func (i *Indexer) nextBlockHeight() (int64, error) {
+	cursor, err := i.db.SyncCursor().Get(data.CursorBlocks)
 	if err != nil {
-		return
+		return 0, err
+	}
+	if cursor == nil {
+		return int64(i.cfg.StartHeight), nil
 	}
+	return cursor.Height + 1, nil
+}
 
-	header, err := i.rpcClient.GetBlockHeader(nextBlockHash)
-	if err != nil {
-		i.logger.WithField("height", nextHeight).WithError(err).Error("failed to fetch header")
-		return
+func (i *Indexer) advanceBlockCursor(height int64) error {
+	if err := i.db.SyncCursor().Set(data.CursorBlocks, height); err != nil {
+		return err
 	}
+	return i.pruneUndoLog(height)
+}
 
-	txs, err := i.rpcClient.GetBlock(nextBlockHash)
-	if err != nil {
-		i.logger.WithError(err).Error("failed to fetch block txs")
-		return
+type fetchedBlock struct {
+	height int64
+	header *bitcoin.BlockHeader
+	txs    []bitcoin.Transaction
+	err    error
+}
+
+// fetchBlock fetches the transactions of a block whose header is already
+// stored. With scripts set, the block is only downloaded if its filter
+// matches one of them; otherwise it is returned without transactions.
+func (i *Indexer) fetchBlock(ctx context.Context, header data.BlockHeader, scripts [][]byte) fetchedBlock {
+	res := fetchedBlock{
+		height: header.Height,
+		header: fromDataHeader(header),
 	}
 
-	i.processBlock(header, txs)
+	if scripts != nil {
+		match, err := i.matchFilter(ctx, header.BlockHash, scripts)
+		if err != nil {
+			res.err = err
+			return res
+		}
+		if !match {
+			i.logger.WithField("height", header.Height).Debug("block filter did not match, skipping download")
+			return res
+		}
+	}
+
+	res.txs, res.err = i.source.GetBlock(ctx, header.BlockHash)
+	if res.err != nil {
+		res.err = errors.Wrap(res.err, "failed to fetch block txs")
+	}
+
+	return res
 }
 
-func (i *Indexer) processBlock(header *bitcoin.BlockHeader, txs []bitcoin.Transaction) {
-	if bitcoin.CheckProofOfWork(header) {
-		i.logger.WithField("hash", header.BlockHash).Info("Passed check of proof")
-	} else {
-		i.logger.WithField("hash", header.BlockHash).Error("Failed check of proof")
-		return
+// processBlock indexes the transactions of a block and calls advance with its
+// height in the same DB transaction, to move whatever cursor the caller
+// keeps.
+func (i *Indexer) processBlock(ctx context.Context, header *bitcoin.BlockHeader, txs []bitcoin.Transaction, advance func(height int64) error) error {
+	// a wallet address used in the block can derive addresses paid later in
+	// the same block, selection is repeated until no wallet extends
+	var trackedTxs []bitcoin.Transaction
+	var proofs map[string][]data.MerkleNode
+	for {
+		var err error
+		trackedTxs, proofs, err = i.selectTxs(ctx, header, txs)
+		if err != nil {
+			return err
+		}
+
+		extended, err := i.useWalletAddresses(trackedTxs)
+		if err != nil {
+			return err
+		}
+		if !extended {
+			break
+		}
 	}
 
-	err := i.db.BlockHeader().Insert(data.BlockHeader{
-		BlockHash:      header.BlockHash,
-		PreviousHash:   header.PreviousHash,
-		Height:         header.Height,
-		MerkleRoot:     header.MerkleRoot,
-		Timestamp:      time.Unix(header.Timestamp, 0),
-		Difficulty:     int64(header.Difficulty),
-		Nonce:          int64(header.Nonce),
-		TransactionNum: header.TransactionNum,
+	prevouts := i.resolvePrevouts(ctx, trackedTxs, txs)
+
+	err := i.db.NewTransaction(func() error {
+		for _, tx := range trackedTxs {
+			if err := i.updateDatabase(tx, proofs[tx.TxID], header, prevouts); err != nil {
+				return errors.Wrap(err, "failed to index transaction", logan.F{"tx_id": tx.TxID})
+			}
+		}
+
+		return advance(header.Height)
 	})
 	if err != nil {
-		i.logger.WithError(err).Error("failed to insert block header")
-		return
+		return err
 	}
 
+	for _, tx := range trackedTxs {
+		i.logger.WithField("tx_id", tx.TxID).Info("indexed transaction")
+	}
+
+	i.logger.WithField("height", header.Height).Info("block indexed")
+	return nil
+}
+
+// selectTxs returns the transactions of the block that touch tracked
+// addresses and have a valid merkle proof, with their merkle branches.
+func (i *Indexer) selectTxs(ctx context.Context, header *bitcoin.BlockHeader, txs []bitcoin.Transaction) ([]bitcoin.Transaction, map[string][]data.MerkleNode, error) {
+	var candidates []bitcoin.Transaction
+	blockUTXOs := make(map[string]struct{})
 	for _, tx := range txs {
-		tracked := false
-		for _, out := range tx.Outputs {
-			addr := i.getAddrFromOutput(out)
-			if addr != "" && i.isAddressTracked(addr) {
-				tracked = true
-				break
+		if i.isTxTracked(tx, blockUTXOs) {
+			candidates = append(candidates, tx)
+		}
+	}
+
+	txids := make([]string, len(candidates))
+	for n, tx := range candidates {
+		txids[n] = tx.TxID
+	}
+	rawProofs, proofErrs, err := bitcoin.GetTxOutProofs(ctx, i.source, txids, header.BlockHash)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get tx out proofs", logan.F{"height": header.Height})
+	}
+
+	var trackedTxs []bitcoin.Transaction
+	proofs := make(map[string][]data.MerkleNode)
+	for n, tx := range candidates {
+		entry := i.logger.WithField("tx_id", tx.TxID)
+
+		err := proofErrs[n]
+		var proof []data.MerkleNode
+		if err == nil {
+			proof, err = verifyTxProof(tx.TxID, rawProofs[n], header)
+		}
+		if err != nil {
+			if !i.params.AllowMissingProofs {
+				entry.WithError(err).Error("rejected transaction with invalid merkle proof")
+				continue
 			}
+			entry.WithError(err).WithField("network", i.params.Name).Warn("indexing transaction without a valid merkle proof")
+		} else {
+			entry.Debug("verified proof for tx")
 		}
 
-		if tracked {
-			proof, err := i.rpcClient.GetTxOutProof(tx.TxID, header.BlockHash)
+		proofs[tx.TxID] = proof
+		trackedTxs = append(trackedTxs, tx)
+	}
 
-			entry := i.logger.WithField("tx_id", tx.TxID)
+	return trackedTxs, proofs, nil
+}
 
-			if err == nil && bitcoin.VerifyMerkleProof(tx.TxID, [][]byte{proof}, header.MerkleRoot) {
-				entry.Info("verified proof for tx")
-			} else {
-				entry.Warn("skipping real Merkle check for tx (Regtest mode)")
+// useWalletAddresses marks the wallet addresses txs pay to as used, each in
+// its own DB transaction ahead of the block, and reports whether a wallet
+// derived new addresses.
+func (i *Indexer) useWalletAddresses(txs []bitcoin.Transaction) (bool, error) {
+	extended := false
+	for _, tx := range txs {
+		for _, out := range tx.Outputs {
+			addrStr, _ := outputAddress(i.params, out)
+			if addrStr == "" {
+				continue
+			}
+			addr, err := i.db.Address().GetByAddress(addrStr)
+			if err != nil {
+				return false, errors.Wrap(err, "failed to get address")
+			}
+			if addr == nil || addr.WalletID == nil || addr.Used || !i.inScope(addr.ID) {
+				continue
 			}
 
-			i.updateDatabase(tx, header)
-			entry.Info("indexed transaction")
+			var added bool
+			err = i.db.NewTransaction(func() error {
+				var err error
+				added, err = i.useWalletAddress(*addr)
+				return err
+			})
+			if err != nil {
+				return false, errors.Wrap(err, "failed to extend wallet")
+			}
+			extended = extended || added
 		}
 	}
-
-	i.logger.WithField("height", header.Height).Info("block indexed")
+	return extended, nil
 }
 
-func (i *Indexer) getAddrFromOutput(out bitcoin.TxOutput) string {
-	if out.Address != "" {
-		return out.Address
+// verifyTxProof checks the gettxoutproof of txID against the merkle root of
+// the header being indexed and returns the merkle branch.
+func verifyTxProof(txID string, raw []byte, header *bitcoin.BlockHeader) ([]data.MerkleNode, error) {
+	branch, err := bitcoin.VerifyMerkleProof(txID, raw, header.MerkleRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := make([]data.MerkleNode, len(branch))
+	for n, node := range branch {
+		proof[n] = data.MerkleNode{
+			Hash:   bitcoin.HashToHex(node.Hash),
+			IsLeft: node.IsLeft,
+		}
 	}
-	if out.ScriptPubKey.Address != "" {
-		return out.ScriptPubKey.Address
+	return proof, nil
+}
+
+// outputAddress returns the address an output pays to, empty if it has none,
+// and its script type. The script is decoded when the source provides it,
+// otherwise the address reported by the source is used as is.
+func outputAddress(params *bitcoin.ChainParams, out bitcoin.TxOutput) (string, string) {
+	if script, err := hex.DecodeString(out.ScriptPubKey.Hex); err == nil && len(script) > 0 {
+		return params.ScriptAddress(script)
 	}
-	if len(out.ScriptPubKey.Addresses) > 0 {
-		return out.ScriptPubKey.Addresses[0]
+
+	switch {
+	case out.Address != "":
+		return out.Address, out.ScriptPubKey.Type
+	case out.ScriptPubKey.Address != "":
+		return out.ScriptPubKey.Address, out.ScriptPubKey.Type
+	case len(out.ScriptPubKey.Addresses) > 0:
+		return out.ScriptPubKey.Addresses[0], out.ScriptPubKey.Type
 	}
-	return "unknown"
+	return "", out.ScriptPubKey.Type
 }
 
 func (i *Indexer) CurrentTip() int64 {
@@ -140,80 +279,258 @@ func (i *Indexer) CurrentTip() int64 {
 
 func (i *Indexer) isAddressTracked(address string) bool {
 	addr, err := i.db.Address().GetByAddress(address)
-	return err == nil && addr != nil
+	return err == nil && addr != nil && i.inScope(addr.ID)
+}
+
+// inScope reports whether the indexer is indexing addressID: every address
+// for live sync, a single one or those of a wallet for a rescan.
+func (i *Indexer) inScope(addressID int64) bool {
+	switch {
+	case i.scope.walletID != 0:
+		addr, err := i.db.Address().GetByID(addressID)
+		return err == nil && addr.WalletID != nil && *addr.WalletID == i.scope.walletID
+	case i.scope.addressID != 0:
+		return i.scope.addressID == addressID
+	}
+	return true
 }
 
-func (i *Indexer) updateDatabase(tx bitcoin.Transaction, header *bitcoin.BlockHeader) {
+// isTxTracked reports whether tx pays to a tracked address or spends a tracked
+// UTXO. Outpoints paying to tracked addresses are added to blockUTXOs, so spends
+// of outputs created earlier in the same block are matched before they reach
+// the database.
+func (i *Indexer) isTxTracked(tx bitcoin.Transaction, blockUTXOs map[string]struct{}) bool {
+	tracked := false
+	for _, out := range tx.Outputs {
+		addr, _ := outputAddress(i.params, out)
+		if addr != "" && i.isAddressTracked(addr) {
+			blockUTXOs[outpointKey(tx.TxID, out.Vout)] = struct{}{}
+			tracked = true
+		}
+	}
+	if tracked {
+		return true
+	}
+
+	for _, in := range tx.Inputs {
+		if in.PrevTxID == "" {
+			continue
+		}
+		if _, ok := blockUTXOs[outpointKey(in.PrevTxID, in.Vout)]; ok {
+			return true
+		}
+		if i.isUTXOTracked(in.PrevTxID, in.Vout) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (i *Indexer) isUTXOTracked(txID string, vout int64) bool {
+	utxo, err := i.db.UTXO().GetByOutpoint(txID, vout)
+	return err == nil && utxo != nil && !utxo.IsSpent && i.inScope(utxo.AddressID)
+}
+
+func outpointKey(txID string, vout int64) string {
+	return fmt.Sprintf("%s:%d", txID, vout)
+}
+
+func (i *Indexer) updateDatabase(tx bitcoin.Transaction, proof []data.MerkleNode, header *bitcoin.BlockHeader, prevouts map[string]prevout) error {
 	var dbInputs []data.TransactionInput
 	var dbOutputs []data.TransactionOutput
-	var transactionAddressID *int64
-	var transactionAmount int64
+
+	// the fee is known only when every input was resolved, coinbase has none
+	var inputTotal, outputTotal amount.Amount
+	feeKnown := true
+
+	flows := newAddressFlows()
 
 	for _, in := range tx.Inputs {
 		var prevTxID *string
 		if in.PrevTxID != "" {
 			prevTxID = &in.PrevTxID
 		}
-		dbInputs = append(dbInputs, data.TransactionInput{
+		dbInput := data.TransactionInput{
 			TxID:     tx.TxID,
 			PrevTxID: prevTxID,
 			VoutIdx:  uint32(in.Vout),
-		})
-		_ = i.db.UTXO().MarkAsSpent(in.PrevTxID, in.Vout)
+		}
+		if p, ok := prevouts[outpointKey(in.PrevTxID, in.Vout)]; ok && prevTxID != nil {
+			dbInput.Address = p.address
+			dbInput.Amount = p.amount
+			inputTotal += p.amount
+		} else {
+			feeKnown = false
+		}
+		dbInputs = append(dbInputs, dbInput)
+
+		if prevTxID == nil {
+			continue
+		}
+
+		utxo, err := i.db.UTXO().GetByOutpoint(in.PrevTxID, in.Vout)
+		if err != nil {
+			return errors.Wrap(err, "failed to get spent utxo")
+		}
+		if utxo == nil || utxo.IsSpent || !i.inScope(utxo.AddressID) {
+			continue
+		}
+
+		if err := i.db.UTXO().MarkAsSpent(in.PrevTxID, in.Vout, tx.TxID, header.Height); err != nil {
+			return errors.Wrap(err, "failed to mark utxo as spent")
+		}
+		if err := i.recordUndo(header.Height, data.UndoSpendUTXO, in.PrevTxID, in.Vout); err != nil {
+			return errors.Wrap(err, "failed to record utxo spend")
+		}
+
+		flows.add(utxo.AddressID, -utxo.Amount)
 	}
 
 	for _, out := range tx.Outputs {
-		addrStr := i.getAddrFromOutput(out)
-		amountSat := int64(out.Value * 1e8)
+		addrStr, scriptType := outputAddress(i.params, out)
+		outputTotal += out.Value
 
 		dbOutputs = append(dbOutputs, data.TransactionOutput{
-			TxID:    tx.TxID,
-			Address: addrStr,
-			Amount:  amountSat,
-			VoutIdx: uint32(out.Vout),
+			TxID:       tx.TxID,
+			Address:    addrStr,
+			ScriptType: scriptType,
+			Amount:     out.Value,
+			VoutIdx:    uint32(out.Vout),
 		})
 
 		if addrStr != "" {
 			addrRecord, err := i.db.Address().GetByAddress(addrStr)
-			if err == nil && addrRecord != nil {
-				if transactionAddressID == nil {
-					transactionAddressID = &addrRecord.ID
-					transactionAmount = amountSat
+			if err == nil && addrRecord != nil && i.inScope(addrRecord.ID) {
+				// a rescan and live sync can both see the blocks around
+				// the block cursor, whichever comes first records them
+				existing, err := i.db.UTXO().GetByOutpoint(tx.TxID, out.Vout)
+				if err != nil {
+					return errors.Wrap(err, "failed to get utxo")
 				}
 
-				err = i.db.UTXO().Insert(data.UTXO{
-					TxID:        tx.TxID,
-					Vout:        out.Vout,
-					AddressID:   addrRecord.ID,
-					Amount:      amountSat,
-					BlockHeight: header.Height,
-				})
+				if existing != nil && existing.Imported {
+					if err := i.db.UTXO().ClearImported(tx.TxID, out.Vout); err != nil {
+						return errors.Wrap(err, "failed to reconcile imported utxo")
+					}
+				}
 
-				if err == nil {
-					i.undoLog.Add(UndoAction{
-						BlockHeight: header.Height,
-						Action:      "create_utxo",
+				if existing == nil {
+					err = i.db.UTXO().Insert(data.UTXO{
 						TxID:        tx.TxID,
 						Vout:        out.Vout,
+						AddressID:   addrRecord.ID,
+						Amount:      out.Value,
+						BlockHeight: header.Height,
 					})
+					if err != nil {
+						return errors.Wrap(err, "failed to insert utxo")
+					}
+
+					if err := i.recordUndo(header.Height, data.UndoCreateUTXO, tx.TxID, out.Vout); err != nil {
+						return errors.Wrap(err, "failed to record utxo creation")
+					}
 				}
+
+				if addrRecord.WalletID != nil && !addrRecord.Used {
+					if _, err := i.useWalletAddress(*addrRecord); err != nil {
+						return errors.Wrap(err, "failed to extend wallet")
+					}
+				}
+
+				flows.add(addrRecord.ID, out.Value)
 			}
 		}
 	}
 
-	err := i.db.Transaction().Insert(data.Transaction{
-		TxID:        tx.TxID,
-		AddressID:   transactionAddressID,
-		Amount:      transactionAmount,
-		BlockHeight: header.Height,
-		BlockHash:   header.BlockHash,
-		MerkleProof: json.RawMessage(`[]`),
-		CreatedAt:   time.Now(),
-		Inputs:      dbInputs,
-		Outputs:     dbOutputs,
-	})
+	if len(flows.addressIDs) == 0 {
+		return nil
+	}
+
+	var fee *amount.Amount
+	if feeKnown && len(tx.Inputs) > 0 {
+		f := inputTotal - outputTotal
+		fee = &f
+	}
 
+	if proof == nil {
+		proof = []data.MerkleNode{}
+	}
+	merkleProof, err := json.Marshal(proof)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal merkle proof")
+	}
+
+	storedOutput, err := i.db.Transaction().GetOutput(tx.TxID, int64(dbOutputs[0].VoutIdx))
 	if err != nil {
-		i.logger.WithError(err).WithField("tx_id", tx.TxID).Error("failed to insert transaction")
+		return errors.Wrap(err, "failed to get transaction output")
 	}
+	// inputs and outputs go with the first row of the transaction, unless
+	// another pass over this block stored them already
+	withRows := storedOutput == nil
+	inserted := false
+
+	// one history row per tracked address, inputs and outputs are shared by
+	// tx_id and stored only once
+	for _, addressID := range flows.addressIDs {
+		existing, err := i.db.Transaction().GetByTxIDAddressID(tx.TxID, addressID)
+		if err != nil {
+			return errors.Wrap(err, "failed to get transaction")
+		}
+		if existing != nil {
+			continue
+		}
+
+		direction, value := flows.get(addressID)
+
+		dbTx := data.Transaction{
+			TxID:        tx.TxID,
+			AddressID:   &addressID,
+			Amount:      value,
+			Direction:   direction,
+			Fee:         fee,
+			BlockHeight: header.Height,
+			BlockHash:   header.BlockHash,
+			MerkleProof: merkleProof,
+			CreatedAt:   time.Now(),
+		}
+		if withRows && !inserted {
+			dbTx.Inputs = dbInputs
+			dbTx.Outputs = dbOutputs
+		}
+
+		if err := i.db.Transaction().Insert(dbTx); err != nil {
+			return errors.Wrap(err, "failed to insert transaction")
+		}
+		inserted = true
+	}
+
+	// promote the pending entry, if the mempool watcher saw the transaction
+	// first; after a rollback the watcher picks it up from the mempool again
+	if err := i.db.Mempool().DeleteByTxID(tx.TxID); err != nil {
+		return errors.Wrap(err, "failed to promote pending transaction")
+	}
+
+	if !inserted {
+		return nil
+	}
+
+	if err := i.recordUndo(header.Height, data.UndoInsertTransaction, tx.TxID, 0); err != nil {
+		return errors.Wrap(err, "failed to record transaction insert")
+	}
+	if !withRows {
+		return nil
+	}
+	for _, in := range dbInputs {
+		if err := i.recordUndo(header.Height, data.UndoInsertTxInput, tx.TxID, int64(in.VoutIdx)); err != nil {
+			return errors.Wrap(err, "failed to record transaction input insert")
+		}
+	}
+	for _, out := range dbOutputs {
+		if err := i.recordUndo(header.Height, data.UndoInsertTxOutput, tx.TxID, int64(out.VoutIdx)); err != nil {
+			return errors.Wrap(err, "failed to record transaction output insert")
+		}
+	}
+
+	return nil
 }
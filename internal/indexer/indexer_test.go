@@ -0,0 +1,114 @@
+package indexer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/amount"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+)
+
+func TestSyncIndexesTrackedTransactions(t *testing.T) {
+	chain, blocks := newTestChain(t)
+	i, db := newTestIndexer(t, chain, Config{})
+
+	i.sync(context.Background())
+
+	assertCursor(t, db, 3)
+	addr, _ := db.Address().GetByAddress(alice)
+	assertUTXOs(t, db, addr.ID, map[string]bool{
+		testTxID("coinbase 1"):     true,
+		testTxID("alice pays bob"): false,
+	})
+	assertHistory(t, db, addr.ID, testTxID("coinbase 1"), testTxID("alice pays bob"))
+
+	tx, _ := db.Transaction().GetByTxIDAddressID(testTxID("alice pays bob"), addr.ID)
+	if tx.BlockHash != blocks[2].Header.BlockHash || len(tx.Inputs) != 1 || len(tx.Outputs) != 2 {
+		t.Errorf("indexed transaction = %+v", tx)
+	}
+	if tx.Fee == nil || *tx.Fee != amount.SatoshiPerBitcoin {
+		t.Errorf("fee = %v, want 1 BTC", tx.Fee)
+	}
+	if string(tx.MerkleProof) == "[]" {
+		t.Error("transaction stored without its merkle branch")
+	}
+}
+
+func TestSyncRejectsInvalidProofs(t *testing.T) {
+	chain, blocks := newTestChain(t)
+	genesis, err := bitcoin.SerializeHeader(&blocks[0].Header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every proof is for the genesis block instead of the one indexed
+	chain.Proofs = func(_ bitcoin.FakeBlock, txid string) ([]byte, error) {
+		hash, err := bitcoin.HashFromHex(txid)
+		if err != nil {
+			return nil, err
+		}
+		return bitcoin.BuildMerkleBlock(genesis, [][32]byte{hash}, hash)
+	}
+
+	params := bitcoin.RegTestParams
+	params.AllowMissingProofs = false
+	i, db := newTestIndexer(t, chain, Config{Params: &params})
+
+	i.sync(context.Background())
+
+	// the blocks are indexed, without the transactions that failed
+	assertCursor(t, db, 3)
+	addr, _ := db.Address().GetByAddress(alice)
+	assertUTXOs(t, db, addr.ID, map[string]bool{})
+	assertHistory(t, db, addr.ID)
+}
+
+func TestSyncFollowsWalletGapWithinABlock(t *testing.T) {
+	i, db := newTestIndexer(t, nil, Config{})
+	// BIP32 test vector 1, a single chain with a gap of one address
+	db.Wallet().Insert(data.Wallet{
+		Descriptor: "wpkh(xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8/*)",
+		GapLimit:   1,
+	})
+	wallet := db.state.wallets[0]
+	if _, err := ExtendWallet(db, i.params, wallet, data.AddressStatusActive); err != nil {
+		t.Fatal(err)
+	}
+	desc, _ := bitcoin.ParseDescriptor(wallet.Descriptor)
+	derive := func(index uint32) string {
+		address, err := desc.Address(i.params, 0, index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return address
+	}
+
+	// the second payment is to an address derived only once the first one
+	// is seen used
+	b1 := mineBlock(t, regtestGenesis.Header,
+		coinbase("coinbase 1", pay(derive(0), 0, 50)),
+		bitcoin.Transaction{
+			TxID:    testTxID("pays the next address"),
+			Inputs:  []bitcoin.TxInput{{PrevTxID: testTxID("elsewhere"), Vout: 0}},
+			Outputs: []bitcoin.TxOutput{pay(derive(1), 0, 1)},
+		},
+	)
+	chain := bitcoin.NewFakeChain()
+	chain.SetBlock(regtestGenesis)
+	chain.SetBlock(b1)
+	i.source = chain
+
+	i.sync(context.Background())
+
+	assertCursor(t, db, 1)
+	for index, txid := range []string{testTxID("coinbase 1"), testTxID("pays the next address")} {
+		addr, _ := db.Address().GetByAddress(derive(uint32(index)))
+		if addr == nil || !addr.Used {
+			t.Fatalf("address %d = %+v, want it derived and used", index, addr)
+		}
+		assertUTXOs(t, db, addr.ID, map[string]bool{txid: false})
+	}
+	if addr, _ := db.Address().GetByAddress(derive(2)); addr == nil || addr.Used {
+		t.Errorf("address past the gap = %+v, want it derived and unused", addr)
+	}
+}
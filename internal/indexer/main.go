@@ -7,34 +7,118 @@ import (
 	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
 	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
 	"gitlab.com/distributed_lab/logan/v3"
+	"gitlab.com/distributed_lab/logan/v3/errors"
 )
 
 type Config struct {
-	MaxReorgDepth int
-	PollInterval  time.Duration
-	StartHeight   int
+	MaxReorgDepth       int
+	PollInterval        time.Duration
+	MempoolPollInterval time.Duration
+	StartHeight         int
+	ZMQURL              string
+	CatchUpWorkers      int
+	HeaderBatchSize     int
+	// FilterScan matches BIP158 block filters against tracked addresses and
+	// only downloads the blocks that match
+	FilterScan bool
+	// NodeHealthInterval is how often the nodes of a node pool are
+	// checked
+	NodeHealthInterval time.Duration
+	// Params are the rules of the network, mainnet if nil
+	Params *bitcoin.ChainParams
+	// Checkpoints are added to the built-in ones of the network
+	Checkpoints []bitcoin.Checkpoint
 }
 
 type Indexer struct {
-	db        data.MasterQ
-	rpcClient *bitcoin.RPCClient
-	cfg       Config
-	logger    *logan.Entry
-	undoLog   *UndoLog
+	db     data.MasterQ
+	source bitcoin.BlockSource
+	cfg    Config
+	params *bitcoin.ChainParams
+	logger *logan.Entry
+
+	checkpoints []bitcoin.Checkpoint
+	// filters is set when filter scanning is on.
+	filters bitcoin.FilterSource
+	// scope limits indexing to one address or wallet in the copies used
+	// for rescans, the zero value means every tracked address.
+	scope rescanScope
+	// walletExtended is set when indexing a block derived new wallet
+	// addresses, so block filters are matched against them from then on.
+	walletExtended bool
+
+	// halted is set once a block fails header validation.
+	halted *ValidationError
+
+	blockNotify chan struct{}
+	txNotify    chan struct{}
+	// zmqReconnectDelay is how long runZMQ waits before reconnecting.
+	zmqReconnectDelay time.Duration
+}
+
+type rescanScope struct {
+	addressID int64
+	walletID  int64
 }
 
-func New(logger *logan.Entry, db data.MasterQ, rpc *bitcoin.RPCClient, cfg Config) *Indexer {
+func New(logger *logan.Entry, db data.MasterQ, source bitcoin.BlockSource, cfg Config) *Indexer {
+	params := cfg.Params
+	if params == nil {
+		params = &bitcoin.MainNetParams
+	}
+
+	checkpoints, err := bitcoin.MergeCheckpoints(params.Checkpoints, cfg.Checkpoints)
+	if err != nil {
+		panic(errors.Wrap(err, "invalid checkpoints"))
+	}
+
+	var filters bitcoin.FilterSource
+	if cfg.FilterScan {
+		var ok bool
+		if filters, ok = source.(bitcoin.FilterSource); !ok {
+			panic(errors.New("block source does not serve compact block filters"))
+		}
+	}
+
 	return &Indexer{
-		logger:    logger.WithField("service", "indexer"),
-		db:        db,
-		rpcClient: rpc,
-		cfg:       cfg,
-		undoLog:   NewUndoLog(),
+		logger: logger.WithField("service", "indexer"),
+		db:     db,
+		source: source,
+		cfg:    cfg,
+		params: params,
+
+		checkpoints: checkpoints,
+		filters:     filters,
+
+		blockNotify: make(chan struct{}, 1),
+		txNotify:    make(chan struct{}, 1),
+
+		zmqReconnectDelay: zmqReconnectDelay,
+	}
+}
+
+// worker returns an indexer for a background worker. It has its own database
+// handle and shares none of the state live sync writes to, so it can run
+// alongside Run.
+func (i *Indexer) worker(logger *logan.Entry) *Indexer {
+	return &Indexer{
+		logger: logger,
+		db:     i.db.New(),
+		source: i.source,
+		cfg:    i.cfg,
+		params: i.params,
+
+		checkpoints: i.checkpoints,
+		filters:     i.filters,
 	}
 }
 
 func (i *Indexer) Run(ctx context.Context) {
 	i.logger.Info("indexer started")
+	if i.cfg.ZMQURL != "" {
+		go i.runZMQ(ctx)
+	}
+
 	ticker := time.NewTicker(i.cfg.PollInterval)
 	defer ticker.Stop()
 
@@ -44,7 +128,27 @@ func (i *Indexer) Run(ctx context.Context) {
 			i.logger.Info("indexer stopped")
 			return
 		case <-ticker.C:
-			i.SyncNextBlock()
+			i.sync(ctx)
+		case <-i.blockNotify:
+			i.sync(ctx)
+		}
+
+		if i.halted != nil {
+			i.logger.WithError(i.halted).Error("indexer halted")
+			return
 		}
 	}
 }
+
+// sync brings the header chain up to the node's tip and then indexes the
+// blocks it has headers for.
+func (i *Indexer) sync(ctx context.Context) {
+	i.SyncHeaders(ctx)
+	if i.halted != nil {
+		return
+	}
+
+	i.SyncNextBlock(ctx)
+	i.CatchUp(ctx)
+	i.finishRescans(ctx)
+}
@@ -0,0 +1,447 @@
+package indexer
+
+import (
+	"maps"
+	"slices"
+	"sort"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+)
+
+// memDB is an in-memory data.MasterQ for driving the indexer in tests. It
+// keeps the tables block indexing touches; calls to anything else panic on
+// the nil interfaces embedded in its tables. NewTransaction rolls the state
+// back when fn fails, like the pg implementation does.
+type memDB struct {
+	state *memState
+}
+
+type memState struct {
+	nextID    int64
+	addresses []data.Address
+	headers   map[int64]data.BlockHeader
+	txs       []data.Transaction
+	inputs    []data.TransactionInput
+	outputs   []data.TransactionOutput
+	utxos     []data.UTXO
+	undo      []data.UndoAction
+	mempool   []data.MempoolTransaction
+	cursors   map[string]int64
+	wallets   []data.Wallet
+}
+
+func newMemDB() *memDB {
+	return &memDB{state: &memState{
+		headers: make(map[int64]data.BlockHeader),
+		cursors: make(map[string]int64),
+	}}
+}
+
+func (s *memState) clone() *memState {
+	return &memState{
+		nextID:    s.nextID,
+		addresses: slices.Clone(s.addresses),
+		headers:   maps.Clone(s.headers),
+		txs:       slices.Clone(s.txs),
+		inputs:    slices.Clone(s.inputs),
+		outputs:   slices.Clone(s.outputs),
+		utxos:     slices.Clone(s.utxos),
+		undo:      slices.Clone(s.undo),
+		mempool:   slices.Clone(s.mempool),
+		cursors:   maps.Clone(s.cursors),
+		wallets:   slices.Clone(s.wallets),
+	}
+}
+
+func (s *memState) id() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+func (m *memDB) New() data.MasterQ               { return m }
+func (m *memDB) User() data.Userdb               { return memUsers{} }
+func (m *memDB) Address() data.Addressdb         { return memAddresses{db: m} }
+func (m *memDB) BlockHeader() data.BlockHeaderdb { return memHeaders{db: m} }
+func (m *memDB) Transaction() data.Transactiondb { return memTransactions{db: m} }
+func (m *memDB) UTXO() data.UTXOdb               { return memUTXOs{db: m} }
+func (m *memDB) UndoLog() data.UndoLogdb         { return memUndoLog{db: m} }
+func (m *memDB) Mempool() data.Mempooldb         { return memMempool{db: m} }
+func (m *memDB) SyncCursor() data.SyncCursordb   { return memCursors{db: m} }
+func (m *memDB) RescanJob() data.RescanJobdb     { return memRescanJobs{} }
+func (m *memDB) Wallet() data.Walletdb           { return memWallets{db: m} }
+
+func (m *memDB) NewTransaction(fn func() error) error {
+	saved := m.state.clone()
+	if err := fn(); err != nil {
+		m.state = saved
+		return err
+	}
+	return nil
+}
+
+type memUsers struct{ data.Userdb }
+
+type memWallets struct {
+	data.Walletdb
+	db *memDB
+}
+
+func (w memWallets) Insert(wallet data.Wallet) error {
+	wallet.ID = w.db.state.id()
+	w.db.state.wallets = append(w.db.state.wallets, wallet)
+	return nil
+}
+
+func (w memWallets) GetByID(id int64) (*data.Wallet, error) {
+	for _, wallet := range w.db.state.wallets {
+		if wallet.ID == id {
+			return &wallet, nil
+		}
+	}
+	return nil, nil
+}
+
+// memRescanJobs has no jobs, so live sync never finishes a rescan.
+type memRescanJobs struct{ data.RescanJobdb }
+
+func (memRescanJobs) SelectByStatus(string) ([]data.RescanJob, error) { return nil, nil }
+func (memRescanJobs) Rewind(int64) error                              { return nil }
+
+type memAddresses struct {
+	data.Addressdb
+	db *memDB
+}
+
+func (a memAddresses) Insert(addr data.Address) error {
+	addr.ID = a.db.state.id()
+	a.db.state.addresses = append(a.db.state.addresses, addr)
+	return nil
+}
+
+func (a memAddresses) SelectAll() ([]data.Address, error) {
+	return slices.Clone(a.db.state.addresses), nil
+}
+
+func (a memAddresses) GetByAddress(address string) (*data.Address, error) {
+	for _, addr := range a.db.state.addresses {
+		if addr.Address == address {
+			return &addr, nil
+		}
+	}
+	return nil, nil
+}
+
+func (a memAddresses) GetByID(id int64) (*data.Address, error) {
+	for _, addr := range a.db.state.addresses {
+		if addr.ID == id {
+			return &addr, nil
+		}
+	}
+	return nil, nil
+}
+
+func (a memAddresses) GetByAddressUserID(address string, userID int64) (*data.Address, error) {
+	for _, addr := range a.db.state.addresses {
+		if addr.Address == address && addr.UserID == userID {
+			return &addr, nil
+		}
+	}
+	return nil, nil
+}
+
+func (a memAddresses) SelectByWallet(walletID int64) ([]data.Address, error) {
+	var addresses []data.Address
+	for _, addr := range a.db.state.addresses {
+		if addr.WalletID != nil && *addr.WalletID == walletID {
+			addresses = append(addresses, addr)
+		}
+	}
+	return addresses, nil
+}
+
+func (a memAddresses) MarkUsed(id int64) error {
+	for n := range a.db.state.addresses {
+		if a.db.state.addresses[n].ID == id {
+			a.db.state.addresses[n].Used = true
+		}
+	}
+	return nil
+}
+
+type memHeaders struct {
+	data.BlockHeaderdb
+	db *memDB
+}
+
+func (h memHeaders) Insert(header data.BlockHeader) error {
+	h.db.state.headers[header.Height] = header
+	return nil
+}
+
+func (h memHeaders) GetByHeight(height int64) (*data.BlockHeader, error) {
+	header, ok := h.db.state.headers[height]
+	if !ok {
+		return nil, nil
+	}
+	return &header, nil
+}
+
+func (h memHeaders) GetByHash(hash string) (*data.BlockHeader, error) {
+	for _, header := range h.db.state.headers {
+		if header.BlockHash == hash {
+			return &header, nil
+		}
+	}
+	return nil, nil
+}
+
+func (h memHeaders) GetLast() (*data.BlockHeader, error) {
+	if len(h.db.state.headers) == 0 {
+		return nil, nil
+	}
+	return h.GetByHeight(slices.Max(slices.Collect(maps.Keys(h.db.state.headers))))
+}
+
+func (h memHeaders) GetFirst() (*data.BlockHeader, error) {
+	if len(h.db.state.headers) == 0 {
+		return nil, nil
+	}
+	return h.GetByHeight(slices.Min(slices.Collect(maps.Keys(h.db.state.headers))))
+}
+
+func (h memHeaders) SelectRange(from, to int64) ([]data.BlockHeader, error) {
+	var headers []data.BlockHeader
+	for _, header := range h.db.state.headers {
+		if header.Height >= from && header.Height <= to {
+			headers = append(headers, header)
+		}
+	}
+	sort.Slice(headers, func(a, b int) bool { return headers[a].Height < headers[b].Height })
+	return headers, nil
+}
+
+func (h memHeaders) DeleteAboveHeight(height int64) error {
+	maps.DeleteFunc(h.db.state.headers, func(h int64, _ data.BlockHeader) bool { return h > height })
+	return nil
+}
+
+type memTransactions struct {
+	data.Transactiondb
+	db *memDB
+}
+
+func (t memTransactions) Insert(tx data.Transaction) error {
+	s := t.db.state
+	for _, in := range tx.Inputs {
+		in.ID = s.id()
+		s.inputs = append(s.inputs, in)
+	}
+	for _, out := range tx.Outputs {
+		out.ID = s.id()
+		s.outputs = append(s.outputs, out)
+	}
+	tx.ID = s.id()
+	tx.Inputs, tx.Outputs = nil, nil
+	s.txs = append(s.txs, tx)
+	return nil
+}
+
+func (t memTransactions) SelectByAddressID(addressID int64) ([]data.Transaction, error) {
+	var txs []data.Transaction
+	for _, tx := range t.db.state.txs {
+		if tx.AddressID != nil && *tx.AddressID == addressID {
+			txs = append(txs, t.withRows(tx))
+		}
+	}
+	return txs, nil
+}
+
+func (t memTransactions) GetByTxIDAddressID(txID string, addressID int64) (*data.Transaction, error) {
+	for _, tx := range t.db.state.txs {
+		if tx.TxID == txID && tx.AddressID != nil && *tx.AddressID == addressID {
+			tx = t.withRows(tx)
+			return &tx, nil
+		}
+	}
+	return nil, nil
+}
+
+func (t memTransactions) withRows(tx data.Transaction) data.Transaction {
+	for _, in := range t.db.state.inputs {
+		if in.TxID == tx.TxID {
+			tx.Inputs = append(tx.Inputs, in)
+		}
+	}
+	for _, out := range t.db.state.outputs {
+		if out.TxID == tx.TxID {
+			tx.Outputs = append(tx.Outputs, out)
+		}
+	}
+	return tx
+}
+
+func (t memTransactions) GetOutput(txID string, voutIdx int64) (*data.TransactionOutput, error) {
+	for _, out := range t.db.state.outputs {
+		if out.TxID == txID && int64(out.VoutIdx) == voutIdx {
+			return &out, nil
+		}
+	}
+	return nil, nil
+}
+
+func (t memTransactions) Delete(txID string, blockHeight int64) error {
+	t.db.state.txs = slices.DeleteFunc(t.db.state.txs, func(tx data.Transaction) bool {
+		return tx.TxID == txID && tx.BlockHeight == blockHeight
+	})
+	return nil
+}
+
+func (t memTransactions) DeleteInput(txID string, voutIdx int64) error {
+	t.db.state.inputs = slices.DeleteFunc(t.db.state.inputs, func(in data.TransactionInput) bool {
+		return in.TxID == txID && int64(in.VoutIdx) == voutIdx
+	})
+	return nil
+}
+
+func (t memTransactions) DeleteOutput(txID string, voutIdx int64) error {
+	t.db.state.outputs = slices.DeleteFunc(t.db.state.outputs, func(out data.TransactionOutput) bool {
+		return out.TxID == txID && int64(out.VoutIdx) == voutIdx
+	})
+	return nil
+}
+
+type memUTXOs struct {
+	data.UTXOdb
+	db *memDB
+}
+
+func (u memUTXOs) Insert(utxo data.UTXO) error {
+	utxo.ID = u.db.state.id()
+	u.db.state.utxos = append(u.db.state.utxos, utxo)
+	return nil
+}
+
+func (u memUTXOs) SelectByAddressID(addressID int64) ([]data.UTXO, error) {
+	var utxos []data.UTXO
+	for _, utxo := range u.db.state.utxos {
+		if utxo.AddressID == addressID {
+			utxos = append(utxos, utxo)
+		}
+	}
+	return utxos, nil
+}
+
+func (u memUTXOs) GetByOutpoint(txID string, vout int64) (*data.UTXO, error) {
+	if n := u.find(txID, vout); n >= 0 {
+		utxo := u.db.state.utxos[n]
+		return &utxo, nil
+	}
+	return nil, nil
+}
+
+func (u memUTXOs) MarkAsSpent(txID string, vout int64, spentTxID string, spentHeight int64) error {
+	if n := u.find(txID, vout); n >= 0 {
+		utxo := &u.db.state.utxos[n]
+		utxo.IsSpent = true
+		utxo.SpentTxID = &spentTxID
+		utxo.SpentHeight = &spentHeight
+	}
+	return nil
+}
+
+func (u memUTXOs) Unspend(txID string, vout int64) error {
+	if n := u.find(txID, vout); n >= 0 {
+		utxo := &u.db.state.utxos[n]
+		utxo.IsSpent = false
+		utxo.SpentTxID = nil
+		utxo.SpentHeight = nil
+	}
+	return nil
+}
+
+func (u memUTXOs) ClearImported(txID string, vout int64) error {
+	if n := u.find(txID, vout); n >= 0 {
+		u.db.state.utxos[n].Imported = false
+	}
+	return nil
+}
+
+func (u memUTXOs) DeleteByOutpoint(txID string, vout int64) error {
+	u.db.state.utxos = slices.DeleteFunc(u.db.state.utxos, func(utxo data.UTXO) bool {
+		return utxo.TxID == txID && utxo.Vout == vout
+	})
+	return nil
+}
+
+func (u memUTXOs) find(txID string, vout int64) int {
+	return slices.IndexFunc(u.db.state.utxos, func(utxo data.UTXO) bool {
+		return utxo.TxID == txID && utxo.Vout == vout
+	})
+}
+
+type memUndoLog struct {
+	data.UndoLogdb
+	db *memDB
+}
+
+func (u memUndoLog) Insert(action data.UndoAction) error {
+	action.ID = u.db.state.id()
+	u.db.state.undo = append(u.db.state.undo, action)
+	return nil
+}
+
+// SelectByHeight returns the actions newest first, like the pg query.
+func (u memUndoLog) SelectByHeight(height int64) ([]data.UndoAction, error) {
+	var actions []data.UndoAction
+	for _, action := range u.db.state.undo {
+		if action.BlockHeight == height {
+			actions = append(actions, action)
+		}
+	}
+	slices.Reverse(actions)
+	return actions, nil
+}
+
+func (u memUndoLog) DeleteByHeight(height int64) error {
+	u.db.state.undo = slices.DeleteFunc(u.db.state.undo, func(action data.UndoAction) bool {
+		return action.BlockHeight == height
+	})
+	return nil
+}
+
+func (u memUndoLog) DeleteBelowHeight(height int64) error {
+	u.db.state.undo = slices.DeleteFunc(u.db.state.undo, func(action data.UndoAction) bool {
+		return action.BlockHeight < height
+	})
+	return nil
+}
+
+type memMempool struct {
+	data.Mempooldb
+	db *memDB
+}
+
+func (m memMempool) DeleteByTxID(txID string) error {
+	m.db.state.mempool = slices.DeleteFunc(m.db.state.mempool, func(tx data.MempoolTransaction) bool {
+		return tx.TxID == txID
+	})
+	return nil
+}
+
+type memCursors struct {
+	data.SyncCursordb
+	db *memDB
+}
+
+func (c memCursors) Get(name string) (*data.SyncCursor, error) {
+	height, ok := c.db.state.cursors[name]
+	if !ok {
+		return nil, nil
+	}
+	return &data.SyncCursor{Name: name, Height: height}, nil
+}
+
+func (c memCursors) Set(name string, height int64) error {
+	c.db.state.cursors[name] = height
+	return nil
+}
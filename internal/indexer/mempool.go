@@ -0,0 +1,186 @@
+package indexer
+
+import (
+	"context"
+	stderrors "errors"
+	"time"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+	"gitlab.com/distributed_lab/logan/v3"
+	"gitlab.com/distributed_lab/logan/v3/errors"
+)
+
+// minMempoolSyncInterval spaces out the syncs ZMQ triggers. Every relayed
+// transaction is announced, and each sync reads the whole mempool.
+const minMempoolSyncInterval = time.Second
+
+// mempoolWatcher records unconfirmed transactions touching tracked addresses.
+// It runs in its own goroutine, so it keeps a separate DB handle and never
+// shares the indexer's one, which is switched into a transaction per block.
+type mempoolWatcher struct {
+	db     data.MasterQ
+	source bitcoin.BlockSource
+	params *bitcoin.ChainParams
+	logger *logan.Entry
+	// seen holds mempool txids that were already checked, so every tick only
+	// fetches transactions that entered the mempool since the previous one
+	seen map[string]struct{}
+}
+
+func (i *Indexer) RunMempool(ctx context.Context) {
+	if i.cfg.MempoolPollInterval <= 0 {
+		i.logger.Info("mempool watcher disabled")
+		return
+	}
+
+	w := &mempoolWatcher{
+		db:     i.db.New(),
+		source: i.source,
+		params: i.params,
+		logger: i.logger.WithField("worker", "mempool"),
+		seen:   make(map[string]struct{}),
+	}
+
+	w.logger.Info("mempool watcher started")
+	ticker := time.NewTicker(i.cfg.MempoolPollInterval)
+	defer ticker.Stop()
+
+	var (
+		lastSync time.Time
+		// delayed fires for a notification that came too soon after the
+		// last sync, nil when none is waiting
+		delayed <-chan time.Time
+	)
+	sync := func() {
+		w.sync(ctx)
+		lastSync = time.Now()
+		delayed = nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("mempool watcher stopped")
+			return
+		case <-ticker.C:
+			sync()
+		case <-delayed:
+			sync()
+		case <-i.txNotify:
+			if delayed != nil {
+				continue
+			}
+			if wait := minMempoolSyncInterval - time.Since(lastSync); wait > 0 {
+				delayed = time.After(wait)
+				continue
+			}
+			sync()
+		}
+	}
+}
+
+func (w *mempoolWatcher) sync(ctx context.Context) {
+	txids, err := w.source.GetRawMempool(ctx)
+	if err != nil {
+		w.logger.WithError(err).Error("failed to get raw mempool")
+		return
+	}
+
+	inMempool := make(map[string]struct{}, len(txids))
+	for _, txid := range txids {
+		inMempool[txid] = struct{}{}
+		if _, ok := w.seen[txid]; ok {
+			continue
+		}
+
+		tx, err := w.source.GetRawTransaction(ctx, txid)
+		if stderrors.Is(err, bitcoin.ErrTxNotFound) {
+			// mined or replaced since getrawmempool
+			w.logger.WithError(err).WithField("tx_id", txid).Debug("mempool transaction is gone")
+			continue
+		}
+		if err != nil {
+			w.logger.WithError(err).WithField("tx_id", txid).Error("failed to fetch mempool transaction")
+			return
+		}
+
+		if err := w.indexTx(*tx); err != nil {
+			w.logger.WithError(err).WithField("tx_id", txid).Error("failed to index mempool transaction")
+			continue
+		}
+		w.seen[txid] = struct{}{}
+	}
+
+	for txid := range w.seen {
+		if _, ok := inMempool[txid]; !ok {
+			delete(w.seen, txid)
+		}
+	}
+
+	pending, err := w.db.Mempool().SelectTxIDs()
+	if err != nil {
+		w.logger.WithError(err).Error("failed to select pending transactions")
+		return
+	}
+
+	for _, txid := range pending {
+		if _, ok := inMempool[txid]; ok {
+			continue
+		}
+		if err := w.db.Mempool().DeleteByTxID(txid); err != nil {
+			w.logger.WithError(err).WithField("tx_id", txid).Error("failed to evict pending transaction")
+			continue
+		}
+		w.logger.WithField("tx_id", txid).Info("pending transaction left mempool")
+	}
+}
+
+func (w *mempoolWatcher) indexTx(tx bitcoin.Transaction) error {
+	flows := newAddressFlows()
+
+	for _, in := range tx.Inputs {
+		if in.PrevTxID == "" {
+			continue
+		}
+
+		utxo, err := w.db.UTXO().GetByOutpoint(in.PrevTxID, in.Vout)
+		if err != nil {
+			return errors.Wrap(err, "failed to get spent utxo")
+		}
+		if utxo != nil && !utxo.IsSpent {
+			flows.add(utxo.AddressID, -utxo.Amount)
+		}
+	}
+
+	for _, out := range tx.Outputs {
+		address, _ := outputAddress(w.params, out)
+		if address == "" {
+			continue
+		}
+		addr, err := w.db.Address().GetByAddress(address)
+		if err == nil && addr != nil {
+			flows.add(addr.ID, out.Value)
+		}
+	}
+
+	for _, addressID := range flows.addressIDs {
+		direction, value := flows.get(addressID)
+
+		err := w.db.Mempool().Insert(data.MempoolTransaction{
+			TxID:      tx.TxID,
+			AddressID: addressID,
+			Amount:    value,
+			Direction: direction,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to insert pending transaction")
+		}
+	}
+
+	if len(flows.addressIDs) > 0 {
+		w.logger.WithField("tx_id", tx.TxID).Info("pending transaction indexed")
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package indexer
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+	"gitlab.com/distributed_lab/logan/v3/errors"
+)
+
+const zmqReconnectDelay = 5 * time.Second
+
+// runZMQ wakes the block and mempool loops up as soon as bitcoind announces a
+// block or a transaction. Polling keeps running next to it, so a dropped or
+// quiet socket only costs latency.
+func (i *Indexer) runZMQ(ctx context.Context) {
+	for {
+		err := i.listenZMQ(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		i.logger.WithError(err).Warn("zmq subscription lost, falling back to polling until reconnected")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(i.zmqReconnectDelay):
+		}
+	}
+}
+
+func (i *Indexer) listenZMQ(ctx context.Context) error {
+	sub := bitcoin.NewZMQSubscriber(i.cfg.ZMQURL, bitcoin.ZMQTopicHashBlock, bitcoin.ZMQTopicRawTx)
+	if err := sub.Connect(ctx); err != nil {
+		return errors.Wrap(err, "failed to connect to zmq publisher")
+	}
+
+	// Receive has no context, closing the socket is the only way to unblock it
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+		sub.Close()
+	}()
+
+	i.logger.WithField("endpoint", i.cfg.ZMQURL).Info("zmq subscription established")
+
+	for {
+		msg, err := sub.Receive()
+		if err != nil {
+			return errors.Wrap(err, "failed to receive zmq message")
+		}
+
+		switch msg.Topic {
+		case bitcoin.ZMQTopicHashBlock:
+			i.logger.WithField("hash", hex.EncodeToString(msg.Body)).Debug("block announced via zmq")
+			notify(i.blockNotify)
+		case bitcoin.ZMQTopicRawTx:
+			notify(i.txNotify)
+		}
+	}
+}
+
+// notify never blocks, notifications that arrive while a sync is pending are
+// merged into it.
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,225 @@
+package indexer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+	"gitlab.com/distributed_lab/logan/v3"
+)
+
+const testTimeout = 5 * time.Second
+
+// zmqPublisher is the publishing end of bitcoind's ZMQ notifications: a
+// ZMTP 3.0 PUB socket with the NULL mechanism on a local listener.
+type zmqPublisher struct {
+	ln net.Listener
+}
+
+func newZMQPublisher(t *testing.T) *zmqPublisher {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return &zmqPublisher{ln: ln}
+}
+
+func (p *zmqPublisher) url() string {
+	return "tcp://" + p.ln.Addr().String()
+}
+
+// accept takes the next subscriber, completes the handshake and checks it
+// subscribes to topics.
+func (p *zmqPublisher) accept(t *testing.T, topics ...string) net.Conn {
+	t.Helper()
+
+	p.ln.(*net.TCPListener).SetDeadline(time.Now().Add(testTimeout))
+	conn, err := p.ln.Accept()
+	if err != nil {
+		t.Fatalf("no subscriber connected: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(testTimeout))
+	r := bufio.NewReader(conn)
+
+	greeting := make([]byte, 64)
+	greeting[0] = 0xff
+	greeting[9] = 0x7f
+	greeting[10] = 3
+	copy(greeting[12:32], "NULL")
+	if _, err := conn.Write(greeting); err != nil {
+		t.Fatal(err)
+	}
+
+	peer := make([]byte, 64)
+	if _, err := io.ReadFull(r, peer); err != nil {
+		t.Fatal(err)
+	}
+	if peer[0] != 0xff || peer[9] != 0x7f || peer[10] != 3 || string(bytes.TrimRight(peer[12:32], "\x00")) != "NULL" {
+		t.Fatalf("unexpected greeting %x", peer)
+	}
+
+	flags, body := readZMQFrame(t, r)
+	if flags&0x04 == 0 || !bytes.HasPrefix(body, []byte("\x05READY")) || !bytes.HasSuffix(body, []byte("\x00\x00\x00\x03SUB")) {
+		t.Fatalf("unexpected READY from subscriber: %q", body)
+	}
+	ready := append([]byte("\x05READY\x0bSocket-Type"), 0, 0, 0, 3)
+	writeZMQFrame(t, conn, 0x04, append(ready, "PUB"...))
+
+	for _, topic := range topics {
+		if _, body := readZMQFrame(t, r); string(body) != "\x01"+topic {
+			t.Fatalf("subscription = %q, want %q", body, topic)
+		}
+	}
+
+	conn.SetDeadline(time.Time{})
+	return conn
+}
+
+// publish sends a notification the way bitcoind does: topic, body and a
+// little endian sequence number.
+func publish(t *testing.T, conn net.Conn, topic string, body []byte, seq uint32) {
+	t.Helper()
+	writeZMQFrame(t, conn, 0x01, []byte(topic))
+	writeZMQFrame(t, conn, 0x01, body)
+	writeZMQFrame(t, conn, 0, binary.LittleEndian.AppendUint32(nil, seq))
+}
+
+func writeZMQFrame(t *testing.T, w io.Writer, flags byte, body []byte) {
+	t.Helper()
+	frame := []byte{flags, byte(len(body))}
+	if len(body) > 255 {
+		frame = binary.BigEndian.AppendUint64([]byte{flags | 0x02}, uint64(len(body)))
+	}
+	if _, err := w.Write(append(frame, body...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readZMQFrame(t *testing.T, r *bufio.Reader) (byte, []byte) {
+	t.Helper()
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(r, head); err != nil {
+		t.Fatal(err)
+	}
+	if head[0]&0x02 != 0 {
+		t.Fatal("unexpected long frame from subscriber")
+	}
+	body := make([]byte, head[1])
+	if _, err := io.ReadFull(r, body); err != nil {
+		t.Fatal(err)
+	}
+	return head[0], body
+}
+
+// logLines collects what the indexer logs, one entry per write.
+type logLines chan string
+
+func (l logLines) Write(p []byte) (int, error) {
+	select {
+	case l <- string(p):
+	default:
+	}
+	return len(p), nil
+}
+
+func waitLog(t *testing.T, logs logLines, substr string) {
+	t.Helper()
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case line := <-logs:
+			if strings.Contains(line, substr) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("nothing logged with %q", substr)
+		}
+	}
+}
+
+func waitNotify(t *testing.T, ch chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(testTimeout):
+		t.Fatalf("no %s notification", what)
+	}
+}
+
+func TestZMQNotifies(t *testing.T) {
+	pub := newZMQPublisher(t)
+	logs := make(logLines, 100)
+	i := New(logan.New().Out(logs), nil, nil, Config{ZMQURL: pub.url()})
+
+	i.zmqReconnectDelay = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		i.runZMQ(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	conn := pub.accept(t, bitcoin.ZMQTopicHashBlock, bitcoin.ZMQTopicRawTx)
+	publish(t, conn, bitcoin.ZMQTopicHashBlock, bytes.Repeat([]byte{0xab}, 32), 0)
+	waitNotify(t, i.blockNotify, "block")
+	// a transaction over 255 bytes goes in a long frame
+	publish(t, conn, bitcoin.ZMQTopicRawTx, bytes.Repeat([]byte{0xcd}, 300), 0)
+	waitNotify(t, i.txNotify, "transaction")
+
+	conn.Close()
+	waitLog(t, logs, "falling back to polling")
+
+	conn = pub.accept(t, bitcoin.ZMQTopicHashBlock, bitcoin.ZMQTopicRawTx)
+	defer conn.Close()
+	publish(t, conn, bitcoin.ZMQTopicHashBlock, bytes.Repeat([]byte{0xef}, 32), 1)
+	waitNotify(t, i.blockNotify, "block after reconnecting")
+}
+
+func TestRunPollsWhileZMQIsDown(t *testing.T) {
+	_, blocks := newTestChain(t)
+	chain := bitcoin.NewFakeChain()
+	chain.SetBlock(blocks[0])
+
+	pub := newZMQPublisher(t)
+	logs := make(logLines, 100)
+	i, db := newTestIndexer(t, chain, Config{
+		ZMQURL:       pub.url(),
+		PollInterval: 10 * time.Millisecond,
+	})
+	i.logger = logan.New().Out(logs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		i.Run(ctx)
+	}()
+	defer cancel()
+
+	// the publisher goes away for good
+	pub.accept(t, bitcoin.ZMQTopicHashBlock, bitcoin.ZMQTopicRawTx).Close()
+	pub.ln.Close()
+	waitLog(t, logs, "falling back to polling")
+
+	for _, block := range blocks[1:] {
+		chain.SetBlock(block)
+	}
+	waitLog(t, logs, `msg="block indexed" height=3`)
+
+	cancel()
+	<-done
+	assertCursor(t, db, 3)
+}
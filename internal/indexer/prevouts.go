@@ -0,0 +1,108 @@
+package indexer
+
+import (
+	"context"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/amount"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+)
+
+type prevout struct {
+	address string
+	amount  amount.Amount
+}
+
+// resolvePrevouts finds the output spent by every input of txs, keyed by
+// outpoint. Local tables are tried first, then outputs created earlier in the
+// same block, the prevouts of a verbosity 3 block and finally the node's
+// getrawtransaction. Inputs that can't be resolved are left out.
+func (i *Indexer) resolvePrevouts(ctx context.Context, txs []bitcoin.Transaction, block []bitcoin.Transaction) map[string]prevout {
+	blockOutputs := make(map[string]bitcoin.TxOutput)
+	for _, tx := range block {
+		for _, out := range tx.Outputs {
+			blockOutputs[outpointKey(tx.TxID, out.Vout)] = out
+		}
+	}
+
+	rawTxs := make(map[string]*bitcoin.Transaction)
+	resolved := make(map[string]prevout)
+	for _, tx := range txs {
+		for _, in := range tx.Inputs {
+			if in.PrevTxID == "" {
+				continue
+			}
+
+			key := outpointKey(in.PrevTxID, in.Vout)
+			if _, ok := resolved[key]; ok {
+				continue
+			}
+
+			p, ok := i.resolvePrevout(ctx, in, blockOutputs, rawTxs)
+			if !ok {
+				i.logger.WithFields(map[string]interface{}{
+					"tx_id":   tx.TxID,
+					"prevout": key,
+				}).Warn("failed to resolve input prevout")
+				continue
+			}
+			resolved[key] = p
+		}
+	}
+
+	return resolved
+}
+
+func (i *Indexer) resolvePrevout(ctx context.Context, in bitcoin.TxInput, blockOutputs map[string]bitcoin.TxOutput, rawTxs map[string]*bitcoin.Transaction) (prevout, bool) {
+	utxo, err := i.db.UTXO().GetByOutpoint(in.PrevTxID, in.Vout)
+	if err == nil && utxo != nil {
+		addr, err := i.db.Address().GetByID(utxo.AddressID)
+		if err == nil && addr != nil {
+			return prevout{address: addr.Address, amount: utxo.Amount}, true
+		}
+	}
+
+	dbOutput, err := i.db.Transaction().GetOutput(in.PrevTxID, in.Vout)
+	if err == nil && dbOutput != nil {
+		return prevout{address: dbOutput.Address, amount: dbOutput.Amount}, true
+	}
+
+	if out, ok := blockOutputs[outpointKey(in.PrevTxID, in.Vout)]; ok {
+		return i.prevoutFromOutput(out), true
+	}
+
+	if in.Prevout != nil {
+		return i.prevoutFromOutput(bitcoin.TxOutput{
+			Value:        in.Prevout.Value,
+			Vout:         in.Vout,
+			ScriptPubKey: in.Prevout.ScriptPubKey,
+		}), true
+	}
+
+	rawTx, ok := rawTxs[in.PrevTxID]
+	if !ok {
+		rawTx, err = i.source.GetRawTransaction(ctx, in.PrevTxID)
+		if err != nil {
+			i.logger.WithError(err).WithField("tx_id", in.PrevTxID).Debug("failed to fetch raw transaction")
+		}
+		rawTxs[in.PrevTxID] = rawTx
+	}
+	if rawTx == nil {
+		return prevout{}, false
+	}
+
+	for _, out := range rawTx.Outputs {
+		if out.Vout == in.Vout {
+			return i.prevoutFromOutput(out), true
+		}
+	}
+
+	return prevout{}, false
+}
+
+func (i *Indexer) prevoutFromOutput(out bitcoin.TxOutput) prevout {
+	address, _ := outputAddress(i.params, out)
+	return prevout{
+		address: address,
+		amount:  out.Value,
+	}
+}
@@ -1,77 +1,181 @@
 package indexer
 
-func (i *Indexer) RollbackBlock(height int64) {
-	actions := i.undoLog.Get(height)
+import (
+	"context"
+	stderrors "errors"
 
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+	"gitlab.com/distributed_lab/logan/v3"
+	"gitlab.com/distributed_lab/logan/v3/errors"
+)
+
+const defaultMaxReorgDepth = 6
+
+// ErrReorgTooDeep is returned by FindCommonAncestor when the node's chain
+// shares no block with the stored one within the max reorg depth.
+var ErrReorgTooDeep = errors.New("no common ancestor within the max reorg depth")
+
+// RollbackBlock reverses everything the block at height did to the database,
+// replaying its undo log newest first, and moves the block cursor below it.
+// The header itself is left to HandleReorg.
+func (i *Indexer) RollbackBlock(height int64) error {
+	var actions []data.UndoAction
 	err := i.db.NewTransaction(func() error {
+		var err error
+		actions, err = i.db.UndoLog().SelectByHeight(height)
+		if err != nil {
+			return errors.Wrap(err, "failed to select undo actions")
+		}
+
 		for _, a := range actions {
-			switch a.Action {
-			case "create_utxo":
-				_ = i.db.UTXO().DeleteAboveHeight(height - 1)
-			case "spend_utxo":
-				_ = i.db.UTXO().UnspendByHeight(height - 1)
+			if err := i.revertUndo(a); err != nil {
+				return errors.Wrap(err, "failed to revert undo action", logan.F{
+					"action": a.Action,
+					"tx_id":  a.TxID,
+					"vout":   a.Vout,
+				})
 			}
 		}
 
-		if err := i.db.BlockHeader().DeleteAboveHeight(height - 1); err != nil {
-			return err
+		if err := i.db.SyncCursor().Set(data.CursorBlocks, height-1); err != nil {
+			return errors.Wrap(err, "failed to move block cursor")
 		}
 
-		return nil
+		return i.db.UndoLog().DeleteByHeight(height)
 	})
-
 	if err != nil {
-		i.logger.WithError(err).Error("failed to rollback block header")
-		return
+		return errors.Wrap(err, "failed to rollback block", logan.F{"height": height})
 	}
 
-	i.undoLog.Remove(height)
-	i.logger.WithField("height", height).Info("rolled back block and removed header")
+	i.logger.WithFields(map[string]interface{}{
+		"height":  height,
+		"actions": len(actions),
+	}).Info("rolled back block")
+	return nil
 }
 
-func (i *Indexer) HandleReorg(newTipHeight int64) {
+func (i *Indexer) HandleReorg(ctx context.Context, newTipHeight int64) {
 	i.logger.WithField("new_tip", newTipHeight).Info("reorganization detected, searching for common ancestor")
 
-	commonAncestor := i.FindCommonAncestor(newTipHeight)
+	commonAncestor, err := i.FindCommonAncestor(ctx, newTipHeight)
+	if errors.Cause(err) == ErrReorgTooDeep {
+		tip, tipErr := i.db.BlockHeader().GetLast()
+		if tipErr != nil || tip == nil {
+			i.logger.WithError(err).Error("failed to find common ancestor, reorg postponed")
+			return
+		}
+		i.halt(&ValidationError{Height: tip.Height, Hash: tip.BlockHash, Err: err})
+		return
+	}
+	if err != nil {
+		i.logger.WithError(err).Error("failed to find common ancestor, reorg postponed")
+		return
+	}
 	currentTip := i.CurrentTip()
 
+	if cp := bitcoin.LastCheckpoint(i.checkpoints, currentTip); cp != nil && commonAncestor < cp.Height {
+		i.halt(&ValidationError{Height: cp.Height, Hash: cp.Hash, Err: errors.From(
+			errors.New("refusing to reorg below the last checkpoint"),
+			logan.F{"common_ancestor": commonAncestor},
+		)})
+		return
+	}
+
 	i.logger.WithFields(map[string]interface{}{
 		"common_ancestor": commonAncestor,
 		"current_tip":     currentTip,
 	}).Info("starting rollback process")
 
-	for h := currentTip; h > commonAncestor; h-- {
-		i.RollbackBlock(h)
+	cursor, err := i.db.SyncCursor().Get(data.CursorBlocks)
+	if err != nil {
+		i.logger.WithError(err).Error("failed to get block cursor")
+		return
 	}
+
+	if cursor != nil {
+		// Blocks below the start height were never indexed, so the cursor
+		// does not need to go further back than just below it.
+		for h := cursor.Height; h > commonAncestor && h >= int64(i.cfg.StartHeight); h-- {
+			if err := i.RollbackBlock(h); err != nil {
+				i.logger.WithError(err).Error("reorg rollback aborted")
+				return
+			}
+		}
+	}
+
+	// rescans already saw blocks of the old branch, scan the new one again
+	if err := i.db.RescanJob().Rewind(commonAncestor); err != nil {
+		i.logger.WithError(err).Error("failed to rewind rescan jobs")
+		return
+	}
+
+	if err := i.db.BlockHeader().DeleteAboveHeight(commonAncestor); err != nil {
+		i.logger.WithError(err).Error("failed to delete reorged headers")
+		return
+	}
+
+	i.logger.WithField("height", commonAncestor).Info("header chain rolled back to common ancestor")
 }
 
-func (i *Indexer) FindCommonAncestor(newHeight int64) int64 {
-	for h := newHeight - 1; h > 0; h-- {
-		dbBlock, err := i.db.BlockHeader().GetByHeight(h)
+// FindCommonAncestor walks back from newHeight to the highest stored header
+// the node has the same block for, comparing a batch of heights per request.
+// Heights the node has no block at are stepped over; any other error ends
+// the search, so a node that is down does not look like a deep reorg. Past
+// the max reorg depth it gives up with ErrReorgTooDeep.
+func (i *Indexer) FindCommonAncestor(ctx context.Context, newHeight int64) (int64, error) {
+	lowest := max(newHeight-int64(i.maxReorgDepth())-1, 1)
+	size := int64(bitcoin.BatchSize(i.source))
+
+	for top := newHeight - 1; top >= lowest; top -= size {
+		bottom := max(top-size+1, lowest)
+
+		stored, err := i.db.BlockHeader().SelectRange(bottom, top)
 		if err != nil {
-			i.logger.WithError(err).WithField("height", h).Error("failed to get block from DB during reorg")
-			continue
+			return 0, errors.Wrap(err, "failed to get blocks from DB", logan.F{"from": bottom, "to": top})
+		}
+		dbHashes := make(map[int64]string, len(stored))
+		for _, header := range stored {
+			dbHashes[header.Height] = header.BlockHash
 		}
 
-		var rpcHash string
-		err = i.rpcClient.Call("getblockhash", []interface{}{h}, &rpcHash)
+		heights := make([]int64, 0, top-bottom+1)
+		for h := top; h >= bottom; h-- {
+			heights = append(heights, h)
+		}
+		rpcHashes, errs, err := bitcoin.GetBlockHashes(ctx, i.source, heights)
 		if err != nil {
-			i.logger.WithError(err).WithField("height", h).Error("failed to get block hash from RPC")
-			continue
+			return 0, errors.Wrap(err, "failed to get block hashes from node", logan.F{"from": bottom, "to": top})
 		}
 
-		if dbBlock != nil && dbBlock.BlockHash == rpcHash {
-			i.logger.WithFields(map[string]interface{}{
-				"height": h,
-				"hash":   rpcHash,
-			}).Debug("common ancestor found")
-			return h
+		for n, h := range heights {
+			switch {
+			case stderrors.Is(errs[n], bitcoin.ErrBlockNotFound):
+				i.logger.WithField("height", h).Debug("node has no block at height")
+			case errs[n] != nil:
+				return 0, errors.Wrap(errs[n], "failed to get block hash from node", logan.F{"height": h})
+			case dbHashes[h] != "" && dbHashes[h] == rpcHashes[n]:
+				i.logger.WithFields(map[string]interface{}{
+					"height": h,
+					"hash":   rpcHashes[n],
+				}).Debug("common ancestor found")
+				return h, nil
+			}
 		}
+	}
 
-		if newHeight-h > int64(i.cfg.MaxReorgDepth) {
-			i.logger.Warn("max reorg depth reached, could not find common ancestor")
-			break
-		}
+	if lowest > 1 {
+		return 0, errors.From(ErrReorgTooDeep, logan.F{
+			"new_height":      newHeight,
+			"max_reorg_depth": i.maxReorgDepth(),
+		})
+	}
+	return 0, nil
+}
+
+func (i *Indexer) maxReorgDepth() int {
+	if i.cfg.MaxReorgDepth < 1 {
+		return defaultMaxReorgDepth
 	}
-	return 0
+	return i.cfg.MaxReorgDepth
 }
@@ -0,0 +1,271 @@
+package indexer
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/amount"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+	"gitlab.com/distributed_lab/logan/v3"
+	"gitlab.com/distributed_lab/logan/v3/errors"
+)
+
+const (
+	alice = "bcrt1qalice"
+	bob   = "bcrt1qbob"
+)
+
+// regtestGenesis is the regtest genesis block, the checkpoint header sync
+// starts from.
+var regtestGenesis = bitcoin.FakeBlock{
+	Header: bitcoin.BlockHeader{
+		BlockHash:  "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206",
+		Version:    1,
+		MerkleRoot: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+		Timestamp:  1296688602,
+		Bits:       "207fffff",
+		Nonce:      2,
+	},
+	Txs: []bitcoin.Transaction{{TxID: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"}},
+}
+
+// testTxID makes a txid out of a name, so tests can refer to transactions by
+// what they are.
+func testTxID(name string) string {
+	return bitcoin.HashToHex(sha256.Sum256([]byte(name)))
+}
+
+func coinbase(name string, outputs ...bitcoin.TxOutput) bitcoin.Transaction {
+	return bitcoin.Transaction{
+		TxID:    testTxID(name),
+		Inputs:  []bitcoin.TxInput{{Coinbase: "00"}},
+		Outputs: outputs,
+	}
+}
+
+func pay(address string, vout int64, btc int64) bitcoin.TxOutput {
+	return bitcoin.TxOutput{Value: amount.Amount(btc * amount.SatoshiPerBitcoin), Vout: vout, Address: address}
+}
+
+// mineBlock builds a valid regtest block on top of prev.
+func mineBlock(t *testing.T, prev bitcoin.BlockHeader, txs ...bitcoin.Transaction) bitcoin.FakeBlock {
+	t.Helper()
+
+	txids := make([][32]byte, len(txs))
+	for n, tx := range txs {
+		var err error
+		if txids[n], err = bitcoin.HashFromHex(tx.TxID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// only the root of the partial tree is used, the header is a stand-in
+	raw, err := bitcoin.BuildMerkleBlock(make([]byte, 80), txids, txids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb, err := bitcoin.ParseMerkleBlock(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _, err := mb.ExtractMatches()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := bitcoin.BlockHeader{
+		Version:        0x20000000,
+		PreviousHash:   prev.BlockHash,
+		MerkleRoot:     bitcoin.HashToHex(root),
+		Timestamp:      prev.Timestamp + 600,
+		Bits:           prev.Bits,
+		Height:         prev.Height + 1,
+		TransactionNum: int64(len(txs)),
+	}
+	bits, _ := bitcoin.ParseBits(header.Bits)
+	for ; ; header.Nonce++ {
+		raw, err := bitcoin.SerializeHeader(&header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash := bitcoin.DoubleSHA256(raw)
+		if bitcoin.RegTestParams.CheckProofOfWork(hash, bits) == nil {
+			header.BlockHash = bitcoin.HashToHex(hash)
+			break
+		}
+	}
+
+	return bitcoin.FakeBlock{Header: header, Txs: txs}
+}
+
+// newTestChain returns a fake regtest chain of three blocks: block 1 pays
+// alice 50, block 2 spends it to 30 for alice and 19 for bob, block 3 pays
+// bob.
+func newTestChain(t *testing.T) (*bitcoin.FakeChain, []bitcoin.FakeBlock) {
+	t.Helper()
+
+	b1 := mineBlock(t, regtestGenesis.Header, coinbase("coinbase 1", pay(alice, 0, 50)))
+	b2 := mineBlock(t, b1.Header, coinbase("coinbase 2", pay(bob, 0, 50)), bitcoin.Transaction{
+		TxID:    testTxID("alice pays bob"),
+		Inputs:  []bitcoin.TxInput{{PrevTxID: testTxID("coinbase 1"), Vout: 0}},
+		Outputs: []bitcoin.TxOutput{pay(alice, 0, 30), pay(bob, 1, 19)},
+	})
+	b3 := mineBlock(t, b2.Header, coinbase("coinbase 3", pay(bob, 0, 50)))
+
+	blocks := []bitcoin.FakeBlock{regtestGenesis, b1, b2, b3}
+	chain := bitcoin.NewFakeChain()
+	for _, block := range blocks {
+		chain.SetBlock(block)
+	}
+	return chain, blocks
+}
+
+// newTestIndexer returns an indexer over source with alice tracked, on
+// regtest unless cfg sets other params.
+func newTestIndexer(t *testing.T, source bitcoin.BlockSource, cfg Config) (*Indexer, *memDB) {
+	t.Helper()
+
+	db := newMemDB()
+	if err := db.Address().Insert(data.Address{Address: alice, Status: data.AddressStatusActive}); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Params == nil {
+		cfg.Params = &bitcoin.RegTestParams
+	}
+	return New(logan.New().Out(io.Discard), db, source, cfg), db
+}
+
+func TestReorgRollsBackIndexedBlocks(t *testing.T) {
+	chain, blocks := newTestChain(t)
+	i, db := newTestIndexer(t, chain, Config{})
+	i.sync(context.Background())
+	assertCursor(t, db, 3)
+	addr, _ := db.Address().GetByAddress(alice)
+
+	assertUTXOs(t, db, addr.ID, map[string]bool{
+		testTxID("coinbase 1"):     true,
+		testTxID("alice pays bob"): false,
+	})
+	assertHistory(t, db, addr.ID, testTxID("coinbase 1"), testTxID("alice pays bob"))
+
+	// the node drops blocks 2 and 3 for a branch that pays alice 5
+	fork2 := mineBlock(t, blocks[1].Header, coinbase("coinbase 2b", pay(alice, 0, 5)))
+	fork3 := mineBlock(t, fork2.Header, coinbase("coinbase 3b", pay(bob, 0, 50)))
+	chain.SetBlock(fork2)
+	chain.SetBlock(fork3)
+
+	i.sync(context.Background())
+	if i.halted != nil {
+		t.Fatalf("indexer halted: %v", i.halted)
+	}
+
+	assertCursor(t, db, 1)
+	if tip := i.CurrentTip(); tip != 1 {
+		t.Errorf("header tip = %d, want the common ancestor 1", tip)
+	}
+	assertUTXOs(t, db, addr.ID, map[string]bool{testTxID("coinbase 1"): false})
+	utxo, _ := db.UTXO().GetByOutpoint(testTxID("coinbase 1"), 0)
+	if utxo.SpentTxID != nil || utxo.SpentHeight != nil {
+		t.Errorf("rolled back utxo still records its spend: %+v", utxo)
+	}
+	assertHistory(t, db, addr.ID, testTxID("coinbase 1"))
+	if out, _ := db.Transaction().GetOutput(testTxID("alice pays bob"), 0); out != nil {
+		t.Errorf("outputs of a rolled back transaction are left: %+v", out)
+	}
+	for _, height := range []int64{2, 3} {
+		if actions, _ := db.UndoLog().SelectByHeight(height); len(actions) != 0 {
+			t.Errorf("undo log of rolled back block %d is left: %+v", height, actions)
+		}
+	}
+
+	// the next sync indexes the new branch
+	i.sync(context.Background())
+
+	assertCursor(t, db, 3)
+	assertUTXOs(t, db, addr.ID, map[string]bool{
+		testTxID("coinbase 1"):  false,
+		testTxID("coinbase 2b"): false,
+	})
+	assertHistory(t, db, addr.ID, testTxID("coinbase 1"), testTxID("coinbase 2b"))
+	tx, _ := db.Transaction().GetByTxIDAddressID(testTxID("coinbase 2b"), addr.ID)
+	if tx.BlockHash != fork2.Header.BlockHash {
+		t.Errorf("transaction recorded in block %s, want %s", tx.BlockHash, fork2.Header.BlockHash)
+	}
+}
+
+func TestReorgPastMaxDepthHalts(t *testing.T) {
+	chain, blocks := newTestChain(t)
+	i, db := newTestIndexer(t, chain, Config{MaxReorgDepth: 1})
+	i.sync(context.Background())
+	assertCursor(t, db, 3)
+	addr, _ := db.Address().GetByAddress(alice)
+
+	fork2 := mineBlock(t, blocks[1].Header, coinbase("coinbase 2b", pay(bob, 0, 50)))
+	chain.SetBlock(fork2)
+	chain.SetBlock(mineBlock(t, fork2.Header, coinbase("coinbase 3b", pay(bob, 0, 50))))
+
+	i.sync(context.Background())
+
+	if i.halted == nil || errors.Cause(i.halted.Err) != ErrReorgTooDeep {
+		t.Fatalf("halted = %v, want ErrReorgTooDeep", i.halted)
+	}
+	assertCursor(t, db, 3)
+	assertUTXOs(t, db, addr.ID, map[string]bool{
+		testTxID("coinbase 1"):     true,
+		testTxID("alice pays bob"): false,
+	})
+}
+
+func assertCursor(t *testing.T, db data.MasterQ, want int64) {
+	t.Helper()
+	cursor, err := db.SyncCursor().Get(data.CursorBlocks)
+	if err != nil || cursor == nil {
+		t.Fatalf("block cursor = %v, %v", cursor, err)
+	}
+	if cursor.Height != want {
+		t.Fatalf("block cursor at %d, want %d", cursor.Height, want)
+	}
+}
+
+// assertUTXOs checks the UTXOs of an address by txid, with whether they are
+// spent.
+func assertUTXOs(t *testing.T, db data.MasterQ, addressID int64, want map[string]bool) {
+	t.Helper()
+	utxos, err := db.UTXO().SelectByAddressID(addressID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool, len(utxos))
+	for _, utxo := range utxos {
+		got[utxo.TxID] = utxo.IsSpent
+	}
+	if len(got) != len(want) {
+		t.Errorf("utxos = %v, want %v", got, want)
+		return
+	}
+	for txid, spent := range want {
+		if isSpent, ok := got[txid]; !ok || isSpent != spent {
+			t.Errorf("utxos = %v, want %v", got, want)
+			return
+		}
+	}
+}
+
+func assertHistory(t *testing.T, db data.MasterQ, addressID int64, want ...string) {
+	t.Helper()
+	txs, err := db.Transaction().SelectByAddressID(addressID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tx := range txs {
+		got = append(got, tx.TxID)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("history = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,284 @@
+package indexer
+
+import (
+	"context"
+	"time"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+	"gitlab.com/distributed_lab/logan/v3"
+	"gitlab.com/distributed_lab/logan/v3/errors"
+)
+
+// rescanHandoverGap is how close to the block cursor a rescan gets in the
+// background before the indexer scans the remaining blocks itself. Near the
+// cursor live sync and the rescan would otherwise race on the same UTXOs.
+const rescanHandoverGap = 6
+
+var errRescanNoHeaders = errors.New("blocks to rescan are below the stored header chain")
+
+// RunRescans works through rescan jobs in the background, one at a time,
+// scanning old blocks for the history of addresses added after their first
+// activity.
+func (i *Indexer) RunRescans(ctx context.Context) {
+	w := i.worker(i.logger.WithField("worker", "rescan"))
+
+	w.logger.Info("rescan worker started")
+	ticker := time.NewTicker(i.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("rescan worker stopped")
+			return
+		case <-ticker.C:
+			w.runRescans(ctx)
+		}
+	}
+}
+
+func (i *Indexer) runRescans(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := i.db.RescanJob().GetNext()
+		if err != nil {
+			i.logger.WithError(err).Error("failed to get next rescan job")
+			return
+		}
+		if job == nil {
+			return
+		}
+
+		entry := i.logger.WithFields(jobFields(*job))
+
+		err = i.rescan(ctx, job)
+		if err == errRescanNoHeaders {
+			entry.WithError(err).Error("rescan job failed")
+			msg := err.Error()
+			job.Status = data.RescanStatusFailed
+			job.Error = &msg
+			err = i.db.NewTransaction(func() error {
+				if err := i.db.RescanJob().Update(*job); err != nil {
+					return err
+				}
+				return i.setJobStatus(*job, data.AddressStatusFailed)
+			})
+			if err != nil {
+				entry.WithError(err).Error("failed to update rescan job")
+			}
+			continue
+		}
+		if err != nil {
+			// most likely the node, retried on the next tick
+			entry.WithError(err).Error("rescan interrupted")
+			return
+		}
+	}
+}
+
+// rescan scans blocks for job until it is close enough to the block cursor to
+// be handed over to the indexer.
+func (i *Indexer) rescan(ctx context.Context, job *data.RescanJob) error {
+	if job.Status == data.RescanStatusPending {
+		job.Status = data.RescanStatusRunning
+		if err := i.db.RescanJob().Update(*job); err != nil {
+			return errors.Wrap(err, "failed to start rescan job")
+		}
+		i.logger.WithFields(jobFields(*job)).WithField("from", job.FromHeight).Info("rescan started")
+	}
+
+	scoped := i.forJob(*job)
+
+	for ctx.Err() == nil {
+		next, err := i.nextBlockHeight()
+		if err != nil {
+			return errors.Wrap(err, "failed to get block cursor")
+		}
+		cursor := next - 1
+
+		if cursor-job.CurrentHeight <= rescanHandoverGap {
+			job.Status = data.RescanStatusFinishing
+			if err := i.db.RescanJob().Update(*job); err != nil {
+				return errors.Wrap(err, "failed to hand over rescan job")
+			}
+			return nil
+		}
+
+		to := job.CurrentHeight + catchUpBatch
+		if to > cursor {
+			to = cursor
+		}
+		job.ToHeight = &cursor
+
+		if err := scoped.rescanRange(ctx, job, to); err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
+
+// finishRescans scans the last blocks of handed over jobs up to the block
+// cursor. It runs between live sync steps, so nothing indexes blocks
+// concurrently, and marks the addresses as synced.
+func (i *Indexer) finishRescans(ctx context.Context) {
+	jobs, err := i.db.RescanJob().SelectByStatus(data.RescanStatusFinishing)
+	if err != nil {
+		i.logger.WithError(err).Error("failed to select rescan jobs")
+		return
+	}
+
+	for _, job := range jobs {
+		entry := i.logger.WithFields(jobFields(job))
+
+		next, err := i.nextBlockHeight()
+		if err != nil {
+			entry.WithError(err).Error("failed to get block cursor")
+			return
+		}
+		cursor := next - 1
+
+		scoped := i.forJob(job)
+		for err == nil && job.CurrentHeight < cursor {
+			err = scoped.rescanRange(ctx, &job, cursor)
+		}
+		if err != nil {
+			entry.WithError(err).Error("failed to finish rescan")
+			continue
+		}
+
+		job.Status = data.RescanStatusDone
+		job.ToHeight = &job.CurrentHeight
+		err = i.db.NewTransaction(func() error {
+			if err := i.db.RescanJob().Update(job); err != nil {
+				return errors.Wrap(err, "failed to complete rescan job")
+			}
+			return i.setJobStatus(job, data.AddressStatusActive)
+		})
+		if err != nil {
+			entry.WithError(err).Error("failed to complete rescan")
+			continue
+		}
+
+		entry.WithField("height", job.CurrentHeight).Info("rescan finished")
+	}
+}
+
+// rescanRange scans the blocks after job.CurrentHeight up to to, saving the
+// progress of the job together with every block. It stops early, with the
+// job short of to, when a wallet derived new addresses that the block
+// filters have to be matched against.
+func (i *Indexer) rescanRange(ctx context.Context, job *data.RescanJob, to int64) error {
+	if to <= job.CurrentHeight {
+		return nil
+	}
+
+	i.walletExtended = false
+	scripts, err := i.scopedScripts()
+	if err != nil {
+		return err
+	}
+
+	headers, err := i.db.BlockHeader().SelectRange(job.CurrentHeight+1, to)
+	if err != nil {
+		return errors.Wrap(err, "failed to get block headers")
+	}
+	if int64(len(headers)) != to-job.CurrentHeight {
+		return errRescanNoHeaders
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	advance := func(height int64) error {
+		job.CurrentHeight = height
+		return i.db.RescanJob().Update(*job)
+	}
+
+	for fetch := range i.fetchBlocks(ctx, headers, scripts) {
+		var block fetchedBlock
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case block = <-fetch.result:
+		}
+
+		if block.err != nil {
+			return errors.Wrap(block.err, "failed to fetch block", logan.F{"height": block.height})
+		}
+
+		if err := i.processBlock(ctx, block.header, block.txs, advance); err != nil {
+			return errors.Wrap(err, "failed to rescan block", logan.F{"height": block.height})
+		}
+
+		if scripts != nil && i.walletExtended {
+			return nil
+		}
+	}
+
+	return ctx.Err()
+}
+
+// setJobStatus sets the status of the address or wallet addresses of job.
+func (i *Indexer) setJobStatus(job data.RescanJob, status string) error {
+	if job.WalletID != nil {
+		return i.db.Address().SetWalletStatus(*job.WalletID, status)
+	}
+	return i.db.Address().SetStatus(*job.AddressID, status)
+}
+
+// forJob returns a copy of the indexer that only indexes the address or
+// wallet of job.
+func (i *Indexer) forJob(job data.RescanJob) *Indexer {
+	scoped := *i
+	if job.WalletID != nil {
+		scoped.scope.walletID = *job.WalletID
+	} else if job.AddressID != nil {
+		scoped.scope.addressID = *job.AddressID
+	}
+	return &scoped
+}
+
+// scopedScripts returns the scripts of the addresses in scope to match block
+// filters against, nil to download every block.
+func (i *Indexer) scopedScripts() ([][]byte, error) {
+	if i.filters == nil {
+		return nil, nil
+	}
+
+	var addresses []data.Address
+	if i.scope.walletID != 0 {
+		var err error
+		if addresses, err = i.db.Address().SelectByWallet(i.scope.walletID); err != nil {
+			return nil, errors.Wrap(err, "failed to select wallet addresses")
+		}
+	} else {
+		addr, err := i.db.Address().GetByID(i.scope.addressID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get address")
+		}
+		addresses = []data.Address{*addr}
+	}
+
+	scripts := make([][]byte, 0, len(addresses))
+	for _, addr := range addresses {
+		script, err := bitcoin.AddressToScript(addr.Address)
+		if err != nil {
+			// cannot be matched against filters, download every block
+			return nil, nil
+		}
+		scripts = append(scripts, script)
+	}
+	return scripts, nil
+}
+
+func jobFields(job data.RescanJob) logan.F {
+	fields := logan.F{"job_id": job.ID}
+	if job.AddressID != nil {
+		fields["address_id"] = *job.AddressID
+	}
+	if job.WalletID != nil {
+		fields["wallet_id"] = *job.WalletID
+	}
+	return fields
+}
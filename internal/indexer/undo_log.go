@@ -1,41 +1,55 @@
 package indexer
 
 import (
-	"sync"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+	"gitlab.com/distributed_lab/logan/v3"
+	"gitlab.com/distributed_lab/logan/v3/errors"
 )
 
-type UndoAction struct {
-	BlockHeight int64
-	Action      string
-	TxID        string
-	Vout        int64
+// recordUndo stores the reverse of a single mutation made while indexing a
+// block. It must be called inside the same DB transaction as the mutation.
+func (i *Indexer) recordUndo(blockHeight int64, action, txID string, vout int64) error {
+	return i.db.UndoLog().Insert(data.UndoAction{
+		BlockHeight: blockHeight,
+		Action:      action,
+		TxID:        txID,
+		Vout:        vout,
+	})
 }
 
-type UndoLog struct {
-	mu   sync.Mutex
-	logs map[int64][]UndoAction
-}
-
-func NewUndoLog() *UndoLog {
-	return &UndoLog{
-		logs: make(map[int64][]UndoAction),
+// pruneUndoLog drops the undo actions of blocks that no reorg can reach
+// anymore once the block cursor is at height: those deeper than MaxReorgDepth
+// and those below the last checkpoint, whichever reaches higher.
+func (i *Indexer) pruneUndoLog(height int64) error {
+	below := height - int64(i.maxReorgDepth())
+	if cp := bitcoin.LastCheckpoint(i.checkpoints, height); cp != nil && cp.Height > below {
+		below = cp.Height
+	}
+	if below <= 0 {
+		return nil
 	}
-}
-
-func (u *UndoLog) Add(action UndoAction) {
-	u.mu.Lock()
-	defer u.mu.Unlock()
-	u.logs[action.BlockHeight] = append(u.logs[action.BlockHeight], action)
-}
 
-func (u *UndoLog) Get(blockHeight int64) []UndoAction {
-	u.mu.Lock()
-	defer u.mu.Unlock()
-	return u.logs[blockHeight]
+	if err := i.db.UndoLog().DeleteBelowHeight(below); err != nil {
+		return errors.Wrap(err, "failed to prune undo log", logan.F{"below": below})
+	}
+	return nil
 }
 
-func (u *UndoLog) Remove(blockHeight int64) {
-	u.mu.Lock()
-	defer u.mu.Unlock()
-	delete(u.logs, blockHeight)
+// revertUndo reverses exactly one recorded mutation.
+func (i *Indexer) revertUndo(action data.UndoAction) error {
+	switch action.Action {
+	case data.UndoCreateUTXO:
+		return i.db.UTXO().DeleteByOutpoint(action.TxID, action.Vout)
+	case data.UndoSpendUTXO:
+		return i.db.UTXO().Unspend(action.TxID, action.Vout)
+	case data.UndoInsertTransaction:
+		return i.db.Transaction().Delete(action.TxID, action.BlockHeight)
+	case data.UndoInsertTxInput:
+		return i.db.Transaction().DeleteInput(action.TxID, action.Vout)
+	case data.UndoInsertTxOutput:
+		return i.db.Transaction().DeleteOutput(action.TxID, action.Vout)
+	default:
+		return errors.From(errors.New("unknown undo action"), logan.F{"action": action.Action})
+	}
 }
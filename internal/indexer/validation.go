@@ -0,0 +1,207 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+	"gitlab.com/distributed_lab/logan/v3"
+	"gitlab.com/distributed_lab/logan/v3/errors"
+)
+
+// ValidationError is returned for a block whose header breaks consensus rules.
+// The source cannot be trusted past that point, so the indexer halts on it
+// instead of retrying.
+type ValidationError struct {
+	Height int64
+	Hash   string
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("block %s at height %d failed header validation: %s", e.Hash, e.Height, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// validateHeader checks header against the stored chain and returns the
+// cumulative chainwork up to and including it.
+func (i *Indexer) validateHeader(header *bitcoin.BlockHeader) (string, error) {
+	tip, err := i.db.BlockHeader().GetLast()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get tip")
+	}
+
+	if cp := i.checkpointAt(header.Height); cp != nil && cp.Hash != header.BlockHash {
+		return "", &ValidationError{Height: header.Height, Hash: header.BlockHash, Err: bitcoin.ErrCheckpointMismatch}
+	}
+
+	if tip == nil && i.checkpointAt(header.Height) == nil {
+		// The header chain has to start at a checkpoint, otherwise nothing
+		// ties it to the real chain.
+		return "", &ValidationError{Height: header.Height, Hash: header.BlockHash, Err: errors.New("first header is not a checkpoint")}
+	}
+
+	ctx := bitcoin.HeaderContext{Now: time.Now()}
+	if tip != nil {
+		ctx.Prev = fromDataHeader(*tip)
+
+		recent, err := i.db.BlockHeader().SelectRange(tip.Height-bitcoin.MedianTimeBlocks+1, tip.Height)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to get recent headers")
+		}
+		for _, h := range recent {
+			ctx.Timestamps = append(ctx.Timestamps, h.Timestamp.Unix())
+		}
+
+		interval := i.params.RetargetInterval()
+		if header.Height%interval == 0 {
+			start, err := i.db.BlockHeader().SelectRange(header.Height-interval, header.Height-interval)
+			if err != nil {
+				return "", errors.Wrap(err, "failed to get retarget period start")
+			}
+			if len(start) > 0 {
+				ctx.PeriodStart = fromDataHeader(start[0])
+			} else {
+				i.logger.WithField("height", header.Height).Warn("retarget period start is not stored, skipping difficulty adjustment check")
+			}
+		} else if i.params.PowAllowMinDifficultyBlocks {
+			if ctx.LastBits, err = i.lastNonMinBits(tip); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	err = i.params.ValidateHeader(header, ctx)
+	if errors.Cause(err) == bitcoin.ErrPrevHashMismatch {
+		// The node moved to another branch between reading the tip and
+		// fetching this block; reorg detection deals with it.
+		return "", err
+	}
+	if err != nil {
+		return "", &ValidationError{Height: header.Height, Hash: header.BlockHash, Err: err}
+	}
+
+	bits, _ := bitcoin.ParseBits(header.Bits)
+	switch {
+	case tip != nil && tip.Chainwork != "":
+		return bitcoin.AddChainwork(tip.Chainwork, bits)
+	case header.Chainwork != "":
+		// Nothing below the checkpoint is stored, so take the node's word
+		// for the work leading up to it.
+		return header.Chainwork, nil
+	default:
+		return bitcoin.AddChainwork("", bits)
+	}
+}
+
+// lastNonMinBits walks back from tip to the last block that was not mined at
+// the minimum difficulty or starts a retarget period, the way the node finds
+// the difficulty a min difficulty network returns to. It returns zero when
+// the walk runs past the stored headers.
+func (i *Indexer) lastNonMinBits(tip *data.BlockHeader) (uint32, error) {
+	const chunk = 144
+
+	interval := i.params.RetargetInterval()
+	minBits := i.params.MinDifficultyBits()
+	isLast := func(h data.BlockHeader) (uint32, bool) {
+		bits, _ := bitcoin.ParseBits(h.Bits)
+		return bits, h.Height%interval == 0 || bits != minBits
+	}
+
+	if bits, ok := isLast(*tip); ok {
+		return bits, nil
+	}
+
+	periodStart := tip.Height - tip.Height%interval
+	for to := tip.Height - 1; to >= periodStart; to -= chunk {
+		from := max(to-chunk+1, periodStart)
+		headers, err := i.db.BlockHeader().SelectRange(from, to)
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to get headers for min difficulty check", logan.F{
+				"from": from,
+				"to":   to,
+			})
+		}
+		if int64(len(headers)) != to-from+1 {
+			return 0, nil
+		}
+		for n := len(headers) - 1; n >= 0; n-- {
+			if bits, ok := isLast(headers[n]); ok {
+				return bits, nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+// checkpointAt returns the checkpoint at exactly height, if any.
+func (i *Indexer) checkpointAt(height int64) *bitcoin.Checkpoint {
+	cp := bitcoin.LastCheckpoint(i.checkpoints, height)
+	if cp == nil || cp.Height != height {
+		return nil
+	}
+	return cp
+}
+
+// halt stops indexing for good and raises an alert.
+func (i *Indexer) halt(err *ValidationError) {
+	i.halted = err
+	i.logger.WithError(err).WithFields(map[string]interface{}{
+		"height": err.Height,
+		"hash":   err.Hash,
+	}).Error("ALERT: chain from the bitcoin node failed validation, indexer stopped; check the node before restarting")
+}
+
+func fromDataHeader(h data.BlockHeader) *bitcoin.BlockHeader {
+	return &bitcoin.BlockHeader{
+		BlockHash:      h.BlockHash,
+		Version:        h.Version,
+		PreviousHash:   h.PreviousHash,
+		MerkleRoot:     h.MerkleRoot,
+		Timestamp:      h.Timestamp.Unix(),
+		Bits:           h.Bits,
+		Nonce:          uint32(h.Nonce),
+		Height:         h.Height,
+		TransactionNum: h.TransactionNum,
+		Chainwork:      h.Chainwork,
+	}
+}
+
+// ErrNetworkMismatch is returned by CheckNetwork when the node follows another
+// chain than the configured network.
+var ErrNetworkMismatch = errors.New("bitcoin node is on another network")
+
+// CheckNetwork makes sure the node follows the configured network, by its
+// genesis block and, where the source tells, its chain name.
+func (i *Indexer) CheckNetwork(ctx context.Context) error {
+	if info, ok := i.source.(bitcoin.ChainInfoSource); ok {
+		chain, err := info.GetBlockchainInfo(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to get blockchain info")
+		}
+		if chain.Chain != i.params.Chain {
+			return errors.From(ErrNetworkMismatch, logan.F{
+				"network":    i.params.Name,
+				"node_chain": chain.Chain,
+			})
+		}
+	}
+
+	genesis, err := i.source.GetBlockHash(ctx, 0)
+	if err != nil {
+		return errors.Wrap(err, "failed to get genesis block hash")
+	}
+	if genesis != i.params.Checkpoints[0].Hash {
+		return errors.From(ErrNetworkMismatch, logan.F{
+			"network":      i.params.Name,
+			"node_genesis": genesis,
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package indexer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+	"gitlab.com/distributed_lab/logan/v3/errors"
+)
+
+func TestStoreHeadersValidatesAgainstTheChain(t *testing.T) {
+	_, blocks := newTestChain(t)
+	i, db := newTestIndexer(t, nil, Config{})
+
+	// the header chain has to start at a checkpoint
+	err := i.storeHeaders([]*bitcoin.BlockHeader{&blocks[1].Header})
+	if vErr, ok := err.(*ValidationError); !ok || vErr.Height != 1 {
+		t.Fatalf("chain started past the checkpoint = %v, want a validation error", err)
+	}
+
+	if err := i.storeHeaders([]*bitcoin.BlockHeader{&blocks[0].Header, &blocks[1].Header, &blocks[2].Header}); err != nil {
+		t.Fatal(err)
+	}
+
+	// regtest never retargets, so the bits have to stay the same
+	prev := blocks[2].Header
+	prev.Bits = "2000ffff"
+	harder := mineBlock(t, prev, coinbase("coinbase 3"))
+	assertInvalid(t, i.storeHeaders([]*bitcoin.BlockHeader{&harder.Header}), bitcoin.ErrBadDifficulty)
+
+	// the median time past of the stored blocks is the one of block 1
+	prev = blocks[2].Header
+	prev.Timestamp = blocks[1].Header.Timestamp - 600
+	early := mineBlock(t, prev, coinbase("coinbase 3"))
+	assertInvalid(t, i.storeHeaders([]*bitcoin.BlockHeader{&early.Header}), bitcoin.ErrTimeTooOld)
+
+	if tip, _ := db.BlockHeader().GetLast(); tip.Height != 2 || tip.Chainwork == "" {
+		t.Errorf("tip = %+v, want block 2 with its chainwork", tip)
+	}
+}
+
+func TestStoreHeadersChecksCheckpoints(t *testing.T) {
+	_, blocks := newTestChain(t)
+	i, db := newTestIndexer(t, nil, Config{Checkpoints: []bitcoin.Checkpoint{
+		{Height: 2, Hash: blocks[1].Header.BlockHash},
+	}})
+
+	err := i.storeHeaders([]*bitcoin.BlockHeader{&blocks[0].Header, &blocks[1].Header, &blocks[2].Header})
+	assertInvalid(t, err, bitcoin.ErrCheckpointMismatch)
+	// the batch is stored all or nothing
+	if tip, _ := db.BlockHeader().GetLast(); tip != nil {
+		t.Errorf("headers stored up to %d before the mismatch", tip.Height)
+	}
+}
+
+// TestLastNonMinBits walks back over testnet blocks mined at the minimum
+// difficulty under the 20 minute rule.
+func TestLastNonMinBits(t *testing.T) {
+	const (
+		minBits  = "1d00ffff"
+		realBits = "1c0ffff0"
+	)
+	params := bitcoin.TestNetParams
+	i, db := newTestIndexer(t, nil, Config{Params: &params})
+
+	insert := func(height int64, bits string) *data.BlockHeader {
+		header := data.BlockHeader{Height: height, Bits: bits, Timestamp: time.Unix(height*600, 0)}
+		if err := db.BlockHeader().Insert(header); err != nil {
+			t.Fatal(err)
+		}
+		return &header
+	}
+	lastBits := func(tip *data.BlockHeader) uint32 {
+		t.Helper()
+		bits, err := i.lastNonMinBits(tip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bits
+	}
+
+	// 4032 starts a retarget period
+	insert(4031, realBits)
+	insert(4032, realBits)
+	insert(4033, minBits)
+	tip := insert(4034, minBits)
+	if bits := lastBits(tip); bits != 0x1c0ffff0 {
+		t.Errorf("bits = %08x, want 1c0ffff0 of block 4032", bits)
+	}
+	if bits := lastBits(insert(4035, "1c0fff00")); bits != 0x1c0fff00 {
+		t.Errorf("bits = %08x, want 1c0fff00 of the tip itself", bits)
+	}
+
+	// the walk stops at the period start even when it is at the minimum
+	insert(4032, minBits)
+	if bits := lastBits(tip); bits != 0x1d00ffff {
+		t.Errorf("bits = %08x, want 1d00ffff of block 4032", bits)
+	}
+
+	delete(db.state.headers, 4033)
+	if bits := lastBits(tip); bits != 0 {
+		t.Errorf("bits = %08x, want 0 past the stored headers", bits)
+	}
+}
+
+func assertInvalid(t *testing.T, err error, want error) {
+	t.Helper()
+	vErr, ok := err.(*ValidationError)
+	if !ok || errors.Cause(vErr.Err) != want {
+		t.Errorf("err = %v, want a validation error for %v", err, want)
+	}
+}
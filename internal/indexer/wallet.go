@@ -0,0 +1,125 @@
+package indexer
+
+import (
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+	"gitlab.com/distributed_lab/logan/v3"
+	"gitlab.com/distributed_lab/logan/v3/errors"
+)
+
+// ExtendWallet derives addresses of wallet until each of its chains has
+// GapLimit addresses past the last used one, and tracks them with status. It
+// returns the number of addresses added.
+func ExtendWallet(db data.MasterQ, params *bitcoin.ChainParams, wallet data.Wallet, status string) (int, error) {
+	desc, err := bitcoin.ParseDescriptor(wallet.Descriptor)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to parse wallet descriptor")
+	}
+
+	addresses, err := db.Address().SelectByWallet(wallet.ID)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to select wallet addresses")
+	}
+
+	next := make([]int64, desc.ChainCount())
+	lastUsed := make([]int64, desc.ChainCount())
+	for chain := range lastUsed {
+		lastUsed[chain] = -1
+	}
+	for _, addr := range addresses {
+		if addr.Chain == nil || addr.DerivationIndex == nil || int(*addr.Chain) >= len(next) {
+			continue
+		}
+		chain, index := *addr.Chain, *addr.DerivationIndex
+		if index >= next[chain] {
+			next[chain] = index + 1
+		}
+		if addr.Used && index > lastUsed[chain] {
+			lastUsed[chain] = index
+		}
+	}
+
+	added := 0
+	for chain := range next {
+		for index := next[chain]; index <= lastUsed[chain]+wallet.GapLimit; index++ {
+			address, err := desc.Address(params, chain, uint32(index))
+			if err == bitcoin.ErrUnusableChild {
+				continue
+			}
+			if err != nil {
+				return added, errors.Wrap(err, "failed to derive address", logan.F{
+					"chain": chain,
+					"index": index,
+				})
+			}
+
+			decoded, err := params.DecodeAddress(address)
+			if err != nil {
+				return added, errors.Wrap(err, "derived an invalid address")
+			}
+
+			chainID := int64(chain)
+
+			// already tracked by hand, it becomes part of the wallet so it
+			// counts towards the gap and is rescanned with it
+			existing, err := db.Address().GetByAddressUserID(address, wallet.UserID)
+			if err != nil {
+				return added, errors.Wrap(err, "failed to get address")
+			}
+			if existing != nil {
+				if existing.WalletID != nil {
+					continue
+				}
+				if err := db.Address().LinkToWallet(existing.ID, wallet.ID, chainID, index); err != nil {
+					return added, errors.Wrap(err, "failed to link address to wallet", logan.F{
+						"address": address,
+					})
+				}
+				continue
+			}
+
+			err = db.Address().Insert(data.Address{
+				UserID:          wallet.UserID,
+				Address:         address,
+				Status:          status,
+				ScriptType:      decoded.ScriptType,
+				WalletID:        &wallet.ID,
+				Chain:           &chainID,
+				DerivationIndex: &index,
+			})
+			if err != nil {
+				return added, errors.Wrap(err, "failed to insert derived address")
+			}
+			added++
+		}
+	}
+
+	return added, nil
+}
+
+// useWalletAddress marks a wallet address as used and moves the gap of its
+// wallet past it, reporting whether new addresses were derived. New addresses
+// share its status, so they are picked up by a running rescan of the wallet.
+func (i *Indexer) useWalletAddress(addr data.Address) (bool, error) {
+	if err := i.db.Address().MarkUsed(addr.ID); err != nil {
+		return false, errors.Wrap(err, "failed to mark address as used")
+	}
+
+	wallet, err := i.db.Wallet().GetByID(*addr.WalletID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get wallet")
+	}
+
+	added, err := ExtendWallet(i.db, i.params, *wallet, addr.Status)
+	if err != nil {
+		return false, err
+	}
+	if added > 0 {
+		i.walletExtended = true
+		i.logger.WithFields(map[string]interface{}{
+			"wallet_id": wallet.ID,
+			"added":     added,
+		}).Info("derived new wallet addresses")
+	}
+	return added > 0, nil
+}
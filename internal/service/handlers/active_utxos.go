@@ -4,7 +4,6 @@ import (
 	"errors"
 	"net/http"
 
-	"github.com/go-chi/chi"
 	"gitlab.com/distributed_lab/ape"
 	"gitlab.com/distributed_lab/ape/problems"
 )
@@ -12,7 +11,7 @@ import (
 func ActiveUTXOsByAddress(w http.ResponseWriter, r *http.Request) {
 	logger := Log(r)
 	db := DB(r)
-	addressStr := chi.URLParam(r, "address")
+	addressStr := AddressParam(r)
 	userID := UserID(r)
 
 	addr, _ := db.Address().GetByAddressUserID(addressStr, userID)
@@ -23,7 +22,7 @@ func ActiveUTXOsByAddress(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utxos, err := db.UTXO().SelectByAddressID(addr.ID)
+	utxos, err := db.UTXO().SelectUnspentByAddressID(addr.ID)
 	if err != nil {
 		logger.WithError(err).Error("failed to select utxos")
 		ape.RenderErr(w, problems.InternalError())
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
@@ -11,6 +13,30 @@ import (
 	"gitlab.com/distributed_lab/ape/problems"
 )
 
+// utxoImporter is the part of the indexer used to seed balances of new
+// addresses.
+type utxoImporter interface {
+	CanImportUTXOs() bool
+	ImportUTXOs(ctx context.Context, addressID int64, address string) error
+}
+
+var errBirthHeightTooLow = errors.New("birth height is below the first stored block header")
+
+// headerChainStart is the height of the first stored block header, the lowest
+// birth height a rescan can start from. Before the header chain is
+// bootstrapped it is the start height, the checkpoint it starts from is at
+// or below it.
+func headerChainStart(r *http.Request) (int64, error) {
+	first, err := DB(r).BlockHeader().GetFirst()
+	if err != nil {
+		return 0, err
+	}
+	if first == nil {
+		return StartHeight(r), nil
+	}
+	return first.Height, nil
+}
+
 func NewAddress(w http.ResponseWriter, r *http.Request) {
 	var req requests.NewAddressRequest
 
@@ -28,6 +54,14 @@ func NewAddress(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// decoded for the network the indexer runs on
+	decoded, err := ChainParams(r).DecodeAddress(req.Address)
+	if err != nil {
+		logger.WithError(err).Warn("invalid address")
+		ape.RenderErr(w, problems.BadRequest(err)...)
+		return
+	}
+
 	username := Username(r)
 	if username == "" {
 		logger.Error("username not found in context")
@@ -48,9 +82,57 @@ func NewAddress(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = DB(r).Address().Insert(data.Address{
-		UserID:  user.ID,
-		Address: req.Address,
+	importer, _ := Indexer(r).(utxoImporter)
+	if req.ImportUTXOs && (importer == nil || !importer.CanImportUTXOs()) {
+		logger.Warn("utxo import is not supported by the bitcoin source")
+		ape.RenderErr(w, problems.BadRequest(errors.New("utxo import is not supported"))...)
+		return
+	}
+
+	birthHeight := StartHeight(r)
+	if req.BirthHeight != nil {
+		birthHeight = *req.BirthHeight
+	}
+	start, err := headerChainStart(r)
+	if err != nil {
+		logger.WithError(err).Error("failed to get first block header")
+		ape.RenderErr(w, problems.InternalError())
+		return
+	}
+	if birthHeight < start {
+		ape.RenderErr(w, problems.BadRequest(errBirthHeightTooLow)...)
+		return
+	}
+
+	var addressID int64
+	db := DB(r)
+	err = db.NewTransaction(func() error {
+		err := db.Address().Insert(data.Address{
+			UserID:     user.ID,
+			Address:    decoded.Canonical,
+			Status:     data.AddressStatusSyncing,
+			ScriptType: decoded.ScriptType,
+		})
+		if err != nil {
+			return err
+		}
+
+		addr, err := db.Address().GetByAddressUserID(decoded.Canonical, user.ID)
+		if err != nil {
+			return err
+		}
+		if addr == nil {
+			return errors.New("inserted address not found")
+		}
+		addressID = addr.ID
+
+		// the history up to the block cursor is left to the rescan, live
+		// sync picks the address up from now on
+		return db.RescanJob().Insert(data.RescanJob{
+			AddressID:     &addr.ID,
+			FromHeight:    birthHeight,
+			CurrentHeight: birthHeight - 1,
+		})
 	})
 
 	if err != nil {
@@ -59,6 +141,13 @@ func NewAddress(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.ImportUTXOs {
+		// the address is stored, a failed import is caught up by the rescan
+		if err := importer.ImportUTXOs(Service(r), addressID, decoded.Canonical); err != nil {
+			logger.WithError(err).Error("failed to start utxo import")
+		}
+	}
+
 	w.WriteHeader(http.StatusCreated)
 	ape.Render(w, models.SuccessResponse{Message: models.NewAddressSuccessMessage})
 
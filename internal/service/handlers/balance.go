@@ -5,7 +5,6 @@ import (
 	"net/http"
 
 	"github.com/Myrtilli/transaction-indexing-svc/internal/service/models"
-	"github.com/go-chi/chi"
 	"gitlab.com/distributed_lab/ape"
 	"gitlab.com/distributed_lab/ape/problems"
 )
@@ -13,7 +12,7 @@ import (
 func GetBalance(w http.ResponseWriter, r *http.Request) {
 	logger := Log(r).WithField("handler", "GetBalance")
 	db := DB(r)
-	addressStr := chi.URLParam(r, "address")
+	addressStr := AddressParam(r)
 	userID := UserID(r)
 
 	username, ok := r.Context().Value(usernameCtxKey).(string)
@@ -48,30 +47,33 @@ func GetBalance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lastBlock, err := db.BlockHeader().GetLast()
+	currentHeight, err := indexedHeight(db)
 	if err != nil {
-		logger.WithError(err).Error("failed to get last block header")
+		logger.WithError(err).Error("failed to get block cursor")
 		ape.RenderErr(w, problems.InternalError())
 		return
 	}
 
-	var currentHeight int64
-	if lastBlock != nil {
-		currentHeight = lastBlock.Height
+	utxos, err := db.UTXO().SelectUnspentByAddressID(addr.ID)
+	if err != nil {
+		logger.WithError(err).Error("failed to select utxos")
+		ape.RenderErr(w, problems.InternalError())
+		return
 	}
 
-	utxos, err := db.UTXO().SelectByAddressID(addr.ID)
+	pending, err := db.Mempool().SelectByAddressID(addr.ID)
 	if err != nil {
-		logger.WithError(err).Error("failed to select utxos")
+		logger.WithError(err).Error("failed to select pending transactions")
 		ape.RenderErr(w, problems.InternalError())
 		return
 	}
 
-	response := models.NewBalanceResponse(addressStr, utxos, currentHeight)
+	response := models.NewBalanceResponse(addressStr, utxos, pending, currentHeight)
 
 	logger.WithFields(map[string]interface{}{
 		"address":   response.Address,
 		"confirmed": response.ConfirmedBalance,
+		"pending":   len(pending),
 		"total":     response.TotalBalance,
 	}).Info("balance calculated")
 
@@ -7,6 +7,8 @@ import (
 
 	"github.com/Myrtilli/transaction-indexing-svc/internal/config"
 	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+	"github.com/go-chi/chi"
 	"gitlab.com/distributed_lab/logan/v3"
 )
 
@@ -19,6 +21,8 @@ const (
 	usernameCtxKey ctxKey = iota
 	indexerCtxKey  ctxKey = iota
 	userIDCtxKey   ctxKey = iota
+	bitcoinCtxKey  ctxKey = iota
+	serviceCtxKey  ctxKey = iota
 )
 
 func CtxLog(entry *logan.Entry) func(context.Context) context.Context {
@@ -84,3 +88,55 @@ func CtxIndexer(indexer interface{}) func(context.Context) context.Context {
 func Indexer(r *http.Request) interface{} {
 	return r.Context().Value(indexerCtxKey)
 }
+
+// CtxService stores the context of the service, which background work
+// started by a request runs in so it stops with the service rather than
+// with the request.
+func CtxService(service context.Context) func(context.Context) context.Context {
+	return func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, serviceCtxKey, service)
+	}
+}
+
+func Service(r *http.Request) context.Context {
+	ctx, ok := r.Context().Value(serviceCtxKey).(context.Context)
+	if !ok {
+		return context.Background()
+	}
+	return ctx
+}
+
+func CtxBitcoin(cfg config.Bitcoin) func(context.Context) context.Context {
+	return func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, bitcoinCtxKey, cfg)
+	}
+}
+
+// StartHeight is the first block the indexer indexes, the default birth
+// height of new addresses.
+func StartHeight(r *http.Request) int64 {
+	cfg, ok := r.Context().Value(bitcoinCtxKey).(config.Bitcoin)
+	if !ok {
+		return 0
+	}
+	return cfg.StartHeight()
+}
+
+// ChainParams are the parameters of the network the indexer runs on.
+func ChainParams(r *http.Request) *bitcoin.ChainParams {
+	cfg, ok := r.Context().Value(bitcoinCtxKey).(config.Bitcoin)
+	if !ok {
+		return &bitcoin.MainNetParams
+	}
+	return cfg.ChainParams()
+}
+
+// AddressParam returns the address URL parameter in canonical form, so bech32
+// addresses are found whatever their case.
+func AddressParam(r *http.Request) string {
+	address := chi.URLParam(r, "address")
+	if decoded, err := ChainParams(r).DecodeAddress(address); err == nil {
+		return decoded.Canonical
+	}
+	return address
+}
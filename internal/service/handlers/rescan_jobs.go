@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/service/models"
+	"gitlab.com/distributed_lab/ape"
+	"gitlab.com/distributed_lab/ape/problems"
+)
+
+// RescanJobsByAddress returns the rescan jobs of an address, or of its wallet
+// for derived addresses, newest first.
+func RescanJobsByAddress(w http.ResponseWriter, r *http.Request) {
+	logger := Log(r).WithField("handler", "RescanJobsByAddress")
+	db := DB(r)
+	addressStr := AddressParam(r)
+
+	addr, err := db.Address().GetByAddressUserID(addressStr, UserID(r))
+	if err != nil {
+		logger.WithError(err).Error("failed to get address")
+		ape.RenderErr(w, problems.InternalError())
+		return
+	}
+	if addr == nil {
+		ape.RenderErr(w, problems.NotFound())
+		return
+	}
+
+	var jobs []data.RescanJob
+	if addr.WalletID != nil {
+		jobs, err = db.RescanJob().SelectByWalletID(*addr.WalletID)
+	} else {
+		jobs, err = db.RescanJob().SelectByAddressID(addr.ID)
+	}
+	if err != nil {
+		logger.WithError(err).Error("failed to select rescan jobs")
+		ape.RenderErr(w, problems.InternalError())
+		return
+	}
+
+	ape.Render(w, models.NewRescanJobList(jobs))
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/service/models"
+	"github.com/go-chi/chi"
+	"gitlab.com/distributed_lab/ape"
+	"gitlab.com/distributed_lab/ape/problems"
+)
+
+func TransactionByID(w http.ResponseWriter, r *http.Request) {
+	logger := Log(r)
+	db := DB(r)
+	addressStr := AddressParam(r)
+	txID := chi.URLParam(r, "tx_id")
+	userID := UserID(r)
+
+	addr, err := db.Address().GetByAddressUserID(addressStr, userID)
+	if err != nil {
+		logger.WithError(err).Error("failed to get address from DB")
+		ape.RenderErr(w, problems.InternalError())
+		return
+	}
+	if addr == nil {
+		err := errors.New(addressStr + " is not tracked, please, add them to your addresses list")
+		logger.Error(err.Error())
+		ape.RenderErr(w, problems.BadRequest(err)...)
+		return
+	}
+
+	tx, err := db.Transaction().GetByTxIDAddressID(txID, addr.ID)
+	if err != nil {
+		logger.WithError(err).Error("failed to get transaction")
+		ape.RenderErr(w, problems.InternalError())
+		return
+	}
+	if tx == nil {
+		ape.RenderErr(w, problems.NotFound())
+		return
+	}
+
+	header, err := db.BlockHeader().GetByHeight(tx.BlockHeight)
+	if err != nil {
+		logger.WithError(err).Error("failed to get block header")
+		ape.RenderErr(w, problems.InternalError())
+		return
+	}
+
+	height, err := indexedHeight(db)
+	if err != nil {
+		logger.WithError(err).Error("failed to get block cursor")
+		ape.RenderErr(w, problems.InternalError())
+		return
+	}
+
+	ape.Render(w, models.NewTxHistoryItem(*tx, header, height))
+}
+
+// indexedHeight is the height of the last block whose transactions are
+// indexed. Confirmations count from it rather than from the header tip, which
+// runs ahead while blocks are caught up.
+func indexedHeight(db data.MasterQ) (int64, error) {
+	cursor, err := db.SyncCursor().Get(data.CursorBlocks)
+	if err != nil || cursor == nil {
+		return 0, err
+	}
+	return cursor.Height, nil
+}
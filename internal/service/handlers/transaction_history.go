@@ -4,8 +4,8 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
 	"github.com/Myrtilli/transaction-indexing-svc/internal/service/models"
-	"github.com/go-chi/chi"
 	"gitlab.com/distributed_lab/ape"
 	"gitlab.com/distributed_lab/ape/problems"
 )
@@ -13,7 +13,7 @@ import (
 func TransactionHistoryByAddress(w http.ResponseWriter, r *http.Request) {
 	logger := Log(r)
 	db := DB(r)
-	addressStr := chi.URLParam(r, "address")
+	addressStr := AddressParam(r)
 	userID := UserID(r)
 
 	addr, err := db.Address().GetByAddressUserID(addressStr, userID)
@@ -36,12 +36,36 @@ func TransactionHistoryByAddress(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lastBlock, _ := db.BlockHeader().GetLast()
-	var height int64
-	if lastBlock != nil {
-		height = lastBlock.Height
+	pending, err := db.Mempool().SelectByAddressID(addr.ID)
+	if err != nil {
+		logger.WithError(err).Error("failed to select pending transactions")
+		ape.RenderErr(w, problems.InternalError())
+		return
 	}
 
-	logger.Infof("returned %d transactions for address %s", len(txs), addressStr)
-	ape.Render(w, models.NewTxHistoryList(txs, height))
+	height, err := indexedHeight(db)
+	if err != nil {
+		logger.WithError(err).Error("failed to get block cursor")
+		ape.RenderErr(w, problems.InternalError())
+		return
+	}
+
+	headers := make(map[int64]data.BlockHeader)
+	for _, tx := range txs {
+		if _, ok := headers[tx.BlockHeight]; ok {
+			continue
+		}
+		header, err := db.BlockHeader().GetByHeight(tx.BlockHeight)
+		if err != nil {
+			logger.WithError(err).Error("failed to get block header")
+			ape.RenderErr(w, problems.InternalError())
+			return
+		}
+		headers[tx.BlockHeight] = *header
+	}
+
+	history := append(models.NewPendingTxHistoryList(pending), models.NewTxHistoryList(txs, headers, height)...)
+
+	logger.Infof("returned %d transactions for address %s", len(history), addressStr)
+	ape.Render(w, history)
 }
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/service/models"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/service/requests"
+	"github.com/go-chi/chi"
+	"gitlab.com/distributed_lab/ape"
+	"gitlab.com/distributed_lab/ape/problems"
+)
+
+var errWalletExists = errors.New("wallet already exists")
+
+// NewWallet tracks the addresses derived from an extended public key. The
+// first gap limit addresses of each chain are derived right away and their
+// history rescanned together; more are derived as the indexer sees them used.
+func NewWallet(w http.ResponseWriter, r *http.Request) {
+	var req requests.NewWalletRequest
+
+	logger := Log(r).WithField("handler", "NewWallet")
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.WithError(err).Error("failed to decode request body")
+		ape.RenderErr(w, problems.BadRequest(err)...)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		logger.WithError(err).Error("invalid request")
+		ape.RenderErr(w, problems.BadRequest(err)...)
+		return
+	}
+
+	desc, err := bitcoin.ParseDescriptor(req.Descriptor)
+	if err != nil {
+		logger.WithError(err).Warn("invalid descriptor")
+		ape.RenderErr(w, problems.BadRequest(err)...)
+		return
+	}
+	if err := desc.Key.CheckNetwork(ChainParams(r)); err != nil {
+		logger.WithError(err).Warn("descriptor key is for another network")
+		ape.RenderErr(w, problems.BadRequest(err)...)
+		return
+	}
+
+	gapLimit := int64(data.DefaultGapLimit)
+	if req.GapLimit != nil {
+		gapLimit = *req.GapLimit
+	}
+	birthHeight := StartHeight(r)
+	if req.BirthHeight != nil {
+		birthHeight = *req.BirthHeight
+	}
+	start, err := headerChainStart(r)
+	if err != nil {
+		logger.WithError(err).Error("failed to get first block header")
+		ape.RenderErr(w, problems.InternalError())
+		return
+	}
+	if birthHeight < start {
+		ape.RenderErr(w, problems.BadRequest(errBirthHeightTooLow)...)
+		return
+	}
+
+	userID := UserID(r)
+	db := DB(r)
+	err = db.NewTransaction(func() error {
+		existing, err := db.Wallet().GetByDescriptorUserID(desc.String(), userID)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return errWalletExists
+		}
+
+		err = db.Wallet().Insert(data.Wallet{
+			UserID:     userID,
+			Descriptor: desc.String(),
+			GapLimit:   gapLimit,
+		})
+		if err != nil {
+			return err
+		}
+
+		wallet, err := db.Wallet().GetByDescriptorUserID(desc.String(), userID)
+		if err != nil {
+			return err
+		}
+		if wallet == nil {
+			return errors.New("inserted wallet not found")
+		}
+
+		if _, err := indexer.ExtendWallet(db, ChainParams(r), *wallet, data.AddressStatusSyncing); err != nil {
+			return err
+		}
+
+		return db.RescanJob().Insert(data.RescanJob{
+			WalletID:      &wallet.ID,
+			FromHeight:    birthHeight,
+			CurrentHeight: birthHeight - 1,
+		})
+	})
+
+	if err == errWalletExists {
+		ape.RenderErr(w, problems.Conflict())
+		return
+	}
+	if err != nil {
+		logger.WithError(err).Error("failed to insert new wallet")
+		ape.RenderErr(w, problems.InternalError())
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	ape.Render(w, models.SuccessResponse{Message: models.NewWalletSuccessMessage})
+}
+
+func GetWallets(w http.ResponseWriter, r *http.Request) {
+	logger := Log(r).WithField("handler", "GetWallets")
+
+	wallets, err := DB(r).Wallet().Select(UserID(r))
+	if err != nil {
+		logger.WithError(err).Error("failed to select wallets")
+		ape.RenderErr(w, problems.InternalError())
+		return
+	}
+
+	ape.Render(w, models.WalletList(wallets))
+}
+
+// WalletAddresses returns the addresses derived from a wallet so far.
+func WalletAddresses(w http.ResponseWriter, r *http.Request) {
+	logger := Log(r).WithField("handler", "WalletAddresses")
+	db := DB(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		ape.RenderErr(w, problems.BadRequest(err)...)
+		return
+	}
+
+	wallet, err := db.Wallet().GetByID(id)
+	if err != nil || wallet.UserID != UserID(r) {
+		ape.RenderErr(w, problems.NotFound())
+		return
+	}
+
+	addresses, err := db.Address().SelectByWallet(wallet.ID)
+	if err != nil {
+		logger.WithError(err).Error("failed to select wallet addresses")
+		ape.RenderErr(w, problems.InternalError())
+		return
+	}
+
+	ape.Render(w, models.AddressList(addresses))
+}
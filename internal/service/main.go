@@ -27,12 +27,28 @@ func (s *service) run(cfg config.Config) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	if err := s.indexer.CheckNetwork(ctx); err != nil {
+		return errors.Wrap(err, "failed to check the bitcoin node network")
+	}
+
 	go func() {
 		s.log.Info("Starting background indexer loop")
 		s.indexer.Run(ctx)
 	}()
 
-	r := s.router(cfg)
+	go func() {
+		s.indexer.RunMempool(ctx)
+	}()
+
+	go func() {
+		s.indexer.RunRescans(ctx)
+	}()
+
+	go func() {
+		s.indexer.RunNodeHealth(ctx)
+	}()
+
+	r := s.router(ctx, cfg)
 
 	if err := s.copus.RegisterChi(r); err != nil {
 		return errors.Wrap(err, "cop failed")
@@ -43,12 +59,18 @@ func (s *service) run(cfg config.Config) error {
 
 func newService(cfg config.Config) *service {
 	db := pg.NewMasterQ(cfg.DB())
-	rpc := bitcoin.NewRPCClient(cfg.NodeURL(), cfg.NodeUser(), cfg.NodePass())
-
-	idx := indexer.New(cfg.Log(), db, rpc, indexer.Config{
-		MaxReorgDepth: 6,
-		PollInterval:  cfg.IndexerPollInterval(),
-		StartHeight:   int(cfg.StartHeight()),
+	idx := indexer.New(cfg.Log(), db, newBlockSource(cfg), indexer.Config{
+		MaxReorgDepth:       cfg.MaxReorgDepth(),
+		PollInterval:        cfg.IndexerPollInterval(),
+		MempoolPollInterval: cfg.MempoolPollInterval(),
+		StartHeight:         int(cfg.StartHeight()),
+		ZMQURL:              cfg.ZMQURL(),
+		CatchUpWorkers:      cfg.CatchUpWorkers(),
+		HeaderBatchSize:     cfg.HeaderBatchSize(),
+		FilterScan:          cfg.FilterScan(),
+		NodeHealthInterval:  cfg.NodeHealthInterval(),
+		Params:              cfg.ChainParams(),
+		Checkpoints:         cfg.Checkpoints(),
 	})
 
 	return &service{
@@ -59,6 +81,29 @@ func newService(cfg config.Config) *service {
 	}
 }
 
+func newBlockSource(cfg config.Config) bitcoin.BlockSource {
+	switch cfg.BlockSource() {
+	case bitcoin.SourceEsplora:
+		return bitcoin.NewEsploraClient(cfg.EsploraURL())
+	default:
+		nodes := cfg.Nodes()
+		clients := make([]*bitcoin.RPCClient, len(nodes))
+		for n, node := range nodes {
+			rpc := cfg.NodeRPC()
+			rpc.CookieFile = node.CookieFile
+			rpc.TLS = node.TLS
+			clients[n] = bitcoin.NewRPCClient(node.URL, node.User, node.Pass, rpc)
+		}
+		if len(clients) == 1 {
+			return clients[0]
+		}
+
+		pool := bitcoin.NewNodePool(clients...)
+		pool.Chain = cfg.ChainParams().Chain
+		return pool
+	}
+}
+
 func Run(cfg config.Config) {
 	if err := newService(cfg).run(cfg); err != nil {
 		panic(err)
@@ -1,6 +1,13 @@
 package models
 
-import "github.com/Myrtilli/transaction-indexing-svc/internal/data"
+import (
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/Myrtilli/transaction-indexing-svc/internal/amount"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/data"
+	"github.com/Myrtilli/transaction-indexing-svc/internal/indexer/bitcoin"
+)
 
 type SuccessResponse struct {
 	Token   string `json:"token,omitempty"`
@@ -11,35 +18,155 @@ const (
 	RegistrationSuccessMessage = "User registered successfully"
 	LoginSuccessMessage        = "User logged in successfully"
 	NewAddressSuccessMessage   = "Address added successfully"
+	NewWalletSuccessMessage    = "Wallet added successfully"
 )
 
 type AddressModel struct {
 	ID      int64  `json:"id"`
 	Address string `json:"address"`
+	Status  string `json:"status"`
+	// ScriptType is the type of the output script the address pays to.
+	ScriptType string `json:"script_type,omitempty"`
+	// WalletID, Chain and Index are set for addresses derived from a
+	// wallet.
+	WalletID *int64 `json:"wallet_id,omitempty"`
+	Chain    *int64 `json:"chain,omitempty"`
+	Index    *int64 `json:"index,omitempty"`
+	Used     bool   `json:"used"`
 }
 
 func AddressList(src []data.Address) []AddressModel {
 	res := make([]AddressModel, len(src))
 	for i, v := range src {
 		res[i] = AddressModel{
-			ID:      v.ID,
-			Address: v.Address,
+			ID:         v.ID,
+			Address:    v.Address,
+			Status:     v.Status,
+			ScriptType: v.ScriptType,
+			WalletID:   v.WalletID,
+			Chain:      v.Chain,
+			Index:      v.DerivationIndex,
+			Used:       v.Used,
+		}
+	}
+	return res
+}
+
+type WalletModel struct {
+	ID         int64  `json:"id"`
+	Descriptor string `json:"descriptor"`
+	GapLimit   int64  `json:"gap_limit"`
+	CreatedAt  int64  `json:"created_at"`
+}
+
+func WalletList(src []data.Wallet) []WalletModel {
+	res := make([]WalletModel, len(src))
+	for i, v := range src {
+		res[i] = WalletModel{
+			ID:         v.ID,
+			Descriptor: v.Descriptor,
+			GapLimit:   v.GapLimit,
+			CreatedAt:  v.CreatedAt.Unix(),
+		}
+	}
+	return res
+}
+
+type RescanJobModel struct {
+	ID            int64   `json:"id"`
+	Status        string  `json:"status"`
+	FromHeight    int64   `json:"from_height"`
+	ToHeight      *int64  `json:"to_height"`
+	CurrentHeight int64   `json:"current_height"`
+	Progress      float64 `json:"progress"`
+	Error         *string `json:"error,omitempty"`
+	CreatedAt     int64   `json:"created_at"`
+	UpdatedAt     int64   `json:"updated_at"`
+}
+
+// NewRescanJobList builds rescan job statuses. Progress is the share of the
+// blocks up to the latest target that were scanned.
+func NewRescanJobList(jobs []data.RescanJob) []RescanJobModel {
+	res := make([]RescanJobModel, len(jobs))
+	for i, job := range jobs {
+		res[i] = RescanJobModel{
+			ID:            job.ID,
+			Status:        job.Status,
+			FromHeight:    job.FromHeight,
+			ToHeight:      job.ToHeight,
+			CurrentHeight: job.CurrentHeight,
+			Error:         job.Error,
+			CreatedAt:     job.CreatedAt.Unix(),
+			UpdatedAt:     job.UpdatedAt.Unix(),
+		}
+
+		switch {
+		case job.Status == data.RescanStatusDone:
+			res[i].Progress = 1
+		case job.ToHeight != nil && *job.ToHeight >= job.FromHeight:
+			total := float64(*job.ToHeight - job.FromHeight + 1)
+			res[i].Progress = float64(job.CurrentHeight-job.FromHeight+1) / total
 		}
 	}
 	return res
 }
 
 type UTXOModel struct {
-	TxID        string `json:"tx_id"`
-	Vout        int    `json:"vout"`
-	Amount      int64  `json:"amount"`
-	BlockHeight int64  `json:"block_height"`
+	TxID        string        `json:"tx_id"`
+	Vout        int           `json:"vout"`
+	Amount      amount.Amount `json:"amount"`
+	BlockHeight int64         `json:"block_height"`
+}
+
+type BlockHeaderModel struct {
+	Hash         string `json:"hash"`
+	PreviousHash string `json:"previous_hash"`
+	MerkleRoot   string `json:"merkle_root"`
+	Height       int64  `json:"height"`
+	Timestamp    int64  `json:"timestamp"`
+	Version      int32  `json:"version"`
+	Bits         string `json:"bits"`
+	Nonce        int64  `json:"nonce"`
+	// Raw is the serialized 80 byte header, whose double SHA-256 is Hash
+	Raw string `json:"raw,omitempty"`
+}
+
+func NewBlockHeaderModel(header data.BlockHeader) BlockHeaderModel {
+	res := BlockHeaderModel{
+		Hash:         header.BlockHash,
+		PreviousHash: header.PreviousHash,
+		MerkleRoot:   header.MerkleRoot,
+		Height:       header.Height,
+		Timestamp:    header.Timestamp.Unix(),
+		Version:      header.Version,
+		Bits:         header.Bits,
+		Nonce:        header.Nonce,
+	}
+
+	raw, err := bitcoin.SerializeHeader(&bitcoin.BlockHeader{
+		PreviousHash: header.PreviousHash,
+		MerkleRoot:   header.MerkleRoot,
+		Timestamp:    header.Timestamp.Unix(),
+		Version:      header.Version,
+		Bits:         header.Bits,
+		Nonce:        uint32(header.Nonce),
+	})
+	if err == nil {
+		res.Raw = hex.EncodeToString(raw)
+	}
+
+	return res
 }
 
 type TxHistoryItem struct {
 	TxID          string            `json:"tx_id"`
-	Amount        int64             `json:"amount"`
+	Amount        amount.Amount     `json:"amount"`
+	Direction     string            `json:"direction"`
+	Fee           *amount.Amount    `json:"fee,omitempty"`
+	Status        string            `json:"status"`
 	BlockHeight   int64             `json:"block_height"`
+	BlockHash     string            `json:"block_hash,omitempty"`
+	BlockHeader   *BlockHeaderModel `json:"block_header,omitempty"`
 	Confirmations int64             `json:"confirmations"`
 	MerkleProof   []data.MerkleNode `json:"merkle_proof"`
 	IsConfirmed   bool              `json:"is_confirmed"`
@@ -48,77 +175,118 @@ type TxHistoryItem struct {
 }
 
 type TxInput struct {
-	PrevTxID string `json:"prev_tx_id"`
-	VoutIdx  uint32 `json:"vout_idx"`
-	Address  string `json:"address"`
-	Amount   int64  `json:"amount"`
+	PrevTxID string        `json:"prev_tx_id"`
+	VoutIdx  uint32        `json:"vout_idx"`
+	Address  string        `json:"address"`
+	Amount   amount.Amount `json:"amount"`
 }
 
 type TxOutput struct {
-	Address      string `json:"address"`
-	Amount       int64  `json:"amount"`
-	VoutIdx      uint32 `json:"vout_idx"`
+	Address      string        `json:"address"`
+	ScriptType   string        `json:"script_type,omitempty"`
+	Amount       amount.Amount `json:"amount"`
+	VoutIdx      uint32        `json:"vout_idx"`
 	ScriptPubKey struct {
 		Address   string   `json:"address"`
 		Addresses []string `json:"addresses"`
 	} `json:"scriptPubKey"`
 }
 
-func NewTxHistoryList(txs []data.Transaction, currentHeight int64) []TxHistoryItem {
+// NewTxHistoryList builds history items for confirmed transactions, headers
+// are looked up by block height and may be missing.
+func NewTxHistoryList(txs []data.Transaction, headers map[int64]data.BlockHeader, currentHeight int64) []TxHistoryItem {
 	res := make([]TxHistoryItem, len(txs))
 	for i, tx := range txs {
-		confirmations := currentHeight - tx.BlockHeight + 1
-		if confirmations < 0 {
-			confirmations = 0
+		var header *data.BlockHeader
+		if h, ok := headers[tx.BlockHeight]; ok {
+			header = &h
 		}
+		res[i] = NewTxHistoryItem(tx, header, currentHeight)
+	}
+	return res
+}
 
-		inputs := make([]TxInput, len(tx.Inputs))
-		for j, in := range tx.Inputs {
-			prevID := ""
-			if in.PrevTxID != nil {
-				prevID = *in.PrevTxID
-			}
-			inputs[j] = TxInput{
-				PrevTxID: prevID,
-				VoutIdx:  in.VoutIdx,
-				Address:  in.Address,
-				Amount:   in.Amount,
-			}
+func NewTxHistoryItem(tx data.Transaction, header *data.BlockHeader, currentHeight int64) TxHistoryItem {
+	confirmations := currentHeight - tx.BlockHeight + 1
+	if confirmations < 0 {
+		confirmations = 0
+	}
+
+	inputs := make([]TxInput, len(tx.Inputs))
+	for j, in := range tx.Inputs {
+		prevID := ""
+		if in.PrevTxID != nil {
+			prevID = *in.PrevTxID
+		}
+		inputs[j] = TxInput{
+			PrevTxID: prevID,
+			VoutIdx:  in.VoutIdx,
+			Address:  in.Address,
+			Amount:   in.Amount,
 		}
+	}
 
-		outputs := make([]TxOutput, len(tx.Outputs))
-		for j, out := range tx.Outputs {
-			outputs[j] = TxOutput{
-				VoutIdx: out.VoutIdx,
-				Address: out.Address,
-				Amount:  out.Amount,
-			}
+	outputs := make([]TxOutput, len(tx.Outputs))
+	for j, out := range tx.Outputs {
+		outputs[j] = TxOutput{
+			VoutIdx:    out.VoutIdx,
+			Address:    out.Address,
+			ScriptType: out.ScriptType,
+			Amount:     out.Amount,
 		}
+	}
+
+	proof := []data.MerkleNode{}
+	if len(tx.MerkleProof) > 0 {
+		_ = json.Unmarshal(tx.MerkleProof, &proof)
+	}
+
+	item := TxHistoryItem{
+		TxID:          tx.TxID,
+		Amount:        tx.Amount,
+		Direction:     tx.Direction,
+		Fee:           tx.Fee,
+		Status:        data.TxStatusConfirmed,
+		BlockHeight:   tx.BlockHeight,
+		BlockHash:     tx.BlockHash,
+		Confirmations: confirmations,
+		MerkleProof:   proof,
+		IsConfirmed:   confirmations >= 6,
+		Inputs:        inputs,
+		Outputs:       outputs,
+	}
+	if header != nil {
+		headerModel := NewBlockHeaderModel(*header)
+		item.BlockHeader = &headerModel
+	}
+
+	return item
+}
 
-		var proof []data.MerkleNode
+func NewPendingTxHistoryList(txs []data.MempoolTransaction) []TxHistoryItem {
+	res := make([]TxHistoryItem, len(txs))
+	for i, tx := range txs {
 		res[i] = TxHistoryItem{
-			TxID:          tx.TxID,
-			Amount:        tx.Amount,
-			BlockHeight:   tx.BlockHeight,
-			Confirmations: confirmations,
-			MerkleProof:   proof,
-			IsConfirmed:   confirmations >= 6,
-			Inputs:        inputs,
-			Outputs:       outputs,
+			TxID:      tx.TxID,
+			Amount:    tx.Amount,
+			Direction: tx.Direction,
+			Status:    data.TxStatusPending,
+			Inputs:    []TxInput{},
+			Outputs:   []TxOutput{},
 		}
 	}
 	return res
 }
 
 type BalanceResponse struct {
-	Address            string `json:"address"`
-	ConfirmedBalance   int64  `json:"confirmed_balance"`
-	UnconfirmedBalance int64  `json:"unconfirmed_balance"`
-	TotalBalance       int64  `json:"total_balance"`
+	Address            string        `json:"address"`
+	ConfirmedBalance   amount.Amount `json:"confirmed_balance"`
+	UnconfirmedBalance amount.Amount `json:"unconfirmed_balance"`
+	TotalBalance       amount.Amount `json:"total_balance"`
 }
 
-func NewBalanceResponse(address string, utxos []data.UTXO, currentHeight int64) BalanceResponse {
-	var confirmed, unconfirmed int64
+func NewBalanceResponse(address string, utxos []data.UTXO, pending []data.MempoolTransaction, currentHeight int64) BalanceResponse {
+	var confirmed, unconfirmed amount.Amount
 
 	for _, u := range utxos {
 		if currentHeight-u.BlockHeight >= 5 {
@@ -128,6 +296,14 @@ func NewBalanceResponse(address string, utxos []data.UTXO, currentHeight int64)
 		}
 	}
 
+	for _, tx := range pending {
+		if tx.Direction == data.TxDirectionOutgoing {
+			unconfirmed -= tx.Amount
+		} else {
+			unconfirmed += tx.Amount
+		}
+	}
+
 	return BalanceResponse{
 		Address:            address,
 		ConfirmedBalance:   confirmed,
@@ -47,14 +47,43 @@ func (r *LoginRequest) Validate() error {
 
 type NewAddressRequest struct {
 	Address string `json:"address"`
+	// BirthHeight is the height to rescan the address history from,
+	// defaults to the start height of the indexer.
+	BirthHeight *int64 `json:"birth_height,omitempty"`
+	// ImportUTXOs seeds the current unspent outputs of the address from
+	// the node right away, ahead of the rescan.
+	ImportUTXOs bool `json:"import_utxos,omitempty"`
 }
 
 func (r NewAddressRequest) Validate() error {
 	if strings.TrimSpace(r.Address) == "" {
 		return errors.New("address cannot be empty")
 	}
-	if len(r.Address) < 26 {
-		return errors.New("address is too short")
+	if r.BirthHeight != nil && *r.BirthHeight < 0 {
+		return errors.New("birth height cannot be negative")
+	}
+	return nil
+}
+
+type NewWalletRequest struct {
+	// Descriptor is an account xpub/ypub/zpub or a ranged pkh, sh(wpkh),
+	// wpkh or tr descriptor.
+	Descriptor string `json:"descriptor"`
+	// GapLimit is how many unused addresses are kept derived on each
+	// chain, defaults to 20.
+	GapLimit    *int64 `json:"gap_limit,omitempty"`
+	BirthHeight *int64 `json:"birth_height,omitempty"`
+}
+
+func (r NewWalletRequest) Validate() error {
+	if strings.TrimSpace(r.Descriptor) == "" {
+		return errors.New("descriptor cannot be empty")
+	}
+	if r.GapLimit != nil && (*r.GapLimit < 1 || *r.GapLimit > 1000) {
+		return errors.New("gap limit must be between 1 and 1000")
+	}
+	if r.BirthHeight != nil && *r.BirthHeight < 0 {
+		return errors.New("birth height cannot be negative")
 	}
 	return nil
 }
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"context"
+
 	"github.com/Myrtilli/transaction-indexing-svc/internal/config"
 	"github.com/Myrtilli/transaction-indexing-svc/internal/data/pg"
 	"github.com/Myrtilli/transaction-indexing-svc/internal/service/handlers"
@@ -8,7 +10,7 @@ import (
 	"gitlab.com/distributed_lab/ape"
 )
 
-func (s *service) router(cfg config.Config) chi.Router {
+func (s *service) router(ctx context.Context, cfg config.Config) chi.Router {
 	r := chi.NewRouter()
 
 	r.Use(
@@ -19,6 +21,8 @@ func (s *service) router(cfg config.Config) chi.Router {
 			handlers.CtxDB(pg.NewMasterQ(cfg.DB())),
 			handlers.CtxJWT(cfg),
 			handlers.CtxIndexer(s.indexer),
+			handlers.CtxService(ctx),
+			handlers.CtxBitcoin(cfg),
 		),
 	)
 
@@ -33,10 +37,19 @@ func (s *service) router(cfg config.Config) chi.Router {
 
 			r.Route("/{address}", func(r chi.Router) {
 				r.Get("/txs", handlers.TransactionHistoryByAddress)
+				r.Get("/txs/{tx_id}", handlers.TransactionByID)
 				r.Get("/utxos", handlers.ActiveUTXOsByAddress)
 				r.Get("/balance", handlers.GetBalance)
+				r.Get("/rescans", handlers.RescanJobsByAddress)
 			})
 		})
+
+		r.Route("/wallets", func(r chi.Router) {
+			r.Use(handlers.AuthRequired)
+			r.Post("/", handlers.NewWallet)
+			r.Get("/", handlers.GetWallets)
+			r.Get("/{id}/addresses", handlers.WalletAddresses)
+		})
 	})
 
 	return r